@@ -0,0 +1,444 @@
+package dbtype
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArrayDim is a dimension of an array: its number of elements, and the index
+// of the first one, 1 unless given otherwise.
+type ArrayDim struct {
+	Len   int
+	Lower int
+}
+
+// Parses the text form of an array, e.g. {1,NULL,"a \"b\""} or
+// [0:1][1:2]={{1,2},{3,4}}, with elements separated by delim, which is ','
+// for all built-in types but box, and ':' for PostGIS ones. Returns its
+// dimensions and its elements in row-major order, nil for NULL ones. Empty
+// arrays have no dimensions.
+func ParseArray(s string, delim byte) (dims []ArrayDim, elems []*string, err error) {
+	p := arrayParser{s: s, delim: delim}
+	var bounds []ArrayDim
+	if bounds, err = p.bounds(); err != nil {
+		return
+	}
+	p.skipSpace()
+	if err = p.array(0); err != nil {
+		return
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	if len(p.elems) == 0 {
+		if len(bounds) > 0 {
+			return nil, nil, p.errorf("bounds given for an empty array")
+		}
+		return nil, nil, nil
+	}
+	dims = make([]ArrayDim, len(p.lens))
+	for i, n := range p.lens {
+		dims[i] = ArrayDim{Len: n, Lower: 1}
+	}
+	if bounds != nil {
+		if len(bounds) != len(dims) {
+			return nil, nil, p.errorf("bounds do not match the dimensions")
+		}
+		for i, b := range bounds {
+			if b.Len != dims[i].Len {
+				return nil, nil, p.errorf("bounds do not match the dimensions")
+			}
+		}
+		dims = bounds
+	}
+	return dims, p.elems, nil
+}
+
+type arrayParser struct {
+	s     string
+	pos   int
+	delim byte
+	lens  []int // Of each dimension, as far as seen.
+	depth int   // Of elements, -1 until one is seen.
+	elems []*string
+	seen  bool
+}
+
+func (p *arrayParser) errorf(format string, args ... interface{}) error {
+	return fmt.Errorf("invalid array %q: %s", p.s, fmt.Sprintf(format, args...))
+}
+
+func (p *arrayParser) skipSpace() {
+	for p.pos < len(p.s) && isArraySpace(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+func isArraySpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// Parses the optional [lower:upper]...= decoration.
+func (p *arrayParser) bounds() ([]ArrayDim, error) {
+	p.skipSpace()
+	var res []ArrayDim
+	for p.pos < len(p.s) && p.s[p.pos] == '[' {
+		end := strings.IndexByte(p.s[p.pos:], ']')
+		if end < 0 {
+			return nil, p.errorf("unterminated bounds")
+		}
+		parts := strings.Split(p.s[p.pos+1:p.pos+end], ":")
+		p.pos += end + 1
+		if len(parts) != 2 {
+			return nil, p.errorf("invalid bounds")
+		}
+		lower, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+		upper, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err1 != nil || err2 != nil || upper < lower {
+			return nil, p.errorf("invalid bounds")
+		}
+		res = append(res, ArrayDim{Len: upper - lower + 1, Lower: lower})
+	}
+	if res != nil {
+		p.skipSpace()
+		if p.pos == len(p.s) || p.s[p.pos] != '=' {
+			return nil, p.errorf("expected = after bounds")
+		}
+		p.pos++
+	}
+	return res, nil
+}
+
+func (p *arrayParser) array(depth int) error {
+	if p.pos == len(p.s) || p.s[p.pos] != '{' {
+		return p.errorf("expected {")
+	}
+	p.pos++
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == '}' {
+		p.pos++
+		// Only the whole array may be empty.
+		if depth > 0 || p.seen {
+			return p.errorf("empty sub-array")
+		}
+		return nil
+	}
+	n := 0
+	for {
+		p.skipSpace()
+		if p.pos < len(p.s) && p.s[p.pos] == '{' {
+			if err := p.array(depth + 1); err != nil {
+				return err
+			}
+		} else if err := p.element(depth); err != nil {
+			return err
+		}
+		n++
+		p.skipSpace()
+		if p.pos == len(p.s) {
+			return p.errorf("unterminated array")
+		}
+		if c := p.s[p.pos]; c == '}' {
+			p.pos++
+			break
+		} else if c != p.delim {
+			return p.errorf("unexpected %q", c)
+		}
+		p.pos++
+	}
+	// Inner sub-arrays end first.
+	for len(p.lens) <= depth {
+		p.lens = append(p.lens, -1)
+	}
+	if p.lens[depth] < 0 {
+		p.lens[depth] = n
+	} else if p.lens[depth] != n {
+		return p.errorf("sub-arrays must have matching dimensions")
+	}
+	return nil
+}
+
+func (p *arrayParser) element(depth int) error {
+	if !p.seen {
+		p.seen, p.depth = true, depth
+	} else if depth != p.depth {
+		return p.errorf("sub-arrays must have matching dimensions")
+	}
+	buf := bytes.Buffer{}
+	quoted := p.pos < len(p.s) && p.s[p.pos] == '"'
+	if quoted {
+		p.pos++
+	}
+	// Trailing spaces of unquoted elements are not part of them, unless
+	// escaped.
+	trimmed := 0
+	for {
+		if p.pos == len(p.s) {
+			return p.errorf("unterminated element")
+		}
+		c := p.s[p.pos]
+		if c == '\\' {
+			if p.pos+1 == len(p.s) {
+				return p.errorf("unterminated element")
+			}
+			buf.WriteByte(p.s[p.pos+1])
+			p.pos += 2
+			trimmed = buf.Len()
+			continue
+		}
+		if quoted {
+			p.pos++
+			if c == '"' {
+				break
+			}
+		} else {
+			if c == p.delim || c == '}' {
+				break
+			} else if c == '{' || c == '"' {
+				return p.errorf("unexpected %q", c)
+			}
+			p.pos++
+		}
+		buf.WriteByte(c)
+	}
+	str := buf.String()
+	if !quoted {
+		end := len(str)
+		for end > trimmed && isArraySpace(str[end-1]) {
+			end--
+		}
+		str = str[:end]
+		if str == "" {
+			return p.errorf("empty element")
+		} else if strings.EqualFold(str, "NULL") && trimmed == 0 {
+			p.elems = append(p.elems, nil)
+			return nil
+		}
+	}
+	p.elems = append(p.elems, &str)
+	return nil
+}
+
+// Writes an array of the given dimensions and elements, nil ones being NULL,
+// in the text form ParseArray reads. Bounds are only written if any differs
+// from 1.
+func FormatArray(dims []ArrayDim, elems []*string, delim byte) (string, error) {
+	size := 1
+	for _, d := range dims {
+		size *= d.Len
+	}
+	if len(dims) == 0 || size == 0 {
+		if len(elems) > 0 {
+			return "", fmt.Errorf("%d elements given for an empty array", len(elems))
+		}
+		return "{}", nil
+	} else if size != len(elems) {
+		return "", fmt.Errorf("%d elements given for an array of %d", len(elems), size)
+	}
+	buf := bytes.Buffer{}
+	for _, d := range dims {
+		if d.Lower != 1 {
+			for _, d := range dims {
+				fmt.Fprintf(&buf, "[%d:%d]", d.Lower, d.Lower+d.Len-1)
+			}
+			buf.WriteByte('=')
+			break
+		}
+	}
+	writeSubArray(&buf, dims, elems, delim)
+	return buf.String(), nil
+}
+
+func writeSubArray(buf *bytes.Buffer, dims []ArrayDim, elems []*string, delim byte) {
+	buf.WriteByte('{')
+	step := len(elems) / dims[0].Len
+	for i := 0; i < dims[0].Len; i++ {
+		if i > 0 {
+			buf.WriteByte(delim)
+		}
+		if len(dims) > 1 {
+			writeSubArray(buf, dims[1:], elems[i*step:(i+1)*step], delim)
+		} else {
+			writeArrayElem(buf, elems[i], delim)
+		}
+	}
+	buf.WriteByte('}')
+}
+
+func writeArrayElem(buf *bytes.Buffer, elem *string, delim byte) {
+	if elem == nil {
+		buf.WriteString("NULL")
+		return
+	}
+	s := *elem
+	quote := s == "" || strings.EqualFold(s, "NULL")
+	for i := 0; i < len(s) && !quote; i++ {
+		c := s[i]
+		quote = c == delim || c == '{' || c == '}' || c == '"' || c == '\\' || isArraySpace(c)
+	}
+	if !quote {
+		buf.WriteString(s)
+		return
+	}
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(s[i])
+	}
+	buf.WriteByte('"')
+}
+
+// Scans the text form of a one-dimensional array with no NULL elements, the
+// elements being read by parse.
+func scanArray[T any](src interface{}, delim byte, parse func(string) (T, error)) ([]T, error) {
+	var str string
+	switch val := src.(type) {
+	case string:
+		str = val
+	case []byte:
+		str = string(val)
+	default:
+		return nil, fmt.Errorf("cannot scan %T into an array", src)
+	}
+	dims, elems, err := ParseArray(str, delim)
+	if err != nil {
+		return nil, err
+	} else if len(dims) > 1 {
+		return nil, fmt.Errorf("cannot scan a %d-dimensional array into a slice", len(dims))
+	}
+	res := make([]T, len(elems))
+	for i, e := range elems {
+		if e == nil {
+			return nil, fmt.Errorf("cannot scan NULL elements of %q", str)
+		}
+		if res[i], err = parse(*e); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Returns the text form of a one-dimensional array, the elements being written
+// by format.
+func arrayValue[T any](a []T, delim byte, format func(T) (string, error)) (driver.Value, error) {
+	elems := make([]*string, len(a))
+	for i, v := range a {
+		str, err := format(v)
+		if err != nil {
+			return nil, err
+		}
+		elems[i] = &str
+	}
+	return FormatArray([]ArrayDim{{Len: len(a), Lower: 1}}, elems, delim)
+}
+
+// Layouts of timestamptz, timestamp and date text.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999-07:00:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Parses the text form of a timestamptz, timestamp or date, the latter two
+// in UTC.
+func ParseTime(s string) (t time.Time, err error) {
+	for _, layout := range timeLayouts {
+		if t, err = time.Parse(layout, s); err == nil {
+			return
+		}
+	}
+	return t, fmt.Errorf("invalid time %q", s)
+}
+
+// A timestamptz[], timestamp[] or date[] with no NULL elements. Elements with
+// no time zone are in UTC.
+type TimeArray []time.Time
+
+func (a *TimeArray) Scan(src interface{}) (err error) {
+	*a, err = scanArray(src, ',', ParseTime)
+	return
+}
+
+func (a TimeArray) Value() (driver.Value, error) {
+	return arrayValue(a, ',', func(t time.Time) (string, error) {
+		return t.Format(time.RFC3339Nano), nil
+	})
+}
+
+// A uuid[] with no NULL elements.
+type UUIDArray []UUID
+
+func (a *UUIDArray) Scan(src interface{}) (err error) {
+	*a, err = scanArray(src, ',', ParseUUID)
+	return
+}
+
+func (a UUIDArray) Value() (driver.Value, error) {
+	return arrayValue(a, ',', func(u UUID) (string, error) {
+		return u.String(), nil
+	})
+}
+
+// A jsonb[] with no NULL elements, which may still be JSON nulls.
+type JsonbArray []JsonbValue
+
+func (a *JsonbArray) Scan(src interface{}) (err error) {
+	*a, err = scanArray(src, ',', func(s string) (j JsonbValue, err error) {
+		err = j.Scan(s)
+		return
+	})
+	return
+}
+
+func (a JsonbArray) Value() (driver.Value, error) {
+	return arrayValue(a, ',', func(j JsonbValue) (string, error) {
+		b, err := json.Marshal(j.V)
+		return string(b), err
+	})
+}
+
+// A geography(Point)[] with no NULL elements. PostGIS types separate array
+// elements with ':'.
+type PointArray []Point
+
+func (a *PointArray) Scan(src interface{}) (err error) {
+	*a, err = scanArray(src, ':', func(s string) (p Point, err error) {
+		err = p.Scan([]byte(s))
+		return
+	})
+	return
+}
+
+func (a PointArray) Value() (driver.Value, error) {
+	return arrayValue(a, ':', func(p Point) (string, error) {
+		v, err := p.Value()
+		if err != nil {
+			return "", err
+		}
+		return v.(string), nil
+	})
+}
+
+// A numeric[] with no NULL elements.
+type DecimalArray []Decimal
+
+func (a *DecimalArray) Scan(src interface{}) (err error) {
+	*a, err = scanArray(src, ',', ParseDecimal)
+	return
+}
+
+func (a DecimalArray) Value() (driver.Value, error) {
+	return arrayValue(a, ',', func(d Decimal) (string, error) {
+		return d.String(), nil
+	})
+}
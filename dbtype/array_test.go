@@ -0,0 +1,150 @@
+package dbtype
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func strs(elems ... interface{}) []*string {
+	res := make([]*string, len(elems))
+	for i, e := range elems {
+		if e != nil {
+			s := e.(string)
+			res[i] = &s
+		}
+	}
+	return res
+}
+
+func TestParseArray(t *testing.T) {
+	cases := []struct {
+		src   string
+		dims  []ArrayDim
+		elems []*string
+	}{
+		{"{}", nil, nil},
+		{" { } ", nil, nil},
+		{"{1,2,3}", []ArrayDim{{3, 1}}, strs("1", "2", "3")},
+		{"{ a b , NULL,null,\"NULL\",\"\"}", []ArrayDim{{5, 1}}, strs("a b", nil, nil, "NULL", "")},
+		{`{"a,b","c\"d","e\\f",g\,h,"{}"}`, []ArrayDim{{5, 1}}, strs("a,b", `c"d`, `e\f`, "g,h", "{}")},
+		{`{a\ }`, []ArrayDim{{1, 1}}, strs("a ")},
+		{"{{1,2},{3,NULL}}", []ArrayDim{{2, 1}, {2, 1}}, strs("1", "2", "3", nil)},
+		{"[0:1][-1:1]={{1,2,3},{4,5,6}}", []ArrayDim{{2, 0}, {3, -1}},
+			strs("1", "2", "3", "4", "5", "6")},
+	}
+	for _, c := range cases {
+		dims, elems, err := ParseArray(c.src, ',')
+		assert.NoError(t, err, c.src)
+		assert.Equal(t, c.dims, dims, c.src)
+		assert.Equal(t, c.elems, elems, c.src)
+	}
+
+	for _, src := range []string{"", "1,2", "{1,2", "{1,,2}", "{1,{2}}", "{{1},2}",
+		"{{1,2},{3}}", "{{}}", `{"a}`, `{a"b}`, "{1} 2", "[1:3]={1,2}", "[1:2]{1,2}"} {
+		_, _, err := ParseArray(src, ',')
+		assert.Error(t, err, src)
+	}
+
+	// Delimiters other than ','.
+	dims, elems, err := ParseArray("{a,b:c}", ':')
+	assert.NoError(t, err)
+	assert.Equal(t, []ArrayDim{{2, 1}}, dims)
+	assert.Equal(t, strs("a,b", "c"), elems)
+}
+
+func TestFormatArray(t *testing.T) {
+	cases := []struct {
+		dims  []ArrayDim
+		elems []*string
+		want  string
+	}{
+		{nil, nil, "{}"},
+		{[]ArrayDim{{0, 1}}, nil, "{}"},
+		{[]ArrayDim{{3, 1}}, strs("1", nil, "NULL"), `{1,NULL,"NULL"}`},
+		{[]ArrayDim{{4, 1}}, strs("", "a b", `c"d\`, "{x,y}"), `{"","a b","c\"d\\","{x,y}"}`},
+		{[]ArrayDim{{2, 1}, {2, 1}}, strs("1", "2", "3", "4"), "{{1,2},{3,4}}"},
+		{[]ArrayDim{{1, 0}, {2, 1}}, strs("1", "2"), "[0:0][1:2]={{1,2}}"},
+	}
+	for _, c := range cases {
+		str, err := FormatArray(c.dims, c.elems, ',')
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, str)
+		// Round trip.
+		dims, elems, err := ParseArray(str, ',')
+		assert.NoError(t, err)
+		assert.Equal(t, c.elems, elems, str)
+		if len(c.elems) > 0 {
+			assert.Equal(t, c.dims, dims)
+		}
+	}
+	_, err := FormatArray([]ArrayDim{{2, 1}}, strs("1"), ',')
+	assert.Error(t, err)
+}
+
+func TestTimeArray(t *testing.T) {
+	a := TimeArray{}
+	assert.NoError(t, a.Scan([]byte(`{"2020-01-02 03:04:05.123456+00","2020-01-02 03:04:05-05:30",2020-01-02}`)))
+	assert.Len(t, a, 3)
+	assert.True(t, a[0].Equal(time.Date(2020, 1, 2, 3, 4, 5, 123456000, time.UTC)))
+	assert.True(t, a[1].Equal(time.Date(2020, 1, 2, 8, 34, 5, 0, time.UTC)))
+	assert.True(t, a[2].Equal(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)))
+	v, err := a[:2].Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "{2020-01-02T03:04:05.123456Z,2020-01-02T03:04:05-05:30}", v)
+
+	assert.Error(t, a.Scan("{2020-01-02,NULL}"))
+	assert.Error(t, a.Scan("{yesterday}"))
+	assert.Error(t, a.Scan("{{2020-01-02}}"))
+}
+
+func TestUUIDArray(t *testing.T) {
+	a := UUIDArray{}
+	assert.NoError(t, a.Scan("{a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11,00000000-0000-0000-0000-000000000000}"))
+	assert.Len(t, a, 2)
+	assert.Equal(t, "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", a[0].String())
+	assert.Equal(t, UUID{}, a[1])
+	v, err := a.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "{a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11,00000000-0000-0000-0000-000000000000}", v)
+
+	v, err = UUIDArray(nil).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", v)
+}
+
+func TestJsonbArray(t *testing.T) {
+	a := JsonbArray{}
+	assert.Error(t, a.Scan(`{"{\"a\": 1}",NULL}`))
+	assert.NoError(t, a.Scan(`{"{\"a\": [1, \"x,y\"]}","null",3}`))
+	assert.Len(t, a, 3)
+	assert.Equal(t, map[string]interface{}{"a": []interface{}{json.Number("1"), "x,y"}}, a[0].V)
+	assert.Nil(t, a[1].V)
+	assert.Equal(t, json.Number("3"), a[2].V)
+	v, err := a.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"{\"a\":[1,\"x,y\"]}","null",3}`, v)
+}
+
+func TestPointArray(t *testing.T) {
+	// EWKB of SRID=4326;POINT(1 2), as output by PostGIS.
+	const hex = "0101000020E6100000000000000000F03F0000000000000040"
+	a := PointArray{}
+	assert.NoError(t, a.Scan("{" + hex + ":" + hex + "}"))
+	assert.Equal(t, PointArray{{Lat: 2, Long: 1, SRID: 4326}, {Lat: 2, Long: 1, SRID: 4326}}, a)
+	v, err := a.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"SRID=4326;POINT(1 2)":"SRID=4326;POINT(1 2)"}`, v)
+}
+
+func TestDecimalArray(t *testing.T) {
+	a := DecimalArray{}
+	assert.NoError(t, a.Scan("{1.50,-2,12345678901234567890.123456789}"))
+	assert.Len(t, a, 3)
+	assert.Equal(t, "1.50", a[0].String())
+	v, err := a.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "{1.50,-2,12345678901234567890.123456789}", v)
+	assert.Error(t, a.Scan("{1,x}"))
+}
@@ -0,0 +1,74 @@
+package dbtype
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// Date is a date column: a day with no time of day nor time zone.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// Returns the date of t in its own location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// Parses a date in the ISO format, e.g. 2020-01-31.
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q", s)
+	}
+	return DateOf(t), nil
+}
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Returns the start of the day in loc.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+func (d Date) AddDays(n int) Date {
+	return DateOf(d.In(time.UTC).AddDate(0, 0, n))
+}
+
+// Returns -1, 0 or 1 whether d is before, the same as, or after other.
+func (d Date) Compare(other Date) int {
+	return d.In(time.UTC).Compare(other.In(time.UTC))
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Date) Scan(src interface{}) (err error) {
+	switch val := src.(type) {
+	case time.Time:
+		*d = DateOf(val)
+	case string:
+		*d, err = ParseDate(val)
+	case []byte:
+		*d, err = ParseDate(string(val))
+	default:
+		return fmt.Errorf("cannot scan %T into a Date", src)
+	}
+	return
+}
+
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Date) UnmarshalText(data []byte) (err error) {
+	*d, err = ParseDate(string(data))
+	return
+}
@@ -0,0 +1,33 @@
+package dbtype
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDate(t *testing.T) {
+	d, err := ParseDate("2020-02-28")
+	assert.NoError(t, err)
+	assert.Equal(t, Date{2020, time.February, 28}, d)
+	assert.Equal(t, "2020-02-29", d.AddDays(1).String())
+	assert.Equal(t, "2020-03-01", d.AddDays(2).String())
+	assert.Equal(t, -1, d.Compare(d.AddDays(1)))
+	assert.Equal(t, 0, d.Compare(d))
+	_, err = ParseDate("2020-02-30")
+	assert.Error(t, err)
+
+	assert.NoError(t, d.Scan(time.Date(2021, 5, 6, 23, 0, 0, 0, time.FixedZone("", -3600))))
+	assert.Equal(t, Date{2021, time.May, 6}, d)
+	assert.NoError(t, d.Scan([]byte("2021-05-07")))
+	v, err := d.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "2021-05-07", v)
+
+	data, err := json.Marshal(d)
+	assert.NoError(t, err)
+	assert.Equal(t, `"2021-05-07"`, string(data))
+	assert.NoError(t, json.Unmarshal([]byte(`"2021-01-01"`), &d))
+	assert.Equal(t, Date{2021, time.January, 1}, d)
+}
@@ -0,0 +1,159 @@
+package dbtype
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number, as numeric columns hold, i.e. an
+// arbitrary precision integer scaled by a power of ten. The zero value is 0.
+// NaN and infinities are not supported.
+type Decimal struct {
+	coef  *big.Int // nil for 0.
+	scale int32    // Number of digits after the decimal point, at least 0.
+}
+
+// Limits of numeric values in Postgres, which ParseDecimal enforces, so that
+// exponents can't make it build huge numbers.
+const (
+	maxDecimalIntDigits = 131072 // Before the decimal point.
+	maxDecimalScale     = 16383  // After it.
+)
+
+// Returns unscaled * 10^-scale, e.g. NewDecimal(150, 2) is 1.50.
+func NewDecimal(unscaled int64, scale int32) Decimal {
+	if scale < 0 {
+		d := Decimal{coef: big.NewInt(unscaled)}
+		d.coef.Mul(d.coef, pow10(-scale))
+		return d
+	}
+	return Decimal{coef: big.NewInt(unscaled), scale: scale}
+}
+
+// Parses a number as Postgres writes numeric values, or in scientific
+// notation, e.g. "-12.50" or "1.5e3". Trailing zeros after the decimal point
+// are kept, as Postgres does. Numbers beyond the limits of Postgres, i.e.
+// 131072 digits before the decimal point and 16383 after it, are errors.
+func ParseDecimal(s string) (Decimal, error) {
+	str := strings.TrimSpace(s)
+	var exp int64
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		var err error
+		if exp, err = strconv.ParseInt(str[i+1:], 10, 32); err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		str = str[:i]
+	}
+	neg := strings.HasPrefix(str, "-")
+	str = strings.TrimPrefix(strings.TrimPrefix(str, "-"), "+")
+	intPart, frac := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, frac = str[:i], str[i+1:]
+	}
+	digits := intPart + frac
+	if digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	scale := int64(len(frac)) - exp
+	// Checked before scaling, which takes time growing with the exponent.
+	if sig := int64(len(strings.TrimLeft(digits, "0"))); sig > 0 && sig-scale > maxDecimalIntDigits ||
+		scale > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal %q out of range", s)
+	}
+	coef, _ := new(big.Int).SetString(digits, 10)
+	if neg {
+		coef.Neg(coef)
+	}
+	if scale < 0 {
+		if coef.Sign() != 0 {
+			coef.Mul(coef, pow10(int32(-scale)))
+		}
+		scale = 0
+	}
+	return Decimal{coef: coef, scale: int32(scale)}, nil
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) String() string {
+	if d.coef == nil {
+		return "0"
+	}
+	digits := new(big.Int).Abs(d.coef).String()
+	sign := ""
+	if d.coef.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if n := int(d.scale) + 1 - len(digits); n > 0 {
+		digits = strings.Repeat("0", n) + digits
+	}
+	i := len(digits) - int(d.scale)
+	return sign + digits[:i] + "." + digits[i:]
+}
+
+// Returns the number of digits after the decimal point.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Returns d as an exact fraction.
+func (d Decimal) Rat() *big.Rat {
+	if d.coef == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFrac(d.coef, pow10(d.scale))
+}
+
+// Returns the float64 nearest to d, and whether it is exact.
+func (d Decimal) Float64() (float64, bool) {
+	return d.Rat().Float64()
+}
+
+// Compares d and other by value, regardless of their scales: 1.5 equals 1.50.
+func (d Decimal) Cmp(other Decimal) int {
+	return d.Rat().Cmp(other.Rat())
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Decimal) Scan(src interface{}) (err error) {
+	switch val := src.(type) {
+	case string:
+		*d, err = ParseDecimal(val)
+	case []byte:
+		*d, err = ParseDecimal(string(val))
+	case int64:
+		*d = NewDecimal(val, 0)
+	case float64:
+		*d, err = ParseDecimal(strconv.FormatFloat(val, 'f', -1, 64))
+	default:
+		return fmt.Errorf("cannot scan %T into a Decimal", src)
+	}
+	return
+}
+
+// Decimals are JSON numbers, written with all their digits.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Accepts numbers, as well as strings for the sake of clients that can't
+// handle large numbers.
+func (d *Decimal) UnmarshalJSON(data []byte) (err error) {
+	str := string(data)
+	if unquoted, e := strconv.Unquote(str); e == nil {
+		str = unquoted
+	}
+	*d, err = ParseDecimal(str)
+	return
+}
@@ -0,0 +1,66 @@
+package dbtype
+
+import (
+	"encoding/json"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDecimal(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"0", "0"},
+		{"1.50", "1.50"},
+		{"-0.001", "-0.001"},
+		{".5", "0.5"},
+		{"+7.", "7"},
+		{"1.5e3", "1500"},
+		{"15E-3", "0.015"},
+		{"123456789012345678901234567890.123456789", "123456789012345678901234567890.123456789"},
+	}
+	for _, c := range cases {
+		d, err := ParseDecimal(c.src)
+		if assert.NoError(t, err, c.src) {
+			assert.Equal(t, c.want, d.String(), c.src)
+		}
+	}
+	for _, src := range []string{"", ".", "1.2.3", "1e", "NaN", "Infinity", "1,5", "--1"} {
+		_, err := ParseDecimal(src)
+		assert.Error(t, err, src)
+	}
+
+	assert.Equal(t, "1.50", NewDecimal(150, 2).String())
+	assert.Equal(t, "1500", NewDecimal(15, -2).String())
+	assert.Equal(t, "0", Decimal{}.String())
+	assert.Equal(t, 0, NewDecimal(150, 2).Cmp(NewDecimal(15, 1)))
+	assert.Equal(t, -1, NewDecimal(-1, 0).Cmp(Decimal{}))
+	f, exact := NewDecimal(15, 1).Float64()
+	assert.Equal(t, 1.5, f)
+	assert.True(t, exact)
+	_, exact = NewDecimal(1, 1).Float64()
+	assert.False(t, exact)
+}
+
+func TestDecimal_Scan(t *testing.T) {
+	d := Decimal{}
+	assert.NoError(t, d.Scan([]byte("12345678901234567890.01")))
+	v, err := d.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "12345678901234567890.01", v)
+	assert.NoError(t, d.Scan(int64(-3)))
+	assert.Equal(t, "-3", d.String())
+	assert.NoError(t, d.Scan(0.25))
+	assert.Equal(t, "0.25", d.String())
+	assert.Error(t, d.Scan(true))
+
+	s := struct{ A, B Decimal }{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"A":0.10000000000000000001,"B":"2.50"}`), &s))
+	assert.Equal(t, "0.10000000000000000001", s.A.String())
+	assert.Equal(t, "2.50", s.B.String())
+	data, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"A":0.10000000000000000001,"B":2.50}`, string(data))
+	assert.Error(t, json.Unmarshal([]byte(`{"A":true}`), &s))
+}
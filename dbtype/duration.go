@@ -0,0 +1,134 @@
+package dbtype
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is an interval column of no months, e.g. a timeout, as a
+// time.Duration. Days are taken to be 24 hours, and precision is limited to
+// microseconds, as in Postgres.
+type Duration time.Duration
+
+// Writes d as [-]h:mm:ss[.ffffff], which Postgres reads as an interval of
+// hours, minutes and seconds only.
+func (d Duration) String() string {
+	us := time.Duration(d).Round(time.Microsecond).Microseconds()
+	sign := ""
+	if us < 0 {
+		sign, us = "-", -us
+	}
+	secs := us / 1e6
+	res := fmt.Sprintf("%s%d:%02d:%02d", sign, secs/3600, secs/60%60, secs%60)
+	if frac := us % 1e6; frac != 0 {
+		res += strings.TrimRight(fmt.Sprintf(".%06d", frac), "0")
+	}
+	return res
+}
+
+// Parses intervals as Postgres writes them with the default IntervalStyle,
+// e.g. "1 day -02:03:04.5", failing for those with months or years.
+func ParseDuration(s string) (Duration, error) {
+	fields := strings.Fields(s)
+	var res time.Duration
+	invalid := fmt.Errorf("invalid interval %q", s)
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if strings.IndexByte(f, ':') >= 0 {
+			t, err := parseClock(f)
+			if err != nil {
+				return 0, invalid
+			}
+			res += t
+			continue
+		}
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil || i+1 == len(fields) {
+			return 0, invalid
+		}
+		i++
+		switch strings.TrimSuffix(fields[i], "s") {
+		case "day":
+			res += time.Duration(n) * 24 * time.Hour
+		case "mon", "year":
+			return 0, fmt.Errorf("interval %q has months, which have no fixed duration", s)
+		default:
+			return 0, invalid
+		}
+	}
+	if len(fields) == 0 {
+		return 0, invalid
+	}
+	return Duration(res), nil
+}
+
+// Parses [+-]h:mm[:ss[.ffffff]].
+func parseClock(s string) (time.Duration, error) {
+	neg := strings.HasPrefix(s, "-")
+	parts := strings.Split(strings.TrimLeft(s, "+-"), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	var res time.Duration
+	units := []time.Duration{time.Hour, time.Minute}
+	for i, p := range parts {
+		if i == 2 {
+			secs, err := strconv.ParseFloat(p, 64)
+			if err != nil || strings.ContainsAny(p, "eE+-") {
+				return 0, fmt.Errorf("invalid time %q", s)
+			}
+			res += time.Duration(secs*1e6+0.5) * time.Microsecond
+			continue
+		}
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		res += time.Duration(n) * units[i]
+	}
+	if neg {
+		res = -res
+	}
+	return res, nil
+}
+
+func (d Duration) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) Scan(src interface{}) (err error) {
+	switch val := src.(type) {
+	case string:
+		*d, err = ParseDuration(val)
+	case []byte:
+		*d, err = ParseDuration(string(val))
+	default:
+		return fmt.Errorf("cannot scan %T into a Duration", src)
+	}
+	return
+}
+
+// Durations are written as by time.Duration.String, e.g. "1h30m0s".
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Accepts strings as time.ParseDuration does, or numbers of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		var ns int64
+		if err = json.Unmarshal(data, &ns); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(ns)
+		return nil
+	}
+	res, err := time.ParseDuration(str)
+	*d = Duration(res)
+	return err
+}
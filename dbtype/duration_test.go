@@ -0,0 +1,72 @@
+package dbtype
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	cases := []struct {
+		src  string
+		want time.Duration
+	}{
+		{"00:00:00", 0},
+		{"01:02:03", time.Hour + 2*time.Minute + 3*time.Second},
+		{"-00:00:00.5", -500 * time.Millisecond},
+		{"00:00:00.000001", time.Microsecond},
+		{"49:00:00", 49 * time.Hour},
+		{"1 day", 24 * time.Hour},
+		{"3 days 01:00:00", 73 * time.Hour},
+		{"-1 days +02:00:00", -22 * time.Hour},
+		{"1 day -00:30", 23*time.Hour + 30*time.Minute},
+	}
+	for _, c := range cases {
+		d, err := ParseDuration(c.src)
+		assert.NoError(t, err, c.src)
+		assert.Equal(t, Duration(c.want), d, c.src)
+	}
+	for _, src := range []string{"", "1", "1 week", "1 mon", "2 years 01:00:00", "1:2:3:4",
+		"00:00:1e3", "x:00:00"} {
+		_, err := ParseDuration(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	cases := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0:00:00"},
+		{90 * time.Minute, "1:30:00"},
+		{-1500 * time.Millisecond, "-0:00:01.5"},
+		{49*time.Hour + 1, "49:00:00"},
+		{1500, "0:00:00.000002"},
+	}
+	for _, c := range cases {
+		v, err := Duration(c.d).Value()
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, v)
+		if c.d%time.Microsecond == 0 {
+			d, err := ParseDuration(c.want)
+			assert.NoError(t, err)
+			assert.Equal(t, Duration(c.d), d)
+		}
+	}
+
+	d := Duration(0)
+	assert.NoError(t, d.Scan([]byte("1 day 00:00:01")))
+	assert.Equal(t, Duration(24*time.Hour+time.Second), d)
+	assert.Error(t, d.Scan(int64(1)))
+
+	s := struct{ A, B Duration }{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"A":"1h30m","B":1000}`), &s))
+	assert.Equal(t, Duration(90*time.Minute), s.A)
+	assert.Equal(t, Duration(time.Microsecond), s.B)
+	data, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"A":"1h30m0s","B":"1µs"}`, string(data))
+	assert.Error(t, json.Unmarshal([]byte(`{"A":"1 day"}`), &s))
+}
@@ -0,0 +1,221 @@
+// Package geom reads PostGIS geometries and geographies from (E)WKB, and
+// writes them as (E)WKT.
+package geom
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Type is the type of a geometry, as its WKB code.
+type Type uint32
+
+const (
+	PointType              Type = 1
+	LineStringType         Type = 2
+	PolygonType            Type = 3
+	MultiPointType         Type = 4
+	MultiLineStringType    Type = 5
+	MultiPolygonType       Type = 6
+	GeometryCollectionType Type = 7
+)
+
+var typeNames = map[Type]string{
+	PointType: "POINT", LineStringType: "LINESTRING", PolygonType: "POLYGON",
+	MultiPointType: "MULTIPOINT", MultiLineStringType: "MULTILINESTRING",
+	MultiPolygonType: "MULTIPOLYGON", GeometryCollectionType: "GEOMETRYCOLLECTION",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Type(%d)", uint32(t))
+}
+
+// Layout tells which of Z and M coordinates geometries have.
+type Layout uint8
+
+const (
+	XY   Layout = iota
+	XYZ  Layout = iota
+	XYM  Layout = iota
+	XYZM Layout = iota
+)
+
+func (l Layout) HasZ() bool {
+	return l == XYZ || l == XYZM
+}
+
+func (l Layout) HasM() bool {
+	return l == XYM || l == XYZM
+}
+
+// Coord is a position. Z and M are 0 unless the layout has them.
+type Coord struct {
+	X float64
+	Y float64
+	Z float64
+	M float64
+}
+
+// Header is what all geometries have.
+type Header struct {
+	Layout Layout
+	// Spatial reference ID, 0 if unknown.
+	SRID int
+}
+
+func (h Header) Head() Header {
+	return h
+}
+
+// Geometry is any of the geometries of this package.
+type Geometry interface {
+	Head() Header
+	GeometryType() Type
+	// Writes the WKT of the geometry, without its SRID.
+	writeWKT(w *wktWriter)
+}
+
+// Point is a position, or POINT EMPTY, which is NaN in WKB.
+type Point struct {
+	Header
+	Coord
+	Empty bool
+}
+
+type LineString struct {
+	Header
+	Coords []Coord
+}
+
+// Polygon is an outer ring followed by holes, each closed.
+type Polygon struct {
+	Header
+	Rings [][]Coord
+}
+
+type MultiPoint struct {
+	Header
+	Points []Coord
+}
+
+type MultiLineString struct {
+	Header
+	Lines [][]Coord
+}
+
+type MultiPolygon struct {
+	Header
+	Polygons [][][]Coord
+}
+
+type GeometryCollection struct {
+	Header
+	Geometries []Geometry
+}
+
+func (Point) GeometryType() Type { return PointType }
+
+func (LineString) GeometryType() Type { return LineStringType }
+
+func (Polygon) GeometryType() Type { return PolygonType }
+
+func (MultiPoint) GeometryType() Type { return MultiPointType }
+
+func (MultiLineString) GeometryType() Type { return MultiLineStringType }
+
+func (MultiPolygon) GeometryType() Type { return MultiPolygonType }
+
+func (GeometryCollection) GeometryType() Type { return GeometryCollectionType }
+
+// Reads a geometry of the same type as *dst from src, binary or hex (E)WKB.
+func scan[T Geometry](dst *T, src interface{}) error {
+	g, err := decodeSrc(src)
+	if err != nil {
+		return err
+	}
+	res, ok := g.(T)
+	if !ok {
+		var zero T
+		return fmt.Errorf("cannot scan a %s into a %s", g.GeometryType(), zero.GeometryType())
+	}
+	*dst = res
+	return nil
+}
+
+func decodeSrc(src interface{}) (Geometry, error) {
+	switch val := src.(type) {
+	case []byte:
+		// Binary results start with their byte order, hex ones with a
+		// digit.
+		if len(val) > 0 && val[0] <= 1 {
+			return Decode(val)
+		}
+		return DecodeHex(string(val))
+	case string:
+		return DecodeHex(val)
+	}
+	return nil, fmt.Errorf("cannot scan %T into a geometry", src)
+}
+
+func (p *Point) Scan(src interface{}) error { return scan(p, src) }
+
+func (l *LineString) Scan(src interface{}) error { return scan(l, src) }
+
+func (p *Polygon) Scan(src interface{}) error { return scan(p, src) }
+
+func (m *MultiPoint) Scan(src interface{}) error { return scan(m, src) }
+
+func (m *MultiLineString) Scan(src interface{}) error { return scan(m, src) }
+
+func (m *MultiPolygon) Scan(src interface{}) error { return scan(m, src) }
+
+func (c *GeometryCollection) Scan(src interface{}) error { return scan(c, src) }
+
+// Geometries are written as EWKT.
+func (p Point) Value() (driver.Value, error) { return EWKT(p), nil }
+
+func (l LineString) Value() (driver.Value, error) { return EWKT(l), nil }
+
+func (p Polygon) Value() (driver.Value, error) { return EWKT(p), nil }
+
+func (m MultiPoint) Value() (driver.Value, error) { return EWKT(m), nil }
+
+func (m MultiLineString) Value() (driver.Value, error) { return EWKT(m), nil }
+
+func (m MultiPolygon) Value() (driver.Value, error) { return EWKT(m), nil }
+
+func (c GeometryCollection) Value() (driver.Value, error) { return EWKT(c), nil }
+
+func (p Point) String() string { return EWKT(p) }
+
+func (l LineString) String() string { return EWKT(l) }
+
+func (p Polygon) String() string { return EWKT(p) }
+
+func (m MultiPoint) String() string { return EWKT(m) }
+
+func (m MultiLineString) String() string { return EWKT(m) }
+
+func (m MultiPolygon) String() string { return EWKT(m) }
+
+func (c GeometryCollection) String() string { return EWKT(c) }
+
+// Any is a geometry of any type, e.g. of a plain geometry column.
+type Any struct {
+	Geometry Geometry
+}
+
+func (a *Any) Scan(src interface{}) (err error) {
+	a.Geometry, err = decodeSrc(src)
+	return
+}
+
+func (a Any) Value() (driver.Value, error) {
+	if a.Geometry == nil {
+		return nil, fmt.Errorf("cannot write a nil geometry, use null.Geometry")
+	}
+	return EWKT(a.Geometry), nil
+}
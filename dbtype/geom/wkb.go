@@ -0,0 +1,232 @@
+package geom
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+)
+
+// Flags of EWKB geometry types.
+const (
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// Reads a geometry from WKB, ISO WKB with Z and M as types 1000 to 3000 and
+// up, or PostGIS' EWKB with its Z, M and SRID flags.
+// Reference:
+// https://en.wikipedia.org/wiki/Well-known_text#Well-known_binary
+func Decode(data []byte) (Geometry, error) {
+	d := decoder{data: data}
+	g, err := d.geometry(nil)
+	if err != nil {
+		return nil, err
+	} else if d.pos != len(data) {
+		return nil, fmt.Errorf("invalid WKB: %d bytes left", len(data)-d.pos)
+	}
+	return g, nil
+}
+
+// Reads a geometry from hex (E)WKB, as geometries are output in text.
+func DecodeHex(s string) (Geometry, error) {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex WKB: %v", err)
+	}
+	return Decode(data)
+}
+
+type decoder struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+var errShort = fmt.Errorf("invalid WKB: unexpected end of data")
+
+func (d *decoder) uint32() (uint32, error) {
+	if len(d.data)-d.pos < 4 {
+		return 0, errShort
+	}
+	v := d.order.Uint32(d.data[d.pos:])
+	d.pos += 4
+	return v, nil
+}
+
+func (d *decoder) float64() (float64, error) {
+	if len(d.data)-d.pos < 8 {
+		return 0, errShort
+	}
+	v := math.Float64frombits(d.order.Uint64(d.data[d.pos:]))
+	d.pos += 8
+	return v, nil
+}
+
+func (d *decoder) coord(layout Layout) (c Coord, err error) {
+	if c.X, err = d.float64(); err != nil {
+		return
+	} else if c.Y, err = d.float64(); err != nil {
+		return
+	}
+	if layout.HasZ() {
+		if c.Z, err = d.float64(); err != nil {
+			return
+		}
+	}
+	if layout.HasM() {
+		c.M, err = d.float64()
+	}
+	return
+}
+
+// Reads a count of elements of at least size bytes each.
+func (d *decoder) count(size int) (int, error) {
+	n, err := d.uint32()
+	if err != nil {
+		return 0, err
+	} else if int64(n)*int64(size) > int64(len(d.data)-d.pos) {
+		return 0, errShort
+	}
+	return int(n), nil
+}
+
+func (d *decoder) coords(layout Layout) ([]Coord, error) {
+	n, err := d.count(16)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Coord, n)
+	for i := range res {
+		if res[i], err = d.coord(layout); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (d *decoder) rings(layout Layout) ([][]Coord, error) {
+	n, err := d.count(4)
+	if err != nil {
+		return nil, err
+	}
+	res := make([][]Coord, n)
+	for i := range res {
+		if res[i], err = d.coords(layout); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Reads a geometry and its header. Geometries within others, of parent, have
+// the layout and SRID of their parent.
+func (d *decoder) geometry(parent *Header) (Geometry, error) {
+	if d.pos == len(d.data) {
+		return nil, errShort
+	}
+	switch d.data[d.pos] {
+	case 0:
+		d.order = binary.BigEndian
+	case 1:
+		d.order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("invalid WKB byte order %d", d.data[d.pos])
+	}
+	d.pos++
+	code, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	h := Header{}
+	z, m := code&ewkbZ != 0, code&ewkbM != 0
+	if code&ewkbSRID != 0 {
+		srid, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		h.SRID = int(srid)
+	}
+	code &^= ewkbZ | ewkbM | ewkbSRID
+	switch code / 1000 {
+	case 1:
+		z = true
+	case 2:
+		m = true
+	case 3:
+		z, m = true, true
+	}
+	switch {
+	case z && m:
+		h.Layout = XYZM
+	case z:
+		h.Layout = XYZ
+	case m:
+		h.Layout = XYM
+	}
+	if parent != nil {
+		if h.Layout != parent.Layout {
+			return nil, fmt.Errorf("invalid WKB: geometry of layout %d within one of layout %d", h.Layout, parent.Layout)
+		}
+		h.SRID = parent.SRID
+	}
+
+	switch typ := Type(code % 1000); typ {
+	case PointType:
+		c, err := d.coord(h.Layout)
+		if err != nil {
+			return nil, err
+		}
+		return Point{Header: h, Coord: c, Empty: math.IsNaN(c.X) && math.IsNaN(c.Y)}, nil
+	case LineStringType:
+		coords, err := d.coords(h.Layout)
+		return LineString{Header: h, Coords: coords}, err
+	case PolygonType:
+		rings, err := d.rings(h.Layout)
+		return Polygon{Header: h, Rings: rings}, err
+	case MultiPointType, MultiLineStringType, MultiPolygonType, GeometryCollectionType:
+		n, err := d.count(5)
+		if err != nil {
+			return nil, err
+		}
+		subs := make([]Geometry, n)
+		for i := range subs {
+			if subs[i], err = d.geometry(&h); err != nil {
+				return nil, err
+			}
+			if typ != GeometryCollectionType && subs[i].GeometryType() != typ-3 {
+				return nil, fmt.Errorf("invalid WKB: %s within a %s", subs[i].GeometryType(), typ)
+			}
+		}
+		return collect(h, typ, subs), nil
+	default:
+		return nil, fmt.Errorf("invalid WKB geometry type %d", code)
+	}
+}
+
+// Returns the geometry of type typ made of subs, which are of the type of its
+// elements.
+func collect(h Header, typ Type, subs []Geometry) Geometry {
+	switch typ {
+	case MultiPointType:
+		res := MultiPoint{Header: h, Points: make([]Coord, len(subs))}
+		for i, sub := range subs {
+			res.Points[i] = sub.(Point).Coord
+		}
+		return res
+	case MultiLineStringType:
+		res := MultiLineString{Header: h, Lines: make([][]Coord, len(subs))}
+		for i, sub := range subs {
+			res.Lines[i] = sub.(LineString).Coords
+		}
+		return res
+	case MultiPolygonType:
+		res := MultiPolygon{Header: h, Polygons: make([][][]Coord, len(subs))}
+		for i, sub := range subs {
+			res.Polygons[i] = sub.(Polygon).Rings
+		}
+		return res
+	}
+	return GeometryCollection{Header: h, Geometries: subs}
+}
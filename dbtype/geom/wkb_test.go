@@ -0,0 +1,145 @@
+package geom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns the WKB of a geometry in little-endian order, values being uint32s,
+// float64s, or the WKB of other geometries.
+func wkb(values ... interface{}) []byte {
+	return wkbOrder(binary.LittleEndian, values...)
+}
+
+func wkbOrder(order binary.ByteOrder, values ... interface{}) []byte {
+	buf := bytes.Buffer{}
+	if order == binary.LittleEndian {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	for _, v := range values {
+		switch val := v.(type) {
+		case int:
+			binary.Write(&buf, order, uint32(val))
+		case []byte:
+			buf.Write(val)
+		default:
+			binary.Write(&buf, order, val)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	square := []Coord{{0, 0, 0, 0}, {4, 0, 0, 0}, {4, 4, 0, 0}, {0, 0, 0, 0}}
+	cases := []struct {
+		data []byte
+		want Geometry
+		ewkt string
+	}{
+		{wkb(1, 1.0, 2.0), Point{Coord: Coord{X: 1, Y: 2}}, "POINT(1 2)"},
+		{wkbOrder(binary.BigEndian, 1, 1.0, 2.0), Point{Coord: Coord{X: 1, Y: 2}}, "POINT(1 2)"},
+		{wkb(ewkbSRID|1, 4326, 1.0, 2.0), Point{Header{XY, 4326}, Coord{X: 1, Y: 2}, false},
+			"SRID=4326;POINT(1 2)"},
+		{wkb(ewkbZ|ewkbSRID|1, 4326, 1.0, 2.0, 3.0), Point{Header{XYZ, 4326}, Coord{1, 2, 3, 0}, false},
+			"SRID=4326;POINT Z (1 2 3)"},
+		{wkb(ewkbM|1, 1.0, 2.0, 4.0), Point{Header{XYM, 0}, Coord{1, 2, 0, 4}, false}, "POINT M (1 2 4)"},
+		{wkb(3001, 1.0, 2.0, 3.0, 4.0), Point{Header{XYZM, 0}, Coord{1, 2, 3, 4}, false},
+			"POINT ZM (1 2 3 4)"},
+		{wkb(1, math.NaN(), math.NaN()), nil, "POINT EMPTY"},
+		{wkb(ewkbSRID|2, 3857, 2, 0.0, 0.0, 1.5, -1.0),
+			LineString{Header{XY, 3857}, []Coord{{0, 0, 0, 0}, {1.5, -1, 0, 0}}},
+			"SRID=3857;LINESTRING(0 0,1.5 -1)"},
+		{wkb(2, 0), LineString{Coords: []Coord{}}, "LINESTRING EMPTY"},
+		{wkb(3, 2, 4, 0.0, 0.0, 4.0, 0.0, 4.0, 4.0, 0.0, 0.0, 4, 1.0, 1.0, 2.0, 1.0, 2.0, 2.0, 1.0, 1.0),
+			Polygon{Rings: [][]Coord{square, {{1, 1, 0, 0}, {2, 1, 0, 0}, {2, 2, 0, 0}, {1, 1, 0, 0}}}},
+			"POLYGON((0 0,4 0,4 4,0 0),(1 1,2 1,2 2,1 1))"},
+		// Elements may have a byte order of their own.
+		{wkb(ewkbSRID|4, 4326, 2, wkb(1, 1.0, 2.0), wkbOrder(binary.BigEndian, 1, 3.0, 4.0)),
+			MultiPoint{Header{XY, 4326}, []Coord{{1, 2, 0, 0}, {3, 4, 0, 0}}},
+			"SRID=4326;MULTIPOINT((1 2),(3 4))"},
+		{wkb(5, 2, wkb(2, 2, 0.0, 0.0, 1.0, 1.0), wkb(2, 2, 2.0, 2.0, 3.0, 3.0)),
+			MultiLineString{Lines: [][]Coord{{{0, 0, 0, 0}, {1, 1, 0, 0}}, {{2, 2, 0, 0}, {3, 3, 0, 0}}}},
+			"MULTILINESTRING((0 0,1 1),(2 2,3 3))"},
+		{wkb(1006, 1, wkb(1003, 1, 4, 0.0, 0.0, 1.0, 1.0, 0.0, 1.0, 1.0, 1.0, 1.0, 0.0, 0.0, 1.0)),
+			MultiPolygon{Header{XYZ, 0}, [][][]Coord{{{{0, 0, 1, 0}, {1, 0, 1, 0}, {1, 1, 1, 0}, {0, 0, 1, 0}}}}},
+			"MULTIPOLYGON Z (((0 0 1,1 0 1,1 1 1,0 0 1)))"},
+		{wkb(ewkbSRID|7, 4326, 2, wkb(1, 1.0, 2.0), wkb(2, 2, 0.0, 0.0, 1.0, 1.0)),
+			GeometryCollection{Header{XY, 4326}, []Geometry{
+				Point{Header{XY, 4326}, Coord{X: 1, Y: 2}, false},
+				LineString{Header{XY, 4326}, []Coord{{0, 0, 0, 0}, {1, 1, 0, 0}}},
+			}},
+			"SRID=4326;GEOMETRYCOLLECTION(POINT(1 2),LINESTRING(0 0,1 1))"},
+		{wkb(7, 0), GeometryCollection{Geometries: []Geometry{}}, "GEOMETRYCOLLECTION EMPTY"},
+	}
+	for _, c := range cases {
+		g, err := Decode(c.data)
+		assert.NoError(t, err, c.ewkt)
+		if c.want != nil {
+			assert.Equal(t, c.want, g)
+		}
+		assert.Equal(t, c.ewkt, EWKT(g))
+
+		// Hex, as geometries are output in text.
+		g, err = DecodeHex(hex.EncodeToString(c.data))
+		assert.NoError(t, err, c.ewkt)
+		assert.Equal(t, c.ewkt, EWKT(g))
+	}
+
+	p, err := Decode(wkb(ewkbZ|ewkbSRID|1, 4326, 1.0, 2.0, 3.0))
+	assert.NoError(t, err)
+	assert.Equal(t, "POINT Z (1 2 3)", WKT(p))
+	assert.Equal(t, "POINT Z EMPTY", WKT(Point{Header: Header{Layout: XYZ}, Empty: true}))
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	cases := [][]byte{
+		nil,
+		{2, 1, 0, 0, 0},
+		wkb(1, 1.0),
+		wkb(1, 1.0, 2.0, 3.0),
+		wkb(8, 0),
+		wkb(2, 0xfffffff0),
+		// Elements of the wrong type or layout.
+		wkb(4, 1, wkb(2, 0)),
+		wkb(4, 1, wkb(1001, 1.0, 2.0, 3.0)),
+		wkb(7, 1),
+	}
+	for i, data := range cases {
+		_, err := Decode(data)
+		assert.Error(t, err, i)
+	}
+	_, err := DecodeHex("0101x")
+	assert.Error(t, err)
+}
+
+func TestScan(t *testing.T) {
+	data := wkb(ewkbSRID|1, 4326, 1.0, 2.0)
+	p := Point{}
+	assert.NoError(t, p.Scan(data))
+	assert.Equal(t, Point{Header{XY, 4326}, Coord{X: 1, Y: 2}, false}, p)
+	assert.NoError(t, p.Scan([]byte(hex.EncodeToString(data))))
+	assert.NoError(t, p.Scan(hex.EncodeToString(data)))
+	v, err := p.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "SRID=4326;POINT(1 2)", v)
+	assert.Equal(t, "SRID=4326;POINT(1 2)", p.String())
+
+	l := LineString{}
+	assert.Error(t, l.Scan(data))
+	assert.Error(t, p.Scan(1))
+
+	a := Any{}
+	assert.NoError(t, a.Scan(wkb(2, 2, 0.0, 0.0, 1.0, 1.0)))
+	assert.Equal(t, LineStringType, a.Geometry.GeometryType())
+	v, err = a.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "LINESTRING(0 0,1 1)", v)
+	_, err = Any{}.Value()
+	assert.Error(t, err)
+}
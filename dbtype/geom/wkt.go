@@ -0,0 +1,152 @@
+package geom
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Returns the WKT of g, e.g. POINT Z (1 2 3), without its SRID.
+func WKT(g Geometry) string {
+	w := wktWriter{}
+	g.writeWKT(&w)
+	return w.buf.String()
+}
+
+// Returns the EWKT of g, e.g. SRID=4326;POINT(1 2), which PostGIS reads as
+// geometries and geographies.
+func EWKT(g Geometry) string {
+	if srid := g.Head().SRID; srid != 0 {
+		return "SRID=" + strconv.Itoa(srid) + ";" + WKT(g)
+	}
+	return WKT(g)
+}
+
+type wktWriter struct {
+	buf bytes.Buffer
+}
+
+// Writes the type of a geometry, and EMPTY if it is.
+func (w *wktWriter) tag(typ Type, layout Layout, empty bool) bool {
+	w.buf.WriteString(typ.String())
+	switch layout {
+	case XYZ:
+		w.buf.WriteString(" Z ")
+	case XYM:
+		w.buf.WriteString(" M ")
+	case XYZM:
+		w.buf.WriteString(" ZM ")
+	}
+	if empty {
+		if layout == XY {
+			w.buf.WriteByte(' ')
+		}
+		w.buf.WriteString("EMPTY")
+	}
+	return !empty
+}
+
+func (w *wktWriter) float(f float64) {
+	w.buf.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func (w *wktWriter) coord(c Coord, layout Layout) {
+	w.float(c.X)
+	w.buf.WriteByte(' ')
+	w.float(c.Y)
+	if layout.HasZ() {
+		w.buf.WriteByte(' ')
+		w.float(c.Z)
+	}
+	if layout.HasM() {
+		w.buf.WriteByte(' ')
+		w.float(c.M)
+	}
+}
+
+func (w *wktWriter) coords(coords []Coord, layout Layout) {
+	w.buf.WriteByte('(')
+	for i, c := range coords {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		w.coord(c, layout)
+	}
+	w.buf.WriteByte(')')
+}
+
+func (w *wktWriter) rings(rings [][]Coord, layout Layout) {
+	w.buf.WriteByte('(')
+	for i, r := range rings {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		w.coords(r, layout)
+	}
+	w.buf.WriteByte(')')
+}
+
+func (p Point) writeWKT(w *wktWriter) {
+	if w.tag(PointType, p.Layout, p.Empty) {
+		w.buf.WriteByte('(')
+		w.coord(p.Coord, p.Layout)
+		w.buf.WriteByte(')')
+	}
+}
+
+func (l LineString) writeWKT(w *wktWriter) {
+	if w.tag(LineStringType, l.Layout, len(l.Coords) == 0) {
+		w.coords(l.Coords, l.Layout)
+	}
+}
+
+func (p Polygon) writeWKT(w *wktWriter) {
+	if w.tag(PolygonType, p.Layout, len(p.Rings) == 0) {
+		w.rings(p.Rings, p.Layout)
+	}
+}
+
+// Points are each parenthesized, e.g. MULTIPOINT((1 2),(3 4)).
+func (m MultiPoint) writeWKT(w *wktWriter) {
+	if w.tag(MultiPointType, m.Layout, len(m.Points) == 0) {
+		w.buf.WriteByte('(')
+		for i, c := range m.Points {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.coords([]Coord{c}, m.Layout)
+		}
+		w.buf.WriteByte(')')
+	}
+}
+
+func (m MultiLineString) writeWKT(w *wktWriter) {
+	if w.tag(MultiLineStringType, m.Layout, len(m.Lines) == 0) {
+		w.rings(m.Lines, m.Layout)
+	}
+}
+
+func (m MultiPolygon) writeWKT(w *wktWriter) {
+	if w.tag(MultiPolygonType, m.Layout, len(m.Polygons) == 0) {
+		w.buf.WriteByte('(')
+		for i, p := range m.Polygons {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.rings(p, m.Layout)
+		}
+		w.buf.WriteByte(')')
+	}
+}
+
+func (c GeometryCollection) writeWKT(w *wktWriter) {
+	if w.tag(GeometryCollectionType, c.Layout, len(c.Geometries) == 0) {
+		w.buf.WriteByte('(')
+		for i, g := range c.Geometries {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			g.writeWKT(w)
+		}
+		w.buf.WriteByte(')')
+	}
+}
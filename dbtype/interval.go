@@ -0,0 +1,310 @@
+package dbtype
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interval is an interval column. Its months, days and microseconds are kept
+// apart as in Postgres, since months and days have no fixed length: adding
+// one month to Jan 31 gives Feb 28 or 29, and adding one day across a DST
+// change keeps the time of day. See Duration for intervals of fixed length.
+type Interval struct {
+	Months       int32
+	Days         int32
+	Microseconds int64
+}
+
+// Microseconds of each unit of fixed length.
+var intervalUnits = map[string]float64{
+	"microsecond": 1, "us": 1, "usec": 1,
+	"millisecond": 1e3, "ms": 1e3, "msec": 1e3,
+	"second": 1e6, "s": 1e6, "sec": 1e6,
+	"minute": 60e6, "m": 60e6, "min": 60e6,
+	"hour": 3600e6, "h": 3600e6, "hr": 3600e6,
+}
+
+// Months of each unit of months.
+var intervalMonths = map[string]float64{
+	"month": 1, "mon": 1,
+	"year": 12, "y": 12, "yr": 12,
+	"decade": 120, "century": 1200, "centuries": 1200, "millennium": 12000, "millennia": 12000,
+}
+
+// Parses intervals in any of the output styles of Postgres: e.g.
+// "1 year 2 mons -3 days +04:05:06.7" (postgres),
+// "@ 1 year 2 mons -3 days 4 hours ago" (postgres_verbose),
+// "P1Y2M-3DT4H5M6.7S" (iso_8601), or "+1-2 -3 +4:05:06.7" (sql_standard).
+// Fractions of units spill into smaller ones, e.g. 1.5 months are 1 month and
+// 15 days.
+func ParseInterval(s string) (Interval, error) {
+	str := strings.TrimSpace(s)
+	if strings.HasPrefix(str, "P") {
+		res, err := parseISOInterval(str[1:])
+		if err != nil {
+			return Interval{}, fmt.Errorf("invalid interval %q", s)
+		}
+		return res, nil
+	}
+	invalid := fmt.Errorf("invalid interval %q", s)
+	fields := strings.Fields(strings.ToLower(strings.TrimPrefix(str, "@")))
+	if len(fields) == 0 {
+		return Interval{}, invalid
+	}
+	// In the sql_standard style, a leading - applies to all fields of no sign
+	// of their own, e.g. -1 2:00:00 is minus 1 day and 2 hours.
+	negAll := len(fields) > 1 && strings.HasPrefix(fields[0], "-")
+	for i, f := range fields {
+		if _, err := strconv.ParseFloat(f, 64); err != nil && !strings.ContainsAny(f, ":-") ||
+			i > 0 && strings.IndexAny(f, "+-") == 0 {
+			negAll = false
+		}
+	}
+	if negAll {
+		fields[0] = fields[0][1:]
+	}
+	acc := intervalAcc{}
+	neg := negAll
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		switch {
+		case f == "ago" && i > 0 && i == len(fields)-1:
+			neg = !neg
+		case strings.IndexByte(f, ':') >= 0:
+			t, err := parseClock(f)
+			if err != nil {
+				return Interval{}, invalid
+			}
+			acc.us += float64(t / time.Microsecond)
+		case strings.IndexByte(strings.TrimLeft(f, "+-"), '-') > 0:
+			// Years and months of the sql_standard style, e.g. -1-2.
+			minus := strings.HasPrefix(f, "-")
+			parts := strings.SplitN(strings.TrimLeft(f, "+-"), "-", 2)
+			y, err1 := strconv.ParseUint(parts[0], 10, 31)
+			m, err2 := strconv.ParseUint(parts[1], 10, 31)
+			if err1 != nil || err2 != nil {
+				return Interval{}, invalid
+			}
+			months := float64(y*12 + m)
+			if minus {
+				months = -months
+			}
+			acc.months += months
+		default:
+			n, err := strconv.ParseFloat(f, 64)
+			if err != nil || strings.ContainsAny(f, "eEnN") {
+				return Interval{}, invalid
+			}
+			unit := ""
+			if i+1 < len(fields) {
+				unit = fields[i+1]
+			}
+			if !acc.add(n, unit) {
+				// Numbers of no unit are days if a time follows, as in the
+				// sql_standard style, and seconds otherwise.
+				if i+1 < len(fields) && strings.IndexByte(unit, ':') >= 0 {
+					acc.add(n, "day")
+				} else if i+1 == len(fields) {
+					acc.add(n, "second")
+				} else {
+					return Interval{}, invalid
+				}
+				continue
+			}
+			i++
+		}
+	}
+	res, err := acc.interval()
+	if err != nil {
+		return Interval{}, invalid
+	}
+	if neg {
+		res = res.Neg()
+	}
+	return res, nil
+}
+
+// Accumulates the parts of an interval, fractions spilling into smaller
+// units.
+type intervalAcc struct {
+	months float64
+	days   float64
+	us     float64
+}
+
+// Adds n of unit, returning false if unit is unknown.
+func (a *intervalAcc) add(n float64, unit string) bool {
+	unit = strings.TrimSuffix(unit, ",")
+	if _, ok := intervalMonths[unit]; !ok {
+		unit = strings.TrimSuffix(unit, "s")
+	}
+	if m, ok := intervalMonths[unit]; ok {
+		a.months += n * m
+	} else if unit == "day" || unit == "d" {
+		a.days += n
+	} else if unit == "week" || unit == "w" {
+		a.days += n * 7
+	} else if us, ok := intervalUnits[unit]; ok {
+		a.us += n * us
+	} else {
+		return false
+	}
+	return true
+}
+
+func (a intervalAcc) interval() (Interval, error) {
+	// As Postgres does, fractions of months are 30 days, and fractions of
+	// days 24 hours.
+	months := math.Trunc(a.months)
+	days := a.days + (a.months-months)*30
+	wholeDays := math.Trunc(days)
+	us := math.Round(a.us + (days-wholeDays)*86400e6)
+	if math.Abs(months) > math.MaxInt32 || math.Abs(wholeDays) > math.MaxInt32 ||
+		math.Abs(us) > math.MaxInt64/2 {
+		return Interval{}, fmt.Errorf("interval out of range")
+	}
+	return Interval{Months: int32(months), Days: int32(wholeDays), Microseconds: int64(us)}, nil
+}
+
+// Parses the ISO 8601 format with designators, past its leading P.
+func parseISOInterval(s string) (Interval, error) {
+	str := s
+	acc := intervalAcc{}
+	inTime := false
+	if s == "" {
+		return Interval{}, fmt.Errorf("empty interval")
+	}
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime {
+				return Interval{}, fmt.Errorf("duplicate T")
+			}
+			inTime, s = true, s[1:]
+			continue
+		}
+		end := strings.IndexFunc(s, func(c rune) bool {
+			return c != '-' && c != '+' && c != '.' && (c < '0' || c > '9')
+		})
+		if end <= 0 {
+			return Interval{}, fmt.Errorf("expected a number")
+		}
+		n, err := strconv.ParseFloat(s[:end], 64)
+		if err != nil {
+			return Interval{}, err
+		}
+		units := map[byte]string{'Y': "year", 'M': "month", 'W': "week", 'D': "day"}
+		if inTime {
+			units = map[byte]string{'H': "hour", 'M': "minute", 'S': "second"}
+		}
+		unit, ok := units[s[end]]
+		if !ok {
+			return Interval{}, fmt.Errorf("unexpected %q", s[end])
+		}
+		acc.add(n, unit)
+		s = s[end+1:]
+	}
+	if strings.HasSuffix(str, "T") {
+		return Interval{}, fmt.Errorf("expected a time after T")
+	}
+	return acc.interval()
+}
+
+func (i Interval) Neg() Interval {
+	return Interval{Months: -i.Months, Days: -i.Days, Microseconds: -i.Microseconds}
+}
+
+// Writes i in the ISO 8601 format with designators, e.g. P1Y2M-3DT4H5M6.7S,
+// which Postgres reads whatever its IntervalStyle.
+func (i Interval) String() string {
+	if i == (Interval{}) {
+		return "PT0S"
+	}
+	buf := bytes.Buffer{}
+	buf.WriteByte('P')
+	write := func(n int64, unit byte) {
+		if n != 0 {
+			buf.WriteString(strconv.FormatInt(n, 10))
+			buf.WriteByte(unit)
+		}
+	}
+	write(int64(i.Months/12), 'Y')
+	write(int64(i.Months%12), 'M')
+	write(int64(i.Days), 'D')
+	if i.Microseconds != 0 {
+		buf.WriteByte('T')
+		us := i.Microseconds
+		write(us/3600e6, 'H')
+		write(us/60e6%60, 'M')
+		if secs := us % 60e6; secs != 0 {
+			str := fmt.Sprintf("%d.%06d", secs/1e6, abs(secs%1e6))
+			if secs < 0 && secs > -1e6 {
+				str = "-" + str
+			}
+			buf.WriteString(strings.TrimSuffix(strings.TrimRight(str, "0"), "."))
+			buf.WriteByte('S')
+		}
+	}
+	return buf.String()
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Returns t + i as Postgres computes it: months are added first, clamping the
+// day to the end of the month, then days, keeping the time of day in t's
+// location, then microseconds.
+func (i Interval) AddTo(t time.Time) time.Time {
+	if i.Months != 0 {
+		y, m, d := t.Date()
+		hh, mm, ss := t.Clock()
+		months := int(m) - 1 + int(i.Months)
+		y += months / 12
+		if months %= 12; months < 0 {
+			months, y = months+12, y-1
+		}
+		m = time.Month(months + 1)
+		if last := time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day(); d > last {
+			d = last
+		}
+		t = time.Date(y, m, d, hh, mm, ss, t.Nanosecond(), t.Location())
+	}
+	if i.Days != 0 {
+		t = t.AddDate(0, 0, int(i.Days))
+	}
+	return t.Add(time.Duration(i.Microseconds) * time.Microsecond)
+}
+
+func (i Interval) Value() (driver.Value, error) {
+	return i.String(), nil
+}
+
+func (i *Interval) Scan(src interface{}) (err error) {
+	switch val := src.(type) {
+	case string:
+		*i, err = ParseInterval(val)
+	case []byte:
+		*i, err = ParseInterval(string(val))
+	default:
+		return fmt.Errorf("cannot scan %T into an Interval", src)
+	}
+	return
+}
+
+// Intervals are ISO 8601 strings in JSON, and may be read in any style.
+func (i Interval) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+func (i *Interval) UnmarshalText(data []byte) (err error) {
+	*i, err = ParseInterval(string(data))
+	return
+}
@@ -0,0 +1,127 @@
+package dbtype
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInterval(t *testing.T) {
+	const hour = 3600e6
+	cases := []struct {
+		src  string
+		want Interval
+	}{
+		// postgres
+		{"00:00:00", Interval{}},
+		{"1 year 2 mons 3 days 04:05:06.789", Interval{14, 3, 4*hour + 5*60e6 + 6789000}},
+		{"-1 years -2 mons +3 days -04:05:06", Interval{-14, 3, -(4*hour + 5*60e6 + 6e6)}},
+		{"-1 days +02:00:00", Interval{0, -1, 2 * hour}},
+		{"1 mon", Interval{1, 0, 0}},
+		// postgres_verbose
+		{"@ 1 year 2 mons -3 days 4 hours 5 mins 6.789 secs ago", Interval{-14, 3, -(4*hour + 5*60e6 + 6789000)}},
+		{"@ 0", Interval{}},
+		// iso_8601
+		{"PT0S", Interval{}},
+		{"P1Y2M3DT4H5M6.789S", Interval{14, 3, 4*hour + 5*60e6 + 6789000}},
+		{"P-1Y-2M3DT-4H-5M-6.789S", Interval{-14, 3, -(4*hour + 5*60e6 + 6789000)}},
+		{"P2W", Interval{0, 14, 0}},
+		{"PT1.5H", Interval{0, 0, 1.5 * hour}},
+		// sql_standard
+		{"1-2", Interval{14, 0, 0}},
+		{"-1-2", Interval{-14, 0, 0}},
+		{"3 4:05:06", Interval{0, 3, 4*hour + 5*60e6 + 6e6}},
+		{"-3 4:05:06", Interval{0, -3, -(4*hour + 5*60e6 + 6e6)}},
+		{"+1-2 -3 +4:05:06.789", Interval{14, -3, 4*hour + 5*60e6 + 6789000}},
+		{"-1-2 3 4:05:06", Interval{-14, -3, -(4*hour + 5*60e6 + 6e6)}},
+		// Other input.
+		{"1.5 months", Interval{1, 15, 0}},
+		{"1.5 years", Interval{18, 0, 0}},
+		{"1.5 days", Interval{0, 1, 12 * hour}},
+		{"2 weeks 1 decade", Interval{120, 14, 0}},
+		{"3 centuries", Interval{3600, 0, 0}},
+		{"90", Interval{0, 0, 90e6}},
+		{"500 milliseconds", Interval{0, 0, 500e3}},
+		{"1 day 2", Interval{0, 1, 2e6}},
+	}
+	for _, c := range cases {
+		i, err := ParseInterval(c.src)
+		assert.NoError(t, err, c.src)
+		assert.Equal(t, c.want, i, c.src)
+	}
+	for _, src := range []string{"", "P", "PT", "P1H", "P1YT", "1 fortnight", "1 day 2 x", "x:00:00",
+		"1-x", "1e3 days", "ago", "1 day ago ago", "PT1S1S2"} {
+		_, err := ParseInterval(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestInterval_String(t *testing.T) {
+	cases := []struct {
+		i    Interval
+		want string
+	}{
+		{Interval{}, "PT0S"},
+		{Interval{14, 3, 0}, "P1Y2M3D"},
+		{Interval{-14, 3, -(4*3600e6 + 5*60e6 + 6789000)}, "P-1Y-2M3DT-4H-5M-6.789S"},
+		{Interval{0, 0, -500e3}, "PT-0.5S"},
+		{Interval{0, 0, 60e6}, "PT1M"},
+		{Interval{0, 0, 20e6}, "PT20S"},
+		{Interval{0, 0, 1}, "PT0.000001S"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, c.i.String())
+		// Round trip.
+		i, err := ParseInterval(c.want)
+		assert.NoError(t, err)
+		assert.Equal(t, c.i, i)
+	}
+}
+
+func TestInterval_AddTo(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	cases := []struct {
+		t    time.Time
+		i    Interval
+		want time.Time
+	}{
+		{time.Date(2020, 1, 31, 10, 0, 0, 0, time.UTC), Interval{Months: 1},
+			time.Date(2020, 2, 29, 10, 0, 0, 0, time.UTC)},
+		{time.Date(2021, 1, 31, 10, 0, 0, 0, time.UTC), Interval{Months: 1},
+			time.Date(2021, 2, 28, 10, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 3, 31, 0, 0, 0, 0, time.UTC), Interval{Months: -1},
+			time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), Interval{Months: -13},
+			time.Date(2018, 12, 15, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC), Interval{Months: 1, Days: 1},
+			time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)},
+		// Days keep the time of day across DST changes, while hours don't.
+		{time.Date(2020, 3, 7, 12, 0, 0, 0, ny), Interval{Days: 1},
+			time.Date(2020, 3, 8, 12, 0, 0, 0, ny)},
+		{time.Date(2020, 3, 7, 12, 0, 0, 0, ny), Interval{Microseconds: 24 * 3600e6},
+			time.Date(2020, 3, 8, 13, 0, 0, 0, ny)},
+	}
+	for _, c := range cases {
+		assert.True(t, c.want.Equal(c.i.AddTo(c.t)), "%s + %s = %s", c.t, c.i, c.i.AddTo(c.t))
+	}
+}
+
+func TestInterval_SQL(t *testing.T) {
+	i := Interval{}
+	assert.NoError(t, i.Scan([]byte("1 day 02:00:00")))
+	assert.Equal(t, Interval{0, 1, 2 * 3600e6}, i)
+	v, err := i.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "P1DT2H", v)
+	assert.Error(t, i.Scan(1))
+
+	data, err := json.Marshal(i)
+	assert.NoError(t, err)
+	assert.Equal(t, `"P1DT2H"`, string(data))
+	assert.NoError(t, json.Unmarshal([]byte(`"3 mons"`), &i))
+	assert.Equal(t, Interval{Months: 3}, i)
+}
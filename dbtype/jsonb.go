@@ -1,9 +1,11 @@
 package dbtype
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"database/sql/driver"
+	"fmt"
 )
 
 type Jsonb map[string]interface{}
@@ -18,6 +20,9 @@ func (j Jsonb) Value() (driver.Value, error) {
 
 func (j *Jsonb) Scan(src interface{}) error {
 	source, ok := src.([]byte)
+	if str, isStr := src.(string); isStr {
+		source, ok = []byte(str), true
+	}
 	if !ok {
 		return errors.New("Type assertion .([]byte) failed.")
 	}
@@ -43,3 +48,45 @@ func (j *Jsonb) GetOrDefault(key string, defaultValue interface{}) interface{} {
 	}
 	return defaultValue
 }
+
+// JsonbValue is any JSON value: an object, an array, a string, a number, a
+// boolean, or null, i.e. a nil V. It is decoded as by encoding/json, except
+// that numbers are json.Number so that no precision is lost. JSON null is not
+// SQL NULL, which only null.Jsonb can hold.
+type JsonbValue struct {
+	V interface{}
+}
+
+func (j JsonbValue) Value() (driver.Value, error) {
+	return json.Marshal(j.V)
+}
+
+func (j *JsonbValue) Scan(src interface{}) error {
+	switch val := src.(type) {
+	case []byte:
+		return j.UnmarshalJSON(val)
+	case string:
+		return j.UnmarshalJSON([]byte(val))
+	case nil:
+		return errors.New("cannot scan NULL into a JsonbValue, use null.Jsonb")
+	}
+	return fmt.Errorf("cannot scan %T into a JsonbValue", src)
+}
+
+func (j JsonbValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.V)
+}
+
+func (j *JsonbValue) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("invalid JSON: data after the top-level value")
+	}
+	j.V = v
+	return nil
+}
@@ -0,0 +1,55 @@
+package dbtype
+
+import (
+	"encoding/json"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonb_Scan(t *testing.T) {
+	j := Jsonb{}
+	assert.NoError(t, j.Scan([]byte(`{"a":1}`)))
+	assert.Equal(t, Jsonb{"a": 1.0}, j)
+	assert.NoError(t, j.Scan(`{"b":"x"}`))
+	assert.Equal(t, Jsonb{"b": "x"}, j)
+	assert.Error(t, j.Scan(`[1]`))
+	assert.Error(t, j.Scan(1))
+}
+
+func TestJsonbValue(t *testing.T) {
+	cases := []struct {
+		src  string
+		want interface{}
+	}{
+		{`{"a":[1,"x"]}`, map[string]interface{}{"a": []interface{}{json.Number("1"), "x"}}},
+		{`[true,null]`, []interface{}{true, nil}},
+		{`"x"`, "x"},
+		{`12345678901234567890`, json.Number("12345678901234567890")},
+		{`1.5`, json.Number("1.5")},
+		{`false`, false},
+		{`null`, nil},
+	}
+	for _, c := range cases {
+		j := JsonbValue{}
+		assert.NoError(t, j.Scan([]byte(c.src)), c.src)
+		assert.Equal(t, c.want, j.V, c.src)
+		v, err := j.Value()
+		assert.NoError(t, err)
+		assert.Equal(t, c.src, string(v.([]byte)))
+		assert.NoError(t, j.Scan(c.src), c.src)
+		assert.Equal(t, c.want, j.V, c.src)
+	}
+
+	j := JsonbValue{}
+	assert.Error(t, j.Scan(nil))
+	assert.Error(t, j.Scan(1))
+	assert.Error(t, j.Scan(`{"a":`))
+	assert.Error(t, j.Scan(`1 2`))
+
+	s := struct{ J JsonbValue }{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"J":[1]}`), &s))
+	assert.Equal(t, []interface{}{json.Number("1")}, s.J.V)
+	data, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"J":[1]}`, string(data))
+}
@@ -0,0 +1,218 @@
+package dbtype
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Multirange is a Postgres multirange, e.g. {[1,3),[5,7)}. Multiranges made
+// by NewMultirange, read from the database or JSON, or returned by the set
+// operations, are normalized as Postgres does: sorted, with no empty ranges,
+// and with overlapping or adjacent ranges merged.
+type Multirange[T any] []Range[T]
+
+type Int4Multirange = Multirange[int32]
+
+type Int8Multirange = Multirange[int64]
+
+type NumMultirange = Multirange[Decimal]
+
+// Timestamps of a tsmultirange are in UTC.
+type TsMultirange = Multirange[time.Time]
+
+type TstzMultirange = Multirange[time.Time]
+
+type DateMultirange = Multirange[Date]
+
+// Returns the normalized multirange of ranges.
+func NewMultirange[T any](ranges ... Range[T]) Multirange[T] {
+	ops := opsOf[T]()
+	sorted := make([]Range[T], 0, len(ranges))
+	for _, r := range ranges {
+		if !r.IsEmpty() {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return cmpBounds(ops, sorted[i].lower(), sorted[j].lower()) < 0
+	})
+	res := Multirange[T]{}
+	for _, r := range sorted {
+		if n := len(res); n > 0 && (res[n-1].Overlaps(r) || res[n-1].Adjacent(r)) {
+			if cmpBounds(ops, r.upper(), res[n-1].upper()) > 0 {
+				res[n-1] = boundsRange(res[n-1].lower(), r.upper())
+			}
+			continue
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+func (m Multirange[T]) IsEmpty() bool {
+	for _, r := range m {
+		if !r.IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether v is in any of the ranges of m.
+func (m Multirange[T]) Contains(v T) bool {
+	for _, r := range m {
+		if r.Contains(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether all of r is in m.
+func (m Multirange[T]) ContainsRange(r Range[T]) bool {
+	if r.IsEmpty() {
+		return true
+	}
+	for _, sub := range NewMultirange(m...) {
+		if sub.ContainsRange(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the elements in m or other, as m + other does.
+func (m Multirange[T]) Union(other Multirange[T]) Multirange[T] {
+	all := make([]Range[T], 0, len(m)+len(other))
+	return NewMultirange(append(append(all, m...), other...)...)
+}
+
+// Returns the elements both in m and other, as m * other does.
+func (m Multirange[T]) Intersect(other Multirange[T]) Multirange[T] {
+	ops := opsOf[T]()
+	a, b := NewMultirange(m...), NewMultirange(other...)
+	var res []Range[T]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		if r := a[i].Intersect(b[j]); !r.IsEmpty() {
+			res = append(res, r)
+		}
+		// The range which ends first can't meet any further ones.
+		if cmpBounds(ops, a[i].upper(), b[j].upper()) < 0 {
+			i++
+		} else {
+			j++
+		}
+	}
+	return NewMultirange(res...)
+}
+
+// Returns the elements in m but not in other, as m - other does.
+func (m Multirange[T]) Difference(other Multirange[T]) Multirange[T] {
+	ops := opsOf[T]()
+	rest := NewMultirange(m...)
+	for _, s := range NewMultirange(other...) {
+		var next []Range[T]
+		for _, r := range rest {
+			if !r.Overlaps(s) {
+				next = append(next, r)
+				continue
+			}
+			// The parts of r left and right of s, whose bounds are the
+			// opposite of those of s.
+			if cmpBounds(ops, r.lower(), s.lower()) < 0 {
+				next = append(next, boundsRange(r.lower(), s.lower().opposite()))
+			}
+			if cmpBounds(ops, r.upper(), s.upper()) > 0 {
+				next = append(next, boundsRange(s.upper().opposite(), r.upper()))
+			}
+		}
+		rest = next
+	}
+	return NewMultirange(rest...)
+}
+
+// Returns the bound right next to b on its other side, e.g. the upper bound
+// 5) for the lower bound [5.
+func (b rangeBound[T]) opposite() rangeBound[T] {
+	b.lower, b.inc = !b.lower, !b.inc
+	return b
+}
+
+// Returns the text form of m, e.g. {[1,3),[5,7)}.
+func (m Multirange[T]) String() string {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, r := range NewMultirange(m...) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(r.String())
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+func ParseMultirange[T any](s string) (Multirange[T], error) {
+	invalid := func(msg string) (Multirange[T], error) {
+		return nil, fmt.Errorf("invalid multirange %q: %s", s, msg)
+	}
+	rest := strings.TrimSpace(s)
+	if !strings.HasPrefix(rest, "{") || !strings.HasSuffix(rest, "}") {
+		return invalid("expected {")
+	}
+	rest = rest[1 : len(rest)-1]
+	var ranges []Range[T]
+	for strings.TrimSpace(rest) != "" {
+		if ranges != nil {
+			rest = strings.TrimSpace(rest)
+			if rest[0] != ',' {
+				return invalid(fmt.Sprintf("unexpected %q", rest))
+			}
+			rest = rest[1:]
+		}
+		r, n, err := parseRange[T](rest)
+		if err != nil {
+			return nil, err
+		}
+		ranges, rest = append(ranges, r), rest[n:]
+	}
+	return NewMultirange(ranges...), nil
+}
+
+func (m Multirange[T]) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Multirange[T]) Scan(src interface{}) (err error) {
+	switch val := src.(type) {
+	case string:
+		*m, err = ParseMultirange[T](val)
+	case []byte:
+		*m, err = ParseMultirange[T](string(val))
+	case nil:
+		return errors.New("cannot scan NULL into a Multirange, use null.Value")
+	default:
+		return fmt.Errorf("cannot scan %T into a Multirange", src)
+	}
+	return
+}
+
+// Multiranges are JSON arrays of ranges.
+func (m Multirange[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]Range[T](NewMultirange(m...)))
+}
+
+func (m *Multirange[T]) UnmarshalJSON(data []byte) error {
+	var ranges []Range[T]
+	if err := json.Unmarshal(data, &ranges); err != nil {
+		return err
+	}
+	*m = NewMultirange(ranges...)
+	return nil
+}
@@ -0,0 +1,109 @@
+package dbtype
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func ints(s string) Int4Multirange {
+	m, err := ParseMultirange[int32](s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func TestNewMultirange(t *testing.T) {
+	m := NewMultirange(NewRange[int32](5, 7, "[)"), EmptyRange[int32](), NewRange[int32](1, 3, "[)"),
+		NewRange[int32](2, 4, "[]"), NewRange[int32](10, 12, "[)"), NewRange[int32](12, 15, "[)"))
+	assert.Equal(t, "{[1,7),[10,15)}", m.String())
+	assert.Equal(t, "{}", NewMultirange[int32]().String())
+	assert.Equal(t, "{}", NewMultirange(EmptyRange[int32]()).String())
+	assert.Equal(t, "{(,)}", NewMultirange(Range[int32]{LowerInf: true, Upper: 3},
+		Range[int32]{Lower: 1, LowerInc: true, UpperInf: true}).String())
+
+	// Continuous ranges merge if they meet.
+	a := NewRange(NewDecimal(1, 0), NewDecimal(2, 0), "[)")
+	b := NewRange(NewDecimal(2, 0), NewDecimal(3, 0), "(]")
+	assert.Equal(t, "{[1,2),(2,3]}", NewMultirange(a, b).String())
+	assert.Equal(t, "{[1,3]}", NewMultirange(a, b, NewRange(NewDecimal(2, 0), NewDecimal(2, 0), "[]")).String())
+}
+
+func TestParseMultirange(t *testing.T) {
+	m, err := ParseMultirange[int32](" { [1,3) , (4,6], empty,[2,3) } ")
+	assert.NoError(t, err)
+	assert.Equal(t, Int4Multirange{NewRange[int32](1, 3, "[)"), NewRange[int32](5, 7, "[)")}, m)
+	m, err = ParseMultirange[int32]("{}")
+	assert.NoError(t, err)
+	assert.True(t, m.IsEmpty())
+	for _, src := range []string{"", "[1,2)", "{[1,2)", "{[1,2) [3,4)}", "{,}", "{[1,2),}", "{[2,1)}"} {
+		_, err := ParseMultirange[int32](src)
+		assert.Error(t, err, src)
+	}
+
+	ts, err := ParseMultirange[time.Time](`{["2020-01-01 00:00:00+00","2020-01-02 00:00:00+00")}`)
+	assert.NoError(t, err)
+	assert.True(t, ts.Contains(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)))
+	assert.False(t, ts.Contains(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestMultirange_Ops(t *testing.T) {
+	m := ints("{[1,5),[10,20)}")
+	assert.True(t, m.Contains(4))
+	assert.False(t, m.Contains(5))
+	assert.True(t, m.ContainsRange(NewRange[int32](10, 15, "[)")))
+	assert.False(t, m.ContainsRange(NewRange[int32](4, 11, "[)")))
+
+	cases := []struct {
+		a, b                   string
+		union, intersect, diff string
+	}{
+		{"{[1,5),[10,20)}", "{[3,12)}", "{[1,20)}", "{[3,5),[10,12)}", "{[1,3),[12,20)}"},
+		{"{[1,5)}", "{[5,10)}", "{[1,10)}", "{}", "{[1,5)}"},
+		{"{[1,10)}", "{[2,3),[5,6)}", "{[1,10)}", "{[2,3),[5,6)}", "{[1,2),[3,5),[6,10)}"},
+		{"{[1,10)}", "{}", "{[1,10)}", "{}", "{[1,10)}"},
+		{"{(,)}", "{[0,5)}", "{(,)}", "{[0,5)}", "{(,0),[5,)}"},
+		{"{[1,3),[4,6)}", "{[1,3),[4,6)}", "{[1,3),[4,6)}", "{[1,3),[4,6)}", "{}"},
+	}
+	for _, c := range cases {
+		a, b := ints(c.a), ints(c.b)
+		assert.Equal(t, c.union, a.Union(b).String(), c.a+" + "+c.b)
+		assert.Equal(t, c.intersect, a.Intersect(b).String(), c.a+" * "+c.b)
+		assert.Equal(t, c.diff, a.Difference(b).String(), c.a+" - "+c.b)
+	}
+
+	// Free slots of a day, given busy ones.
+	day := func(h int) time.Time { return time.Date(2020, 1, 1, h, 0, 0, 0, time.UTC) }
+	work := NewMultirange(NewRange(day(9), day(17), "[)"))
+	busy := NewMultirange(NewRange(day(10), day(11), "[)"), NewRange(day(13), day(15), "[)"))
+	free := work.Difference(busy)
+	assert.Equal(t, TstzMultirange{NewRange(day(9), day(10), "[)"), NewRange(day(11), day(13), "[)"),
+		NewRange(day(15), day(17), "[)")}, free)
+	assert.Equal(t, work, free.Union(busy))
+
+	// Bounds of continuous ranges are kept exact.
+	one, two := NewDecimal(1, 0), NewDecimal(2, 0)
+	diff := NewMultirange(NewRange(one, NewDecimal(3, 0), "[]")).Difference(NewMultirange(NewRange(one, two, "(]")))
+	assert.Equal(t, "{[1,1],(2,3]}", diff.String())
+}
+
+func TestMultirange_SQL(t *testing.T) {
+	m := Int4Multirange{}
+	assert.NoError(t, m.Scan([]byte("{[1,3),[5,7)}")))
+	assert.Equal(t, ints("{[1,3),[5,7)}"), m)
+	v, err := m.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "{[1,3),[5,7)}", v)
+	assert.Error(t, m.Scan(nil))
+
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"lower":1,"upper":3,"bounds":"[)"},{"lower":5,"upper":7,"bounds":"[)"}]`, string(data))
+	data, err = json.Marshal(Int4Multirange(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", string(data))
+	assert.NoError(t, json.Unmarshal([]byte(`[{"lower":5,"upper":8},{"lower":1,"upper":6}]`), &m))
+	assert.Equal(t, ints("{[1,8)}"), m)
+}
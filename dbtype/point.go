@@ -3,56 +3,42 @@ package dbtype
 import (
 	"fmt"
 	"database/sql/driver"
-	"bytes"
-	"encoding/binary"
-	"encoding/hex"
+	"github.com/tsealex/dbutil/dbtype/geom"
 )
 
+// Point is a geography(Point) column. See package geom for other
+// geometries.
 // Reference:
 // https://github.com/nferruzzi/gormGIS
 type Point struct {
 	Lat  float64 `json:"lat"`
 	Long float64 `json:"long"`
+	// Spatial reference ID, 4326 (WGS 84) if 0.
+	SRID int `json:"-"`
 }
 
 func (p *Point) String() string {
 	return fmt.Sprintf("(Lat: %v, Long: %v)", p.Lat, p.Long)
 }
 
+// Reads hex or binary (E)WKB, whose x is the longitude and y the latitude.
 func (p *Point) Scan(v interface{}) error {
-	b, err := hex.DecodeString(string(v.([]uint8)))
-	if err != nil {
-		return err
-	}
-	r := bytes.NewReader(b)
-	var wkbOrder uint8
-	var byteOrder binary.ByteOrder
-	if err := binary.Read(r, binary.LittleEndian, &wkbOrder); err != nil {
-		return err
-	} else if wkbOrder == 0 {
-		byteOrder = binary.BigEndian
-	} else if wkbOrder == 1 {
-		byteOrder = binary.LittleEndian
-	} else {
-		return fmt.Errorf("invalid WKB representation")
-	}
-
-	var wkbType uint64
-	if err := binary.Read(r, byteOrder, &wkbType); err != nil {
-		return err
-	}
-	// TODO: check if wkbType is a Point
-	// Reference:
-	// https://en.wikipedia.org/wiki/Well-known_text#Well-known_binary
-
-	if err := binary.Read(r, byteOrder, p); err != nil {
+	g := geom.Point{}
+	if err := g.Scan(v); err != nil {
 		return err
+	} else if g.Empty {
+		return fmt.Errorf("cannot scan POINT EMPTY into a Point")
 	}
+	*p = Point{Lat: g.Y, Long: g.X, SRID: g.SRID}
 	return nil
 }
 
 func (p Point) Value() (driver.Value, error) {
 	// PostGis stores geography points in long and then lat format
-	// https://postgis.net/2013/08/18/tip_lon_lat/NullFloat64Array
-	return fmt.Sprintf("SRID=4326;POINT(%v %v)", p.Long, p.Lat), nil
+	// https://postgis.net/2013/08/18/tip_lon_lat/
+	srid := p.SRID
+	if srid == 0 {
+		srid = 4326
+	}
+	return fmt.Sprintf("SRID=%d;POINT(%v %v)", srid, p.Long, p.Lat), nil
 }
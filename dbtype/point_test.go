@@ -0,0 +1,28 @@
+package dbtype
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoint(t *testing.T) {
+	p := Point{}
+	// SRID=4326;POINT(1 2), in hex EWKB and in binary WKB.
+	assert.NoError(t, p.Scan([]byte("0101000020E6100000000000000000F03F0000000000000040")))
+	assert.Equal(t, Point{Lat: 2, Long: 1, SRID: 4326}, p)
+	assert.NoError(t, p.Scan([]byte{1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 0, 0, 0, 0, 0, 0, 0, 0x40}))
+	assert.Equal(t, Point{Lat: 2, Long: 1}, p)
+	v, err := p.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "SRID=4326;POINT(1 2)", v)
+
+	p.SRID = 3857
+	v, err = p.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "SRID=3857;POINT(1 2)", v)
+
+	// Other geometries are not points.
+	assert.Error(t, p.Scan([]byte("0102000000000000000000")))
+	assert.Error(t, p.Scan([]byte("0101000000000000000000F87F000000000000F87F")))
+	assert.Error(t, p.Scan(1))
+}
@@ -0,0 +1,539 @@
+package dbtype
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Range is a Postgres range, e.g. [1,10) or (,2020-01-01]. Each bound is
+// inclusive or exclusive, or infinite, in which case Lower or Upper is
+// ignored. T is int32, int64, Decimal, time.Time or Date. Ranges of integers
+// and dates are kept in the canonical [) form, as Postgres does.
+//
+// Ranges whose lower bound is after their upper one, e.g. the zero value
+// (0,0), are empty, as are ranges with Empty set.
+type Range[T any] struct {
+	Lower    T
+	Upper    T
+	LowerInc bool
+	UpperInc bool
+	LowerInf bool
+	UpperInf bool
+	Empty    bool
+}
+
+type Int4Range = Range[int32]
+
+type Int8Range = Range[int64]
+
+type NumRange = Range[Decimal]
+
+// Timestamps of a tsrange are in UTC.
+type TsRange = Range[time.Time]
+
+type TstzRange = Range[time.Time]
+
+type DateRange = Range[Date]
+
+// Returns the range from lower to upper, bounds telling which of them are
+// included as in Postgres, i.e. "[)", "[]", "()" or "(]". Panics if bounds is
+// invalid, lower is after upper, or an exclusive bound can't be made inclusive
+// (or the other way round) without overflowing, e.g. that of [1,2147483647].
+func NewRange[T any](lower T, upper T, bounds string) Range[T] {
+	if len(bounds) != 2 || strings.IndexByte("[(", bounds[0]) < 0 || strings.IndexByte("])", bounds[1]) < 0 {
+		panic(fmt.Sprintf("invalid range bounds %q", bounds))
+	}
+	if opsOf[T]().cmp(lower, upper) > 0 {
+		panic("range lower bound must be less than or equal to range upper bound")
+	}
+	r := Range[T]{Lower: lower, Upper: upper, LowerInc: bounds[0] == '[', UpperInc: bounds[1] == ']'}
+	r, err := r.canonical()
+	if err != nil {
+		panic(err.Error())
+	}
+	return r
+}
+
+func EmptyRange[T any]() Range[T] {
+	return Range[T]{Empty: true}
+}
+
+// Operations on the elements of ranges.
+type rangeOps[T any] struct {
+	cmp    func(a T, b T) int
+	parse  func(string) (T, error)
+	format func(T) string
+	// Returns the element right after v, for discrete types only, and false
+	// if v is the last one.
+	next func(v T) (T, bool)
+}
+
+func cmpInts[T int32 | int64](a T, b T) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func opsOf[T any]() rangeOps[T] {
+	var zero T
+	var res interface{}
+	switch interface{}(zero).(type) {
+	case int32:
+		res = rangeOps[int32]{
+			cmp: cmpInts[int32],
+			parse: func(s string) (int32, error) {
+				v, err := strconv.ParseInt(s, 10, 32)
+				return int32(v), err
+			},
+			format: func(v int32) string { return strconv.FormatInt(int64(v), 10) },
+			next:   func(v int32) (int32, bool) { return v + 1, v < math.MaxInt32 },
+		}
+	case int64:
+		res = rangeOps[int64]{
+			cmp: cmpInts[int64],
+			parse: func(s string) (int64, error) {
+				return strconv.ParseInt(s, 10, 64)
+			},
+			format: func(v int64) string { return strconv.FormatInt(v, 10) },
+			next:   func(v int64) (int64, bool) { return v + 1, v < math.MaxInt64 },
+		}
+	case Decimal:
+		res = rangeOps[Decimal]{
+			cmp:    Decimal.Cmp,
+			parse:  ParseDecimal,
+			format: Decimal.String,
+		}
+	case time.Time:
+		res = rangeOps[time.Time]{
+			cmp:    time.Time.Compare,
+			parse:  ParseTime,
+			format: func(t time.Time) string { return t.Format(time.RFC3339Nano) },
+		}
+	case Date:
+		res = rangeOps[Date]{
+			cmp:    Date.Compare,
+			parse:  ParseDate,
+			format: Date.String,
+			next:   func(d Date) (Date, bool) { return d.AddDays(1), true },
+		}
+	default:
+		panic(fmt.Sprintf("unsupported range element type %T", zero))
+	}
+	return res.(rangeOps[T])
+}
+
+// A bound of a range.
+type rangeBound[T any] struct {
+	v     T
+	inf   bool
+	inc   bool
+	lower bool
+}
+
+func (r Range[T]) lower() rangeBound[T] {
+	return rangeBound[T]{v: r.Lower, inf: r.LowerInf, inc: r.LowerInc, lower: true}
+}
+
+func (r Range[T]) upper() rangeBound[T] {
+	return rangeBound[T]{v: r.Upper, inf: r.UpperInf, inc: r.UpperInc}
+}
+
+// Compares bounds by where they are on the line of elements, as Postgres'
+// range_cmp_bounds does: e.g. the exclusive lower bound (5 comes after the
+// exclusive upper bound 5).
+func cmpBounds[T any](ops rangeOps[T], a rangeBound[T], b rangeBound[T]) int {
+	if a.inf && b.inf {
+		if a.lower == b.lower {
+			return 0
+		}
+	}
+	if a.inf {
+		if a.lower {
+			return -1
+		}
+		return 1
+	} else if b.inf {
+		if b.lower {
+			return 1
+		}
+		return -1
+	}
+	if c := ops.cmp(a.v, b.v); c != 0 {
+		return c
+	}
+	switch {
+	case !a.inc && !b.inc:
+		if a.lower == b.lower {
+			return 0
+		} else if a.lower {
+			return 1
+		}
+		return -1
+	case !a.inc:
+		if a.lower {
+			return 1
+		}
+		return -1
+	case !b.inc:
+		if b.lower {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// Returns the range of the given bounds, or an empty one if lower is after
+// upper.
+func boundsRange[T any](lower rangeBound[T], upper rangeBound[T]) Range[T] {
+	r := Range[T]{Lower: lower.v, LowerInc: lower.inc, LowerInf: lower.inf,
+		Upper: upper.v, UpperInc: upper.inc, UpperInf: upper.inf}
+	// Bounds of canonical ranges, and their opposites, are already [) if
+	// discrete, so they never overflow.
+	r, err := r.canonical()
+	if err != nil {
+		panic(err.Error())
+	}
+	return r
+}
+
+func (r Range[T]) IsEmpty() bool {
+	return r.Empty || cmpBounds(opsOf[T](), r.lower(), r.upper()) > 0
+}
+
+// Returns r with discrete bounds made [), infinite ones exclusive and zero,
+// and all empty ranges the same. Fails if a discrete bound is the last value
+// of its type and would have to be moved past it, as Postgres does.
+func (r Range[T]) canonical() (Range[T], error) {
+	var zero T
+	ops := opsOf[T]()
+	// Bounds which are equal but not both inclusive are empty whatever they
+	// are, e.g. (2147483647,2147483647].
+	if !r.LowerInf && !r.UpperInf && ops.cmp(r.Lower, r.Upper) == 0 && !(r.LowerInc && r.UpperInc) {
+		return EmptyRange[T](), nil
+	}
+	if ops.next != nil {
+		if !r.LowerInf && !r.LowerInc {
+			next, ok := ops.next(r.Lower)
+			if !ok {
+				return Range[T]{}, fmt.Errorf("range lower bound %s out of range", ops.format(r.Lower))
+			}
+			r.Lower, r.LowerInc = next, true
+		}
+		if !r.UpperInf && r.UpperInc {
+			next, ok := ops.next(r.Upper)
+			if !ok {
+				return Range[T]{}, fmt.Errorf("range upper bound %s out of range", ops.format(r.Upper))
+			}
+			r.Upper, r.UpperInc = next, false
+		}
+	}
+	if r.LowerInf {
+		r.Lower, r.LowerInc = zero, false
+	}
+	if r.UpperInf {
+		r.Upper, r.UpperInc = zero, false
+	}
+	if r.IsEmpty() {
+		return EmptyRange[T](), nil
+	}
+	return r, nil
+}
+
+// Whether v is in r.
+func (r Range[T]) Contains(v T) bool {
+	ops := opsOf[T]()
+	return !r.IsEmpty() && cmpBounds(ops, r.lower(), rangeBound[T]{v: v, inc: true, lower: true}) <= 0 &&
+		cmpBounds(ops, r.upper(), rangeBound[T]{v: v, inc: true}) >= 0
+}
+
+// Whether all of other is in r. Empty ranges are in any range.
+func (r Range[T]) ContainsRange(other Range[T]) bool {
+	if other.IsEmpty() {
+		return true
+	} else if r.IsEmpty() {
+		return false
+	}
+	ops := opsOf[T]()
+	return cmpBounds(ops, r.lower(), other.lower()) <= 0 && cmpBounds(ops, r.upper(), other.upper()) >= 0
+}
+
+// Whether r and other have elements in common.
+func (r Range[T]) Overlaps(other Range[T]) bool {
+	if r.IsEmpty() || other.IsEmpty() {
+		return false
+	}
+	ops := opsOf[T]()
+	return cmpBounds(ops, r.lower(), other.upper()) <= 0 && cmpBounds(ops, other.lower(), r.upper()) <= 0
+}
+
+// Whether r and other don't overlap but have no elements between them, e.g.
+// [1,3) and [3,5).
+func (r Range[T]) Adjacent(other Range[T]) bool {
+	if r.IsEmpty() || other.IsEmpty() {
+		return false
+	}
+	ops := opsOf[T]()
+	adjacent := func(upper rangeBound[T], lower rangeBound[T]) bool {
+		return !upper.inf && !lower.inf && ops.cmp(upper.v, lower.v) == 0 && upper.inc != lower.inc
+	}
+	return adjacent(r.upper(), other.lower()) || adjacent(other.upper(), r.lower())
+}
+
+// Returns the elements both in r and other.
+func (r Range[T]) Intersect(other Range[T]) Range[T] {
+	if !r.Overlaps(other) {
+		return EmptyRange[T]()
+	}
+	ops := opsOf[T]()
+	lower, upper := r.lower(), r.upper()
+	if cmpBounds(ops, other.lower(), lower) > 0 {
+		lower = other.lower()
+	}
+	if cmpBounds(ops, other.upper(), upper) < 0 {
+		upper = other.upper()
+	}
+	return boundsRange(lower, upper)
+}
+
+// Returns the text form of r, e.g. [1,10) or empty.
+func (r Range[T]) String() string {
+	if r.IsEmpty() {
+		return "empty"
+	}
+	ops := opsOf[T]()
+	buf := bytes.Buffer{}
+	if r.LowerInc && !r.LowerInf {
+		buf.WriteByte('[')
+	} else {
+		buf.WriteByte('(')
+	}
+	if !r.LowerInf {
+		writeRangeBound(&buf, ops.format(r.Lower))
+	}
+	buf.WriteByte(',')
+	if !r.UpperInf {
+		writeRangeBound(&buf, ops.format(r.Upper))
+	}
+	if r.UpperInc && !r.UpperInf {
+		buf.WriteByte(']')
+	} else {
+		buf.WriteByte(')')
+	}
+	return buf.String()
+}
+
+func writeRangeBound(buf *bytes.Buffer, s string) {
+	if s != "" && strings.IndexFunc(s, func(c rune) bool {
+		return strings.ContainsRune(`,()[]{}"\`, c) || c < 128 && isArraySpace(byte(c))
+	}) < 0 {
+		buf.WriteString(s)
+		return
+	}
+	// Quotes and backslashes are doubled within quotes, as Postgres does.
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			buf.WriteByte(s[i])
+		}
+		buf.WriteByte(s[i])
+	}
+	buf.WriteByte('"')
+}
+
+func ParseRange[T any](s string) (Range[T], error) {
+	r, n, err := parseRange[T](s)
+	if err != nil {
+		return r, err
+	}
+	if strings.TrimSpace(s[n:]) != "" {
+		return Range[T]{}, fmt.Errorf("invalid range %q: unexpected %q", s, s[n:])
+	}
+	return r, nil
+}
+
+// Parses the range at the start of s, with leading spaces. Returns it and the
+// number of bytes read.
+func parseRange[T any](s string) (Range[T], int, error) {
+	invalid := func(msg string) (Range[T], int, error) {
+		return Range[T]{}, 0, fmt.Errorf("invalid range %q: %s", s, msg)
+	}
+	i := 0
+	for i < len(s) && isArraySpace(s[i]) {
+		i++
+	}
+	if len(s)-i >= 5 && strings.EqualFold(s[i:i+5], "empty") {
+		return EmptyRange[T](), i + 5, nil
+	}
+	if i == len(s) || s[i] != '[' && s[i] != '(' {
+		return invalid("expected [ or (")
+	}
+	r := Range[T]{LowerInc: s[i] == '['}
+	i++
+	var bounds [2]string
+	var inf [2]bool
+	for k := 0; k < 2; k++ {
+		buf := bytes.Buffer{}
+		quoted, inQuotes := false, false
+		for {
+			if i == len(s) {
+				return invalid("unterminated range")
+			}
+			c := s[i]
+			if c == '\\' {
+				if i+1 == len(s) {
+					return invalid("unterminated range")
+				}
+				buf.WriteByte(s[i+1])
+				i += 2
+				quoted = true
+				continue
+			}
+			if c == '"' {
+				// Doubled quotes within quotes are literal.
+				if inQuotes && i+1 < len(s) && s[i+1] == '"' {
+					buf.WriteByte('"')
+					i += 2
+					continue
+				}
+				inQuotes, quoted = !inQuotes, true
+				i++
+				continue
+			}
+			if !inQuotes && (k == 0 && c == ',' || k == 1 && (c == ']' || c == ')')) {
+				break
+			} else if !inQuotes && strings.IndexByte(",()[]", c) >= 0 {
+				return invalid(fmt.Sprintf("unexpected %q", c))
+			}
+			buf.WriteByte(c)
+			i++
+		}
+		bounds[k], inf[k] = buf.String(), buf.Len() == 0 && !quoted
+		if k == 1 {
+			r.UpperInc = s[i] == ']'
+		}
+		i++
+	}
+	ops := opsOf[T]()
+	var err error
+	if r.LowerInf = inf[0]; !r.LowerInf {
+		if r.Lower, err = ops.parse(bounds[0]); err != nil {
+			return invalid(err.Error())
+		}
+	}
+	if r.UpperInf = inf[1]; !r.UpperInf {
+		if r.Upper, err = ops.parse(bounds[1]); err != nil {
+			return invalid(err.Error())
+		}
+	}
+	if !r.LowerInf && !r.UpperInf && ops.cmp(r.Lower, r.Upper) > 0 {
+		return invalid("lower bound must be less than or equal to upper bound")
+	}
+	if r, err = r.canonical(); err != nil {
+		return invalid(err.Error())
+	}
+	return r, i, nil
+}
+
+func (r Range[T]) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+func (r *Range[T]) Scan(src interface{}) (err error) {
+	switch val := src.(type) {
+	case string:
+		*r, err = ParseRange[T](val)
+	case []byte:
+		*r, err = ParseRange[T](string(val))
+	case nil:
+		return errors.New("cannot scan NULL into a Range, use null.Value")
+	default:
+		return fmt.Errorf("cannot scan %T into a Range", src)
+	}
+	return
+}
+
+// JSON form of ranges, e.g. {"lower":1,"upper":10,"bounds":"[)"}, with no
+// lower or upper if infinite, or {"empty":true}.
+type rangeJSON struct {
+	Lower  json.RawMessage `json:"lower,omitempty"`
+	Upper  json.RawMessage `json:"upper,omitempty"`
+	Bounds string          `json:"bounds,omitempty"`
+	Empty  bool            `json:"empty,omitempty"`
+}
+
+func (r Range[T]) MarshalJSON() ([]byte, error) {
+	if r.IsEmpty() {
+		return json.Marshal(rangeJSON{Empty: true})
+	}
+	res := rangeJSON{Bounds: "()"}
+	var err error
+	if !r.LowerInf {
+		if res.Lower, err = json.Marshal(r.Lower); err != nil {
+			return nil, err
+		}
+		if r.LowerInc {
+			res.Bounds = "[" + res.Bounds[1:]
+		}
+	}
+	if !r.UpperInf {
+		if res.Upper, err = json.Marshal(r.Upper); err != nil {
+			return nil, err
+		}
+		if r.UpperInc {
+			res.Bounds = res.Bounds[:1] + "]"
+		}
+	}
+	return json.Marshal(res)
+}
+
+// Bounds default to [).
+func (r *Range[T]) UnmarshalJSON(data []byte) error {
+	tmp := rangeJSON{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	if tmp.Empty {
+		*r = EmptyRange[T]()
+		return nil
+	}
+	if tmp.Bounds == "" {
+		tmp.Bounds = "[)"
+	} else if len(tmp.Bounds) != 2 || strings.IndexByte("[(", tmp.Bounds[0]) < 0 ||
+		strings.IndexByte("])", tmp.Bounds[1]) < 0 {
+		return fmt.Errorf("invalid range bounds %q", tmp.Bounds)
+	}
+	res := Range[T]{LowerInc: tmp.Bounds[0] == '[', UpperInc: tmp.Bounds[1] == ']'}
+	isNull := func(data json.RawMessage) bool {
+		return len(data) == 0 || bytes.Equal(data, []byte("null"))
+	}
+	if res.LowerInf = isNull(tmp.Lower); !res.LowerInf {
+		if err := json.Unmarshal(tmp.Lower, &res.Lower); err != nil {
+			return err
+		}
+	}
+	if res.UpperInf = isNull(tmp.Upper); !res.UpperInf {
+		if err := json.Unmarshal(tmp.Upper, &res.Upper); err != nil {
+			return err
+		}
+	}
+	if !res.LowerInf && !res.UpperInf && opsOf[T]().cmp(res.Lower, res.Upper) > 0 {
+		return errors.New("range lower bound must be less than or equal to range upper bound")
+	}
+	res, err := res.canonical()
+	if err != nil {
+		return err
+	}
+	*r = res
+	return nil
+}
@@ -0,0 +1,162 @@
+package dbtype
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"[1,10)", "[1,10)"},
+		{" (1,10] ", "[2,11)"},
+		{"[1,1)", "empty"},
+		{"(1,2)", "empty"},
+		{"EMPTY", "empty"},
+		{"(,5]", "(,6)"},
+		{"[5,)", "[5,)"},
+		{"(,)", "(,)"},
+		{`["1","10")`, "[1,10)"},
+		{`[\1,10)`, "[1,10)"},
+	}
+	for _, c := range cases {
+		r, err := ParseRange[int32](c.src)
+		assert.NoError(t, err, c.src)
+		assert.Equal(t, c.want, r.String(), c.src)
+	}
+	for _, src := range []string{"", "[1,10", "1,10", "[10,1)", "[a,1)", "[1,2,3)", "[1,2) x", `["",1)`} {
+		_, err := ParseRange[int32](src)
+		assert.Error(t, err, src)
+	}
+
+	// Continuous ranges keep their bounds.
+	n, err := ParseRange[Decimal]("(1.5,2.50]")
+	assert.NoError(t, err)
+	assert.Equal(t, "(1.5,2.50]", n.String())
+	n, err = ParseRange[Decimal]("[1.5,1.5]")
+	assert.NoError(t, err)
+	assert.False(t, n.IsEmpty())
+	assert.True(t, n.Contains(NewDecimal(15, 1)))
+
+	ts, err := ParseRange[time.Time](`["2020-01-01 00:00:00+00","2020-01-02 12:30:00+02")`)
+	assert.NoError(t, err)
+	assert.True(t, ts.Upper.Equal(time.Date(2020, 1, 2, 10, 30, 0, 0, time.UTC)))
+	assert.Equal(t, "[2020-01-01T00:00:00Z,2020-01-02T12:30:00+02:00)", ts.String())
+
+	d, err := ParseRange[Date]("[2020-01-01,2020-01-31]")
+	assert.NoError(t, err)
+	assert.Equal(t, "[2020-01-01,2020-02-01)", d.String())
+}
+
+// Discrete bounds which can't be made [) are errors, as in Postgres.
+func TestRange_Overflow(t *testing.T) {
+	assert.Panics(t, func() { NewRange[int32](1, math.MaxInt32, "[]") })
+	assert.Panics(t, func() { NewRange[int64](math.MaxInt64-1, math.MaxInt64, "(]") })
+	assert.Equal(t, "[1,2147483647)", NewRange[int32](1, math.MaxInt32, "[)").String())
+	assert.Equal(t, "[-2147483648,0)", NewRange[int32](math.MinInt32, 0, "[)").String())
+	// Equal bounds which aren't both inclusive are empty before anything else.
+	assert.True(t, NewRange[int32](math.MaxInt32, math.MaxInt32, "(]").IsEmpty())
+
+	_, err := ParseRange[int32]("[1,2147483647]")
+	assert.Error(t, err)
+	_, err = ParseRange[int64]("(9223372036854775807,)")
+	assert.Error(t, err)
+	r := Int4Range{}
+	assert.Error(t, r.Scan("[0,2147483647]"))
+	assert.Error(t, json.Unmarshal([]byte(`{"lower":0,"upper":2147483647,"bounds":"[]"}`), &r))
+}
+
+func TestRange_Ops(t *testing.T) {
+	r := NewRange[int64](1, 10, "[)")
+	assert.True(t, r.Contains(1))
+	assert.True(t, r.Contains(9))
+	assert.False(t, r.Contains(10))
+	assert.False(t, EmptyRange[int64]().Contains(0))
+	assert.Equal(t, NewRange[int64](2, 11, "[)"), NewRange[int64](1, 10, "(]"))
+	assert.True(t, NewRange[int64](1, 1, "[)").IsEmpty())
+	assert.True(t, Range[int64]{}.IsEmpty())
+	assert.Panics(t, func() { NewRange[int64](2, 1, "[)") })
+	assert.Panics(t, func() { NewRange[int64](1, 2, "[") })
+
+	unbounded := Range[int64]{LowerInf: true, Upper: 5}
+	assert.True(t, unbounded.Contains(-1000))
+	assert.False(t, unbounded.Contains(5))
+	assert.True(t, Range[int64]{LowerInf: true, UpperInf: true}.ContainsRange(r))
+	assert.True(t, r.ContainsRange(NewRange[int64](2, 5, "[)")))
+	assert.True(t, r.ContainsRange(EmptyRange[int64]()))
+	assert.False(t, r.ContainsRange(unbounded))
+
+	assert.True(t, r.Overlaps(unbounded))
+	assert.False(t, r.Overlaps(NewRange[int64](10, 20, "[)")))
+	assert.False(t, r.Overlaps(EmptyRange[int64]()))
+	assert.True(t, r.Adjacent(NewRange[int64](10, 20, "[)")))
+	assert.True(t, r.Adjacent(Range[int64]{LowerInf: true, Upper: 1}))
+	assert.False(t, r.Adjacent(NewRange[int64](9, 20, "[)")))
+
+	assert.Equal(t, "[1,5)", r.Intersect(unbounded).String())
+	assert.Equal(t, "[3,4)", r.Intersect(NewRange[int64](3, 4, "[)")).String())
+	assert.True(t, r.Intersect(NewRange[int64](10, 20, "[)")).IsEmpty())
+
+	// Bounds of the same value meet if one of them is inclusive.
+	a := NewRange(NewDecimal(1, 0), NewDecimal(2, 0), "[]")
+	b := NewRange(NewDecimal(2, 0), NewDecimal(3, 0), "(]")
+	assert.False(t, a.Overlaps(b))
+	assert.True(t, a.Adjacent(b))
+	c := NewRange(NewDecimal(2, 0), NewDecimal(3, 0), "[]")
+	assert.True(t, a.Overlaps(c))
+	assert.Equal(t, "[2,2]", a.Intersect(c).String())
+}
+
+func TestRange_SQL(t *testing.T) {
+	r := Int4Range{}
+	assert.NoError(t, r.Scan([]byte("[1,5)")))
+	assert.Equal(t, NewRange[int32](1, 5, "[)"), r)
+	v, err := r.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "[1,5)", v)
+	assert.Error(t, r.Scan(nil))
+	assert.Error(t, r.Scan("[5,1)"))
+
+	v, err = EmptyRange[int32]().Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "empty", v)
+}
+
+func TestRange_JSON(t *testing.T) {
+	cases := []struct {
+		r    interface{}
+		want string
+	}{
+		{NewRange[int32](1, 5, "[)"), `{"lower":1,"upper":5,"bounds":"[)"}`},
+		{EmptyRange[int32](), `{"empty":true}`},
+		{Range[int32]{LowerInf: true, UpperInf: true}, `{"bounds":"()"}`},
+		{NewRange(NewDecimal(15, 1), NewDecimal(2, 0), "(]"), `{"lower":1.5,"upper":2,"bounds":"(]"}`},
+		{NewRange(Date{2020, 1, 1}, Date{2020, 2, 1}, "[)"), `{"lower":"2020-01-01","upper":"2020-02-01","bounds":"[)"}`},
+	}
+	for _, c := range cases {
+		data, err := json.Marshal(c.r)
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, string(data))
+	}
+
+	r := Int4Range{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"lower":1,"upper":5,"bounds":"[]"}`), &r))
+	assert.Equal(t, NewRange[int32](1, 6, "[)"), r)
+	assert.NoError(t, json.Unmarshal([]byte(`{"upper":5}`), &r))
+	assert.Equal(t, Range[int32]{LowerInf: true, Upper: 5}, r)
+	assert.NoError(t, json.Unmarshal([]byte(`{"lower":null,"upper":null}`), &r))
+	assert.Equal(t, Range[int32]{LowerInf: true, UpperInf: true}, r)
+	assert.NoError(t, json.Unmarshal([]byte(`{"empty":true}`), &r))
+	assert.True(t, r.IsEmpty())
+	assert.Error(t, json.Unmarshal([]byte(`{"lower":5,"upper":1}`), &r))
+	assert.Error(t, json.Unmarshal([]byte(`{"lower":1,"bounds":"[["}`), &r))
+
+	ts := TstzRange{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"lower":"2020-01-01T00:00:00Z"}`), &ts))
+	assert.True(t, ts.Contains(time.Now()))
+}
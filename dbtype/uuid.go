@@ -0,0 +1,74 @@
+package dbtype
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// UUID is a uuid column, kept as its 16 bytes.
+type UUID [16]byte
+
+// Parses the text form of a UUID, with or without hyphens and braces, in any
+// case, as Postgres does.
+func ParseUUID(s string) (UUID, error) {
+	var res UUID
+	str := strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	if len(str) != len(s) && len(str) != len(s)-2 {
+		return res, fmt.Errorf("invalid UUID %q", s)
+	}
+	str = strings.Replace(str, "-", "", -1)
+	if len(str) != 32 {
+		return res, fmt.Errorf("invalid UUID %q", s)
+	}
+	if _, err := hex.Decode(res[:], []byte(str)); err != nil {
+		return res, fmt.Errorf("invalid UUID %q", s)
+	}
+	return res, nil
+}
+
+// Returns the canonical form of u, e.g. a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11.
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf, u[:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf)
+}
+
+func (u UUID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+// Accepts the text form, or the 16 bytes of binary results.
+func (u *UUID) Scan(src interface{}) (err error) {
+	switch val := src.(type) {
+	case string:
+		*u, err = ParseUUID(val)
+	case []byte:
+		if len(val) == 16 {
+			copy(u[:], val)
+			return nil
+		}
+		*u, err = ParseUUID(string(val))
+	default:
+		return fmt.Errorf("cannot scan %T into a UUID", src)
+	}
+	return
+}
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *UUID) UnmarshalText(data []byte) (err error) {
+	*u, err = ParseUUID(string(data))
+	return
+}
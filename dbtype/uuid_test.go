@@ -0,0 +1,40 @@
+package dbtype
+
+import (
+	"encoding/json"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUUID(t *testing.T) {
+	const str = "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"
+	for _, src := range []string{str, "A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11",
+		"{a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11}", "a0eebc999c0b4ef8bb6d6bb9bd380a11",
+		"a0ee-bc99-9c0b-4ef8-bb6d-6bb9-bd38-0a11"} {
+		u, err := ParseUUID(src)
+		assert.NoError(t, err, src)
+		assert.Equal(t, str, u.String())
+	}
+	for _, src := range []string{"", "a0eebc99", "{a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11",
+		"g0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"} {
+		_, err := ParseUUID(src)
+		assert.Error(t, err, src)
+	}
+
+	u := UUID{}
+	assert.NoError(t, u.Scan([]byte(str)))
+	v, err := u.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, str, v)
+	raw := u
+	assert.NoError(t, u.Scan(raw[:]))
+	assert.Equal(t, raw, u)
+	assert.Error(t, u.Scan(1))
+
+	s := struct{ U UUID }{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"U":"`+str+`"}`), &s))
+	assert.Equal(t, raw, s.U)
+	data, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"U":"`+str+`"}`, string(data))
+}
@@ -2,7 +2,6 @@ package dynamic
 
 import (
 	"reflect"
-	"github.com/lib/pq"
 	"time"
 	"github.com/tsealex/dbutil/null"
 	"github.com/tsealex/dbutil/dbtype"
@@ -59,21 +58,24 @@ func NewIntField(name string, nullable bool, editable bool, bits int) *IntField
 	f.name = name
 	f.nullable = nullable
 	f.editable = editable
-	if f.nullable {
-		// Set fieldType to be 64-bit null integer.
-		f.bits = 64
-		f.fieldType = reflect.TypeOf(null.Int64{})
+	// Set fieldType to be the corresponding int type.
+	if bits <= 16 {
+		f.bits = 16
+		f.fieldType = reflect.TypeOf(int16(0))
+		if f.nullable {
+			f.fieldType = reflect.TypeOf(null.Int16{})
+		}
+	} else if bits <= 32 {
+		f.bits = 32
+		f.fieldType = reflect.TypeOf(int32(0))
+		if f.nullable {
+			f.fieldType = reflect.TypeOf(null.Int32{})
+		}
 	} else {
-		// Set fieldType to be the corresponding primitive int type.
-		if bits <= 16 {
-			f.bits = 16
-			f.fieldType = reflect.TypeOf(int16(0))
-		} else if bits <= 32 {
-			f.bits = 32
-			f.fieldType = reflect.TypeOf(int32(0))
-		} else {
-			f.bits = 64
-			f.fieldType = reflect.TypeOf(int64(0))
+		f.bits = 64
+		f.fieldType = reflect.TypeOf(int64(0))
+		if f.nullable {
+			f.fieldType = reflect.TypeOf(null.Int64{})
 		}
 	}
 	return &f
@@ -98,18 +100,18 @@ func NewFloatField(name string, nullable bool, editable bool, bits int) *FloatFi
 	f.name = name
 	f.nullable = nullable
 	f.editable = editable
-	if f.nullable {
-		// TODO: set fieldType to be 64-bit null float.
-		f.bits = 64
-		f.fieldType = reflect.TypeOf(null.Float64{})
+	// Set fieldType to be the corresponding float type.
+	if bits <= 32 {
+		f.bits = 32
+		f.fieldType = reflect.TypeOf(float32(0))
+		if f.nullable {
+			f.fieldType = reflect.TypeOf(null.Float32{})
+		}
 	} else {
-		// Set fieldType to be the corresponding primitive int type.
-		if bits <= 32 {
-			f.bits = 32
-			f.fieldType = reflect.TypeOf(float32(0))
-		} else {
-			f.bits = 64
-			f.fieldType = reflect.TypeOf(float64(0))
+		f.bits = 64
+		f.fieldType = reflect.TypeOf(float64(0))
+		if f.nullable {
+			f.fieldType = reflect.TypeOf(null.Float64{})
 		}
 	}
 	return &f
@@ -130,8 +132,7 @@ func NewStringField(name string, nullable bool, editable bool) *StringField {
 	f.nullable = nullable
 	f.editable = editable
 	if f.nullable {
-		// TODO: set fieldType to be null string.
-		f.fieldType = reflect.TypeOf(null.Float64{})
+		f.fieldType = reflect.TypeOf(null.String{})
 	} else {
 		// Set fieldType to be the corresponding primitive int type.
 		f.fieldType = reflect.TypeOf(string(""))
@@ -179,7 +180,7 @@ func NewTimeField(name string, nullable bool, editable bool) *TimeField {
 	f.editable = editable
 	if f.nullable {
 		// TODO: set fieldType to be null bool.
-		f.fieldType = reflect.TypeOf(pq.NullTime{})
+		f.fieldType = reflect.TypeOf(null.Time{})
 	} else {
 		// Set fieldType to be the corresponding primitive int type.
 		f.fieldType = reflect.TypeOf(time.Time{})
@@ -235,6 +236,110 @@ func NewJsonbField(name string, nullable bool, editable bool) *JsonbField {
 	return &f
 }
 
+type BytesField struct {
+	BaseField
+}
+
+// A bytea field.
+func NewBytesField(name string, nullable bool, editable bool) *BytesField {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+	f := BytesField{}
+	f.name = name
+	f.nullable = nullable
+	f.editable = editable
+	if f.nullable {
+		f.fieldType = reflect.TypeOf(null.Bytes{})
+	} else {
+		f.fieldType = reflect.TypeOf([]byte{})
+	}
+	return &f
+}
+
+type UUIDField struct {
+	BaseField
+}
+
+func NewUUIDField(name string, nullable bool, editable bool) *UUIDField {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+	f := UUIDField{}
+	f.name = name
+	f.nullable = nullable
+	f.editable = editable
+	if f.nullable {
+		f.fieldType = reflect.TypeOf(null.UUID{})
+	} else {
+		f.fieldType = reflect.TypeOf(dbtype.UUID{})
+	}
+	return &f
+}
+
+type DecimalField struct {
+	BaseField
+}
+
+// A numeric field, with no rounding.
+func NewDecimalField(name string, nullable bool, editable bool) *DecimalField {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+	f := DecimalField{}
+	f.name = name
+	f.nullable = nullable
+	f.editable = editable
+	if f.nullable {
+		f.fieldType = reflect.TypeOf(null.Decimal{})
+	} else {
+		f.fieldType = reflect.TypeOf(dbtype.Decimal{})
+	}
+	return &f
+}
+
+type DurationField struct {
+	BaseField
+}
+
+// An interval field, of no months.
+func NewDurationField(name string, nullable bool, editable bool) *DurationField {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+	f := DurationField{}
+	f.name = name
+	f.nullable = nullable
+	f.editable = editable
+	if f.nullable {
+		f.fieldType = reflect.TypeOf(null.Duration{})
+	} else {
+		f.fieldType = reflect.TypeOf(dbtype.Duration(0))
+	}
+	return &f
+}
+
+type IntervalField struct {
+	BaseField
+}
+
+// An interval field, of months, days and microseconds.
+func NewIntervalField(name string, nullable bool, editable bool) *IntervalField {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+	f := IntervalField{}
+	f.name = name
+	f.nullable = nullable
+	f.editable = editable
+	if f.nullable {
+		f.fieldType = reflect.TypeOf(null.Interval{})
+	} else {
+		f.fieldType = reflect.TypeOf(dbtype.Interval{})
+	}
+	return &f
+}
+
 // Array types.
 type StringArrayField struct {
 	BaseField
@@ -299,3 +404,86 @@ func NewBoolArrayField(name string, nullable bool, editable bool) *BoolArrayFiel
 	f.fieldType = reflect.TypeOf(null.BoolArray{})
 	return &f
 }
+
+type TimeArrayField struct {
+	BaseField
+}
+
+// A timestamptz[] field.
+func NewTimeArrayField(name string, nullable bool, editable bool) *TimeArrayField {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+	f := TimeArrayField{}
+	f.name = name
+	f.nullable = nullable
+	f.editable = editable
+	f.fieldType = reflect.TypeOf(null.TimeArray{})
+	return &f
+}
+
+type UUIDArrayField struct {
+	BaseField
+}
+
+func NewUUIDArrayField(name string, nullable bool, editable bool) *UUIDArrayField {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+	f := UUIDArrayField{}
+	f.name = name
+	f.nullable = nullable
+	f.editable = editable
+	f.fieldType = reflect.TypeOf(null.UUIDArray{})
+	return &f
+}
+
+type JsonbArrayField struct {
+	BaseField
+}
+
+func NewJsonbArrayField(name string, nullable bool, editable bool) *JsonbArrayField {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+	f := JsonbArrayField{}
+	f.name = name
+	f.nullable = nullable
+	f.editable = editable
+	f.fieldType = reflect.TypeOf(null.JsonbArray{})
+	return &f
+}
+
+type PointArrayField struct {
+	BaseField
+}
+
+// A geography(Point)[] field.
+func NewPointArrayField(name string, nullable bool, editable bool) *PointArrayField {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+	f := PointArrayField{}
+	f.name = name
+	f.nullable = nullable
+	f.editable = editable
+	f.fieldType = reflect.TypeOf(null.PointArray{})
+	return &f
+}
+
+type DecimalArrayField struct {
+	BaseField
+}
+
+// A numeric[] field.
+func NewDecimalArrayField(name string, nullable bool, editable bool) *DecimalArrayField {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+	f := DecimalArrayField{}
+	f.name = name
+	f.nullable = nullable
+	f.editable = editable
+	f.fieldType = reflect.TypeOf(null.DecimalArray{})
+	return &f
+}
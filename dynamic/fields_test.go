@@ -1 +1,50 @@
 package dynamic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/dbtype"
+	"github.com/tsealex/dbutil/null"
+)
+
+func TestFieldTypes(t *testing.T) {
+	cases := []struct {
+		f    AbstractField
+		want interface{}
+	}{
+		{NewIntField("A", false, true, 8), int16(0)},
+		{NewIntField("A", true, true, 8), null.Int16{}},
+		{NewIntField("A", true, true, 32), null.Int32{}},
+		{NewIntField("A", true, true, 64), null.Int64{}},
+		{NewFloatField("A", false, true, 32), float32(0)},
+		{NewFloatField("A", true, true, 32), null.Float32{}},
+		{NewFloatField("A", true, true, 64), null.Float64{}},
+		{NewStringField("A", true, true), null.String{}},
+		{NewTimeField("A", true, true), null.Time{}},
+		{NewBytesField("A", false, true), []byte{}},
+		{NewBytesField("A", true, true), null.Bytes{}},
+		{NewUUIDField("A", false, true), dbtype.UUID{}},
+		{NewUUIDField("A", true, true), null.UUID{}},
+		{NewDecimalField("A", false, true), dbtype.Decimal{}},
+		{NewDecimalField("A", true, true), null.Decimal{}},
+		{NewDurationField("A", false, true), dbtype.Duration(0)},
+		{NewDurationField("A", true, true), null.Duration{}},
+		{NewIntervalField("A", false, true), dbtype.Interval{}},
+		{NewIntervalField("A", true, true), null.Interval{}},
+		{NewTimeArrayField("A", true, true), null.TimeArray{}},
+		{NewUUIDArrayField("A", true, true), null.UUIDArray{}},
+		{NewJsonbArrayField("A", true, true), null.JsonbArray{}},
+		{NewPointArrayField("A", true, true), null.PointArray{}},
+		{NewDecimalArrayField("A", true, true), null.DecimalArray{}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, reflect.TypeOf(c.want), c.f.Type())
+	}
+	// Patches have an Optional field for each of them.
+	for _, c := range cases {
+		assert.True(t, strings.HasPrefix(patchType(c.f.Type()).Name(), "Optional["),
+			c.f.Type().String())
+	}
+}
@@ -24,12 +24,14 @@ type Object struct {
 	modelSlice    reflect.Type
 	editable      reflect.Type
 	editableSlice reflect.Type
+	patch         reflect.Type
 }
 
 func NewObject(fields ... AbstractField) *Object {
 	obj := Object{}
 	structFields := make([]reflect.StructField, len(fields))
 	editableFields := make([]reflect.StructField, 0, len(fields))
+	patchFields := make([]reflect.StructField, 0, len(fields))
 	for i, field := range fields {
 		name := field.Name()
 		structFields[i] = reflect.StructField{
@@ -43,12 +45,19 @@ func NewObject(fields ... AbstractField) *Object {
 				Type:      reflect.PtrTo(field.Type()),
 				Anonymous: false,
 			})
+			patchFields = append(patchFields, reflect.StructField{
+				Name:      name,
+				Type:      patchType(field.Type()),
+				Tag:       patchTag(field),
+				Anonymous: false,
+			})
 		}
 	}
 	obj.model = reflect.StructOf(structFields)
 	obj.modelSlice = reflect.SliceOf(obj.model)
 	obj.editable = reflect.StructOf(editableFields)
 	obj.editableSlice = reflect.SliceOf(obj.editable)
+	obj.patch = reflect.StructOf(patchFields)
 	return &obj
 }
 
@@ -74,6 +83,14 @@ func (o *Object) CreateEditableSlice() ObjectPointer {
 	return reflect.New(o.editableSlice).Interface()
 }
 
+// Returns a pointer to an instance of the editable fields of this Object as
+// null.Optional values, which tell fields left out of JSON from those set to
+// null, e.g. for PATCH requests. Those of NOT NULL fields are tagged so that
+// clause.SQLRecipe.WritePatch refuses to write null to them.
+func (o *Object) CreatePatch() ObjectPointer {
+	return reflect.New(o.patch).Interface()
+}
+
 func GetField(ptr ObjectPointer, name string) (interface{}, error) {
 	if val := reflect.ValueOf(ptr); val.Kind() != reflect.Ptr {
 		return nil, fmt.Errorf("ptr is not a pointer")
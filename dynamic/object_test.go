@@ -7,6 +7,8 @@ import (
 	"github.com/tsealex/dbutil/null"
 	"database/sql"
 	"github.com/jmoiron/sqlx"
+	"github.com/tsealex/dbutil/query/clause"
+	"github.com/tsealex/dbutil/query/exp"
 )
 
 func TestNewObject(t *testing.T) {
@@ -90,4 +92,44 @@ func TestNewObject(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Nil(t, f)
 
-}
\ No newline at end of file
+}
+func TestObject_CreatePatch(t *testing.T) {
+	obj := NewObject(NewIntField("Id", false, false, 64),
+		NewIntField("Age", true, true, 32),
+		NewStringField("Name", true, true),
+		NewTimeField("Born", false, true),
+		NewBoolField("Admin", false, true))
+	patch := obj.CreatePatch()
+	assert.NoError(t, json.Unmarshal([]byte(`{"Age":null,"Name":"x"}`), patch))
+
+	_, err := GetField(patch, "Id")
+	assert.Error(t, err)
+	f, err := GetField(patch, "Age")
+	assert.NoError(t, err)
+	assert.Equal(t, null.None[int32](), f)
+	f, err = GetField(patch, "Name")
+	assert.NoError(t, err)
+	assert.Equal(t, null.Some("x"), f)
+	f, err = GetField(patch, "Admin")
+	assert.NoError(t, err)
+	assert.False(t, f.(null.Optional[bool]).IsPresent())
+
+	r := clause.SQL()
+	args, err := r.WritePatch(patch)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{null.None[int32](), null.Some("x")}, args)
+	q, err := r.Update(exp.Relation("t"))
+	assert.NoError(t, err)
+	assert.Equal(t, `UPDATE t SET age=$1,name=$2`, q)
+
+	// Only nullable fields may be set to null.
+	patch = obj.CreatePatch()
+	assert.NoError(t, json.Unmarshal([]byte(`{"Admin":null}`), patch))
+	_, err = clause.SQL().WritePatch(patch)
+	assert.Error(t, err)
+	patch = obj.CreatePatch()
+	assert.NoError(t, json.Unmarshal([]byte(`{"Admin":false}`), patch))
+	args, err = clause.SQL().WritePatch(patch)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{null.Some(false)}, args)
+}
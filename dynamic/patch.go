@@ -0,0 +1,94 @@
+package dynamic
+
+import (
+	"reflect"
+	"time"
+	"github.com/lib/pq"
+	"github.com/tsealex/dbutil/dbtype"
+	"github.com/tsealex/dbutil/dbtype/geom"
+	"github.com/tsealex/dbutil/null"
+)
+
+// Types of the fields of patches, by the type of the fields they patch.
+var optionalTypes = map[reflect.Type]reflect.Type{}
+
+func registerOptional[T any]() {
+	opt := reflect.TypeOf(null.Optional[T]{})
+	optionalTypes[reflect.TypeOf((*T)(nil)).Elem()] = opt
+	optionalTypes[reflect.TypeOf(null.Value[T]{})] = opt
+}
+
+// The null types which predate null.Value are patched like the Value of their
+// value.
+func registerNamed[T any](named interface{}) {
+	optionalTypes[reflect.TypeOf(named)] = reflect.TypeOf(null.Optional[T]{})
+}
+
+func init() {
+	registerOptional[int16]()
+	registerOptional[int32]()
+	registerOptional[int64]()
+	registerOptional[float32]()
+	registerOptional[float64]()
+	registerOptional[string]()
+	registerOptional[bool]()
+	registerOptional[time.Time]()
+	registerOptional[[]byte]()
+	registerOptional[dbtype.UUID]()
+	registerOptional[dbtype.Decimal]()
+	registerOptional[dbtype.Duration]()
+	registerOptional[dbtype.Interval]()
+	registerOptional[dbtype.Date]()
+	registerOptional[dbtype.Int4Range]()
+	registerOptional[dbtype.Int8Range]()
+	registerOptional[dbtype.NumRange]()
+	registerOptional[dbtype.TstzRange]()
+	registerOptional[dbtype.DateRange]()
+	registerOptional[dbtype.Int4Multirange]()
+	registerOptional[dbtype.Int8Multirange]()
+	registerOptional[dbtype.NumMultirange]()
+	registerOptional[dbtype.TstzMultirange]()
+	registerOptional[dbtype.DateMultirange]()
+	registerOptional[dbtype.Point]()
+	registerOptional[geom.Any]()
+	registerOptional[dbtype.Jsonb]()
+	registerOptional[dbtype.JsonbValue]()
+	registerOptional[pq.Int64Array]()
+	registerOptional[pq.Float64Array]()
+	registerOptional[pq.BoolArray]()
+	registerOptional[pq.StringArray]()
+	registerOptional[dbtype.TimeArray]()
+	registerOptional[dbtype.UUIDArray]()
+	registerOptional[dbtype.JsonbArray]()
+	registerOptional[dbtype.PointArray]()
+	registerOptional[dbtype.DecimalArray]()
+	registerNamed[string](null.String{})
+	registerNamed[int64](null.Int64{})
+	registerNamed[bool](null.Bool{})
+	registerNamed[float64](null.Float64{})
+	registerNamed[time.Time](null.Time{})
+	registerNamed[dbtype.Point](null.Point{})
+	registerNamed[dbtype.JsonbValue](null.Jsonb{})
+	registerNamed[pq.Int64Array](null.Int64Array{})
+	registerNamed[pq.Float64Array](null.Float64Array{})
+	registerNamed[pq.BoolArray](null.BoolArray{})
+	registerNamed[pq.StringArray](null.StringArray{})
+}
+
+// Fields of types with no null.Optional counterpart are pointers, which tell
+// absent fields from present ones but not from null ones.
+func patchType(t reflect.Type) reflect.Type {
+	if opt, ok := optionalTypes[t]; ok {
+		return opt
+	}
+	return reflect.PtrTo(t)
+}
+
+// Marks the patch fields of NOT NULL columns, which null.Optional values can
+// still set to null, for clause.SQLRecipe.WritePatch.
+func patchTag(field AbstractField) reflect.StructTag {
+	if field.Nullable() {
+		return ""
+	}
+	return `db:",notnull"`
+}
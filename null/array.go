@@ -1,215 +1,398 @@
 package null
 
 import (
-	"github.com/lib/pq"
+	"bytes"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/lib/pq"
+	"github.com/tsealex/dbutil/dbtype"
 )
 
-/////////
-// 
-type Int64Array struct {
-	pq.Int64Array
-	Valid bool
+// Array is an array of any number of dimensions whose elements may be NULL,
+// e.g. {{1,NULL},{3,4}}. Elems are in row-major order. Arrays of no Dims but
+// some Elems are one-dimensional. In JSON, arrays are nested lists, with null
+// for NULL elements, and lose their lower bounds; nested lists are always read
+// as dimensions, so elements can't be JSON arrays themselves.
+//
+// An Array is never NULL itself, Value[Array[T]] is.
+type Array[T any] struct {
+	Elems []Value[T]
+	Dims  []dbtype.ArrayDim
 }
 
-func (p *Int64Array) Scan(value interface{}) error {
-	if value == nil {
-		p.Int64Array, p.Valid = []int64{}, false
-		return nil
+// Returns a one-dimensional array.
+func NewArray[T any](elems ... Value[T]) Array[T] {
+	return Array[T]{Elems: elems, Dims: []dbtype.ArrayDim{{Len: len(elems), Lower: 1}}}
+}
+
+func (a Array[T]) dims() []dbtype.ArrayDim {
+	if a.Dims == nil && len(a.Elems) > 0 {
+		return []dbtype.ArrayDim{{Len: len(a.Elems), Lower: 1}}
 	}
-	p.Valid = true
-	return p.Int64Array.Scan(value)
+	return a.Dims
 }
 
-// Value implements the driver Valuer interface.
-func (p Int64Array) Value() (driver.Value, error) {
-	if !p.Valid {
-		return nil, nil // a hack to make sure no array is null in db
+// Returns the element at the given indexes, one per dimension, which start
+// from the lower bounds of the dimensions. Panics if out of range.
+func (a Array[T]) At(idx ... int) Value[T] {
+	dims := a.dims()
+	if len(idx) != len(dims) {
+		panic(fmt.Sprintf("%d indexes given for an array of %d dimensions", len(idx), len(dims)))
 	}
-	if p.Int64Array == nil {
-		return "{}", nil
+	pos := 0
+	for i, d := range dims {
+		if idx[i] < d.Lower || idx[i] >= d.Lower+d.Len {
+			panic(fmt.Sprintf("index %d out of range [%d:%d]", idx[i], d.Lower, d.Lower+d.Len-1))
+		}
+		pos = pos*d.Len + idx[i] - d.Lower
 	}
-	return p.Int64Array.Value()
+	return a.Elems[pos]
 }
 
-func (v Int64Array) MarshalJSON() ([]byte, error) {
-	if v.Valid {
-		return json.Marshal(v.Int64Array)
+// PostGIS types separate array elements with ':'.
+func arrayDelim[T any]() byte {
+	var zero T
+	if _, ok := interface{}(zero).(dbtype.Point); ok {
+		return ':'
 	}
-	return json.Marshal(nil)
+	return ','
 }
 
-func (v *Int64Array) UnmarshalJSON(data []byte) error {
-	var val *[]int64
-	if err := json.Unmarshal(data, &val); err != nil {
+func (a *Array[T]) Scan(src interface{}) error {
+	var str string
+	switch val := src.(type) {
+	case string:
+		str = val
+	case []byte:
+		str = string(val)
+	case nil:
+		return errors.New("cannot scan NULL into an Array, use null.Value")
+	default:
+		return fmt.Errorf("cannot scan %T into an Array", src)
+	}
+	dims, elems, err := dbtype.ParseArray(str, arrayDelim[T]())
+	if err != nil {
 		return err
 	}
-	if val != nil {
-		v.Valid = true
-		v.Int64Array = *val
-	} else {
-		v.Valid = false
+	res := make([]Value[T], len(elems))
+	for i, e := range elems {
+		if e == nil {
+			continue
+		}
+		if err := scanElem(&res[i], *e); err != nil {
+			return err
+		}
+	}
+	a.Elems, a.Dims = res, dims
+	return nil
+}
+
+// Reads the text form of an element, which database/sql does not convert
+// itself for times and bytea.
+func scanElem[T any](v *Value[T], s string) (err error) {
+	switch p := interface{}(&v.V).(type) {
+	case *time.Time:
+		*p, err = dbtype.ParseTime(s)
+	case *[]byte:
+		if !strings.HasPrefix(s, `\x`) {
+			return fmt.Errorf("invalid bytea %q", s)
+		}
+		*p, err = hex.DecodeString(s[2:])
+	default:
+		return v.Scan([]byte(s))
+	}
+	v.Valid = err == nil
+	return
+}
+
+func (a Array[T]) Value() (driver.Value, error) {
+	var zero T
+	_, bytea := interface{}(zero).([]byte)
+	bits := 64
+	if reflect.TypeOf(&zero).Elem().Kind() == reflect.Float32 {
+		bits = 32
+	}
+	elems := make([]*string, len(a.Elems))
+	for i, e := range a.Elems {
+		v, err := e.Value()
+		if err != nil {
+			return nil, err
+		} else if v == nil {
+			continue
+		}
+		var str string
+		switch val := v.(type) {
+		case int64:
+			str = strconv.FormatInt(val, 10)
+		case float64:
+			switch {
+			case math.IsNaN(val):
+				str = "NaN"
+			case math.IsInf(val, 1):
+				str = "Infinity"
+			case math.IsInf(val, -1):
+				str = "-Infinity"
+			default:
+				str = strconv.FormatFloat(val, 'g', -1, bits)
+			}
+		case bool:
+			str = "f"
+			if val {
+				str = "t"
+			}
+		case []byte:
+			str = string(val)
+			if bytea {
+				str = `\x` + hex.EncodeToString(val)
+			}
+		case string:
+			str = val
+		case time.Time:
+			str = val.Format(time.RFC3339Nano)
+		default:
+			return nil, fmt.Errorf("cannot write %T as an array element", v)
+		}
+		elems[i] = &str
+	}
+	return dbtype.FormatArray(a.dims(), elems, arrayDelim[T]())
+}
+
+func (a Array[T]) MarshalJSON() ([]byte, error) {
+	dims := a.dims()
+	if len(dims) == 0 {
+		return []byte("[]"), nil
+	}
+	size := 1
+	for _, d := range dims {
+		size *= d.Len
+	}
+	if size != len(a.Elems) {
+		return nil, fmt.Errorf("%d elements given for an array of %d", len(a.Elems), size)
+	}
+	buf := bytes.Buffer{}
+	if err := marshalSubArray(&buf, dims, a.Elems); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalSubArray[T any](buf *bytes.Buffer, dims []dbtype.ArrayDim, elems []Value[T]) error {
+	buf.WriteByte('[')
+	step := len(elems) / dims[0].Len
+	for i := 0; i < dims[0].Len; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if len(dims) > 1 {
+			if err := marshalSubArray(buf, dims[1:], elems[i*step:(i+1)*step]); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := elems[i].MarshalJSON()
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+func (a *Array[T]) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		a.Elems, a.Dims = nil, nil
+		return nil
+	}
+	u := arrayUnmarshaler[T]{depth: -1}
+	if err := u.unmarshal(data, 0); err != nil {
+		return err
+	}
+	a.Elems, a.Dims = u.elems, nil
+	if len(u.elems) > 0 {
+		a.Dims = make([]dbtype.ArrayDim, len(u.lens))
+		for i, n := range u.lens {
+			a.Dims[i] = dbtype.ArrayDim{Len: n, Lower: 1}
+		}
+	}
+	return nil
+}
+
+type arrayUnmarshaler[T any] struct {
+	lens  []int
+	depth int // Of elements, -1 until one is seen.
+	elems []Value[T]
+}
+
+func (u *arrayUnmarshaler[T]) unmarshal(data []byte, depth int) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		if depth == 0 {
+			return errors.New("invalid array: not a JSON array")
+		} else if u.depth < 0 {
+			u.depth = depth
+		} else if u.depth != depth {
+			return errors.New("invalid array: sub-arrays must have matching dimensions")
+		}
+		v := Value[T]{}
+		if err := v.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		u.elems = append(u.elems, v)
+		return nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	if len(items) == 0 && depth > 0 {
+		return errors.New("invalid array: empty sub-array")
+	}
+	for _, item := range items {
+		if err := u.unmarshal(item, depth+1); err != nil {
+			return err
+		}
+	}
+	for len(u.lens) <= depth {
+		u.lens = append(u.lens, -1)
+	}
+	if u.lens[depth] < 0 {
+		u.lens[depth] = len(items)
+	} else if u.lens[depth] != len(items) {
+		return errors.New("invalid array: sub-arrays must have matching dimensions")
 	}
 	return nil
 }
 
-func (v *Int64Array) IsValid() bool {
+// Valid arrays are never written as NULL, even if nil.
+
+/////////
+//
+type Int64Array struct {
+	pq.Int64Array
+	Valid bool
+}
+
+func (v *Int64Array) Scan(src interface{}) error {
+	return scanInto(&v.Int64Array, &v.Valid, src)
+}
+
+// Value implements the driver Valuer interface.
+func (v Int64Array) Value() (driver.Value, error) {
+	return valueOf(v.Int64Array, v.Valid).Value()
+}
+
+func (v Int64Array) MarshalJSON() ([]byte, error) {
+	return valueOf(v.Int64Array, v.Valid).MarshalJSON()
+}
+
+func (v *Int64Array) UnmarshalJSON(data []byte) error {
+	return unmarshalInto(&v.Int64Array, &v.Valid, data)
+}
+
+func (v Int64Array) IsValid() bool {
 	return v.Valid
 }
 
 /////////
-// 
+//
 type Float64Array struct {
 	pq.Float64Array
 	Valid bool
 }
 
-func (p *Float64Array) Scan(value interface{}) error {
-	if value == nil {
-		p.Float64Array, p.Valid = []float64{}, false
-		return nil
-	}
-	p.Valid = true
-	return p.Float64Array.Scan(value)
+func (v *Float64Array) Scan(src interface{}) error {
+	return scanInto(&v.Float64Array, &v.Valid, src)
 }
 
 // Value implements the driver Valuer interface.
-func (p Float64Array) Value() (driver.Value, error) {
-	if !p.Valid {
-		return nil, nil // a hack to make sure no array is null in db
-	}
-	if p.Float64Array == nil {
-		return "{}", nil
-	}
-	return p.Float64Array.Value()
+func (v Float64Array) Value() (driver.Value, error) {
+	return valueOf(v.Float64Array, v.Valid).Value()
 }
 
 func (v Float64Array) MarshalJSON() ([]byte, error) {
-	if v.Valid {
-		return json.Marshal(v.Float64Array)
-	}
-	return json.Marshal(nil)
+	return valueOf(v.Float64Array, v.Valid).MarshalJSON()
 }
 
 func (v *Float64Array) UnmarshalJSON(data []byte) error {
-	var val *[]float64
-	if err := json.Unmarshal(data, &val); err != nil {
-		return err
-	}
-	if val != nil {
-		v.Valid = true
-		v.Float64Array = *val
-	} else {
-		v.Valid = false
-	}
-	return nil
+	return unmarshalInto(&v.Float64Array, &v.Valid, data)
 }
 
-func (v *Float64Array) IsValid() bool {
+func (v Float64Array) IsValid() bool {
 	return v.Valid
 }
 
 /////////
-// 
+//
 type BoolArray struct {
 	pq.BoolArray
 	Valid bool
 }
 
-func (p *BoolArray) Scan(value interface{}) error {
-	if value == nil {
-		p.BoolArray, p.Valid = []bool{}, false
-		return nil
-	}
-	p.Valid = true
-	return p.BoolArray.Scan(value)
+func (v *BoolArray) Scan(src interface{}) error {
+	return scanInto(&v.BoolArray, &v.Valid, src)
 }
 
 // Value implements the driver Valuer interface.
-func (p BoolArray) Value() (driver.Value, error) {
-	if !p.Valid {
-		return nil, nil // a hack to make sure no array is null in db
-	}
-	if p.BoolArray == nil {
-		return "{}", nil
-	}
-	return p.BoolArray.Value()
+func (v BoolArray) Value() (driver.Value, error) {
+	return valueOf(v.BoolArray, v.Valid).Value()
 }
 
 func (v BoolArray) MarshalJSON() ([]byte, error) {
-	if v.Valid {
-		return json.Marshal(v.BoolArray)
-	}
-	return json.Marshal(nil)
+	return valueOf(v.BoolArray, v.Valid).MarshalJSON()
 }
 
 func (v *BoolArray) UnmarshalJSON(data []byte) error {
-	var val *[]bool
-	if err := json.Unmarshal(data, &val); err != nil {
-		return err
-	}
-	if val != nil {
-		v.Valid = true
-		v.BoolArray = *val
-	} else {
-		v.Valid = false
-	}
-	return nil
+	return unmarshalInto(&v.BoolArray, &v.Valid, data)
 }
 
-func (v *BoolArray) IsValid() bool {
+func (v BoolArray) IsValid() bool {
 	return v.Valid
 }
 
 /////////
-// 
+//
 type StringArray struct {
 	pq.StringArray
 	Valid bool
 }
 
-func (p *StringArray) Scan(value interface{}) error {
-	if value == nil {
-		p.StringArray, p.Valid = []string{}, false
-		return nil
-	}
-	p.Valid = true
-	return p.StringArray.Scan(value)
+func (v *StringArray) Scan(src interface{}) error {
+	return scanInto(&v.StringArray, &v.Valid, src)
 }
 
 // Value implements the driver Valuer interface.
-func (p StringArray) Value() (driver.Value, error) {
-	if !p.Valid {
-		return nil, nil // a hack to make sure no array is null in db
-	}
-	if p.StringArray == nil {
-		return "{}", nil
-	}
-	return p.StringArray.Value()
+func (v StringArray) Value() (driver.Value, error) {
+	return valueOf(v.StringArray, v.Valid).Value()
 }
 
 func (v StringArray) MarshalJSON() ([]byte, error) {
-	if v.Valid {
-		return json.Marshal(v.StringArray)
-	}
-	return json.Marshal(nil)
+	return valueOf(v.StringArray, v.Valid).MarshalJSON()
 }
 
 func (v *StringArray) UnmarshalJSON(data []byte) error {
-	var val *[]string
-	if err := json.Unmarshal(data, &val); err != nil {
-		return err
-	}
-	if val != nil {
-		v.Valid = true
-		v.StringArray = *val
-	} else {
-		v.Valid = false
-	}
-	return nil
+	return unmarshalInto(&v.StringArray, &v.Valid, data)
 }
 
-func (v *StringArray) IsValid() bool {
+func (v StringArray) IsValid() bool {
 	return v.Valid
 }
+
+type TimeArray = Value[dbtype.TimeArray]
+
+type UUIDArray = Value[dbtype.UUIDArray]
+
+type JsonbArray = Value[dbtype.JsonbArray]
+
+type PointArray = Value[dbtype.PointArray]
+
+type DecimalArray = Value[dbtype.DecimalArray]
@@ -1,8 +1,13 @@
 package null
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"math"
 	"testing"
+	"time"
 	"database/sql"
+	"github.com/tsealex/dbutil/dbtype"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 )
@@ -35,4 +40,111 @@ func TestBoolArray_Scan(t *testing.T) {
 	assert.Equal(t, true, k.B.Valid)
 	assert.Equal(t, []bool{false, true}, []bool(k.B.BoolArray))
 
-}
\ No newline at end of file
+}
+func TestArray_Scan(t *testing.T) {
+	a := Array[int64]{}
+	assert.NoError(t, a.Scan([]byte("{1,NULL,3}")))
+	assert.Equal(t, NewArray(New(int64(1)), Value[int64]{}, New(int64(3))), a)
+	assert.Error(t, a.Scan(nil))
+	assert.Error(t, a.Scan("{1,x}"))
+
+	assert.NoError(t, a.Scan("[0:1][1:2]={{1,2},{NULL,4}}"))
+	assert.Equal(t, []dbtype.ArrayDim{{Len: 2, Lower: 0}, {Len: 2, Lower: 1}}, a.Dims)
+	assert.Equal(t, New(int64(2)), a.At(0, 2))
+	assert.Equal(t, Value[int64]{}, a.At(1, 1))
+	assert.Panics(t, func() { a.At(2, 1) })
+	assert.Panics(t, func() { a.At(1) })
+
+	assert.NoError(t, a.Scan("{}"))
+	assert.Empty(t, a.Elems)
+	assert.Nil(t, a.Dims)
+
+	s := Array[string]{}
+	assert.NoError(t, s.Scan(`{"a,b",NULL,"NULL"}`))
+	assert.Equal(t, NewArray(New("a,b"), Value[string]{}, New("NULL")), s)
+
+	b := Array[bool]{}
+	assert.NoError(t, b.Scan("{t,f,NULL}"))
+	assert.Equal(t, NewArray(New(true), New(false), Value[bool]{}), b)
+
+	tm := Array[time.Time]{}
+	assert.NoError(t, tm.Scan(`{"2020-01-02 03:04:05+00",NULL}`))
+	assert.True(t, tm.Elems[0].V.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+	assert.False(t, tm.Elems[1].Valid)
+
+	by := Array[[]byte]{}
+	assert.NoError(t, by.Scan(`{"\\x0102",NULL}`))
+	assert.Equal(t, NewArray(New([]byte{1, 2}), Bytes{}), by)
+
+	u := Array[dbtype.UUID]{}
+	assert.NoError(t, u.Scan("{NULL,a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11}"))
+	assert.False(t, u.Elems[0].Valid)
+	assert.Equal(t, "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", u.Elems[1].V.String())
+
+	// The whole array may be NULL too.
+	v := Value[Array[int64]]{}
+	assert.NoError(t, v.Scan("{1}"))
+	assert.Equal(t, New(NewArray(New(int64(1)))), v)
+	assert.NoError(t, v.Scan(nil))
+	assert.False(t, v.Valid)
+}
+
+func TestArray_Value(t *testing.T) {
+	cases := []struct {
+		arr  driver.Valuer
+		want string
+	}{
+		{Array[int64]{}, "{}"},
+		{NewArray(New(int64(1)), Value[int64]{}), "{1,NULL}"},
+		{Array[int64]{Elems: []Value[int64]{New(int64(1)), New(int64(2)), {}, New(int64(4))},
+			Dims: []dbtype.ArrayDim{{Len: 2, Lower: 1}, {Len: 2, Lower: 1}}}, "{{1,2},{NULL,4}}"},
+		{Array[int64]{Elems: []Value[int64]{New(int64(1))}, Dims: []dbtype.ArrayDim{{Len: 1, Lower: 0}}},
+			"[0:0]={1}"},
+		{Array[int64]{Elems: []Value[int64]{New(int64(1))}}, "{1}"},
+		{NewArray(New(float32(0.1)), New(float32(math.Inf(-1)))), "{0.1,-Infinity}"},
+		{NewArray(New(true), Value[bool]{}), "{t,NULL}"},
+		{NewArray(New("a b"), New(""), New("NULL"), Value[string]{}), `{"a b","","NULL",NULL}`},
+		{NewArray(New([]byte{1, 2})), `{"\\x0102"}`},
+		{NewArray(New(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))), "{2020-01-02T03:04:05Z}"},
+		{NewArray(New(dbtype.JsonbValue{V: nil}), Value[dbtype.JsonbValue]{}), `{"null",NULL}`},
+		{NewArray(New(dbtype.Point{Lat: 1, Long: 2}), Value[dbtype.Point]{}), `{"SRID=4326;POINT(2 1)":NULL}`},
+	}
+	for _, c := range cases {
+		v, err := c.arr.Value()
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, v)
+	}
+	_, err := Array[int64]{Elems: []Value[int64]{{}}, Dims: []dbtype.ArrayDim{{Len: 2, Lower: 1}}}.Value()
+	assert.Error(t, err)
+}
+
+func TestArray_JSON(t *testing.T) {
+	a := Array[int64]{}
+	assert.NoError(t, a.Scan("[0:1][1:2]={{1,2},{NULL,4}}"))
+	data, err := json.Marshal(a)
+	assert.NoError(t, err)
+	assert.Equal(t, "[[1,2],[null,4]]", string(data))
+
+	b := Array[int64]{}
+	assert.NoError(t, json.Unmarshal(data, &b))
+	assert.Equal(t, []dbtype.ArrayDim{{Len: 2, Lower: 1}, {Len: 2, Lower: 1}}, b.Dims)
+	assert.Equal(t, a.Elems, b.Elems)
+
+	assert.NoError(t, json.Unmarshal([]byte("[]"), &b))
+	assert.Empty(t, b.Elems)
+	data, err = json.Marshal(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", string(data))
+
+	for _, src := range []string{"1", "[[1],2]", "[[1,2],[3]]", "[[]]", `["x"]`} {
+		assert.Error(t, json.Unmarshal([]byte(src), &b), src)
+	}
+
+	s := struct {
+		A Value[Array[string]] `json:"a"`
+		B Value[Array[string]] `json:"b"`
+	}{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"a":null,"b":["x",null]}`), &s))
+	assert.False(t, s.A.Valid)
+	assert.Equal(t, New(NewArray(New("x"), Value[string]{})), s.B)
+}
@@ -0,0 +1,154 @@
+package null
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+	"github.com/lib/pq"
+	"github.com/tsealex/dbutil/dbtype"
+	"github.com/tsealex/dbutil/dbtype/geom"
+)
+
+// Optional is a Value which may also be absent, e.g. a field left out of the
+// body of a PATCH request, as opposed to one set to null. The zero value is
+// absent. Fields of type Optional are only decoded from JSON if present, so
+// they tell the three states apart, and are left out when encoding structs if
+// tagged with omitzero.
+type Optional[T any] struct {
+	V       T
+	Valid   bool
+	Present bool
+}
+
+// Returns a present, non-NULL v.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{V: v, Valid: true, Present: true}
+}
+
+// Returns a present NULL.
+func None[T any]() Optional[T] {
+	return Optional[T]{Present: true}
+}
+
+// Returns the value of o, NULL if absent.
+func (o Optional[T]) Get() Value[T] {
+	if !o.Present {
+		return Value[T]{}
+	}
+	return Value[T]{V: o.V, Valid: o.Valid}
+}
+
+func (o Optional[T]) IsPresent() bool {
+	return o.Present
+}
+
+func (o Optional[T]) IsZero() bool {
+	return !o.Present
+}
+
+func (o Optional[T]) IsValid() bool {
+	return o.Valid && o.Present
+}
+
+func (o *Optional[T]) Scan(src interface{}) error {
+	v := Value[T]{}
+	if err := v.Scan(src); err != nil {
+		return err
+	}
+	o.V, o.Valid, o.Present = v.V, v.Valid, true
+	return nil
+}
+
+// Value implements the driver Valuer interface. Absent values are NULL.
+func (o Optional[T]) Value() (driver.Value, error) {
+	return o.Get().Value()
+}
+
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Get())
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	v := Value[T]{}
+	if err := v.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	o.V, o.Valid, o.Present = v.V, v.Valid, true
+	return nil
+}
+
+type OptionalString = Optional[string]
+
+type OptionalInt64 = Optional[int64]
+
+type OptionalInt32 = Optional[int32]
+
+type OptionalInt16 = Optional[int16]
+
+type OptionalBool = Optional[bool]
+
+type OptionalFloat64 = Optional[float64]
+
+type OptionalFloat32 = Optional[float32]
+
+type OptionalBytes = Optional[[]byte]
+
+type OptionalUUID = Optional[dbtype.UUID]
+
+type OptionalDecimal = Optional[dbtype.Decimal]
+
+type OptionalDuration = Optional[dbtype.Duration]
+
+type OptionalInterval = Optional[dbtype.Interval]
+
+type OptionalPoint = Optional[dbtype.Point]
+
+type OptionalGeometry = Optional[geom.Any]
+
+type OptionalJsonb = Optional[dbtype.JsonbValue]
+
+type OptionalTime = Optional[time.Time]
+
+type OptionalDate = Optional[dbtype.Date]
+
+type OptionalInt4Range = Optional[dbtype.Int4Range]
+
+type OptionalInt8Range = Optional[dbtype.Int8Range]
+
+type OptionalNumRange = Optional[dbtype.NumRange]
+
+type OptionalTsRange = Optional[dbtype.TsRange]
+
+type OptionalTstzRange = Optional[dbtype.TstzRange]
+
+type OptionalDateRange = Optional[dbtype.DateRange]
+
+type OptionalInt4Multirange = Optional[dbtype.Int4Multirange]
+
+type OptionalInt8Multirange = Optional[dbtype.Int8Multirange]
+
+type OptionalNumMultirange = Optional[dbtype.NumMultirange]
+
+type OptionalTsMultirange = Optional[dbtype.TsMultirange]
+
+type OptionalTstzMultirange = Optional[dbtype.TstzMultirange]
+
+type OptionalDateMultirange = Optional[dbtype.DateMultirange]
+
+type OptionalInt64Array = Optional[pq.Int64Array]
+
+type OptionalFloat64Array = Optional[pq.Float64Array]
+
+type OptionalBoolArray = Optional[pq.BoolArray]
+
+type OptionalStringArray = Optional[pq.StringArray]
+
+type OptionalTimeArray = Optional[dbtype.TimeArray]
+
+type OptionalUUIDArray = Optional[dbtype.UUIDArray]
+
+type OptionalJsonbArray = Optional[dbtype.JsonbArray]
+
+type OptionalPointArray = Optional[dbtype.PointArray]
+
+type OptionalDecimalArray = Optional[dbtype.DecimalArray]
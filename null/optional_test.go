@@ -0,0 +1,60 @@
+package null
+
+import (
+	"encoding/json"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptional_JSON(t *testing.T) {
+	s := struct {
+		A OptionalString
+		B OptionalString
+		C OptionalString
+		D OptionalJsonb
+	}{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"A":"x","B":null,"D":null}`), &s))
+	assert.Equal(t, Some("x"), s.A)
+	assert.Equal(t, None[string](), s.B)
+	assert.False(t, s.C.IsPresent())
+	assert.True(t, s.D.IsPresent())
+	assert.False(t, s.D.IsValid())
+
+	assert.Equal(t, New("x"), s.A.Get())
+	assert.Equal(t, Value[string]{}, s.B.Get())
+	assert.Equal(t, Value[string]{}, s.C.Get())
+	// Absent values which happen to hold something are still NULL.
+	assert.Equal(t, Value[string]{}, OptionalString{V: "x", Valid: true}.Get())
+
+	data, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"A":"x","B":null,"C":null,"D":null}`, string(data))
+	o := struct {
+		A OptionalInt64 `json:",omitzero"`
+		B OptionalInt64 `json:",omitzero"`
+		C OptionalInt64 `json:",omitzero"`
+	}{A: Some(int64(1)), B: None[int64]()}
+	data, err = json.Marshal(o)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"A":1,"B":null}`, string(data))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"A":1}`), &s))
+}
+
+func TestOptional_Value(t *testing.T) {
+	v, err := Some(int64(1)).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	v, err = None[int64]().Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+	v, err = OptionalInt64{}.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	o := OptionalInt64{}
+	assert.NoError(t, o.Scan(nil))
+	assert.Equal(t, None[int64](), o)
+	assert.NoError(t, o.Scan(int64(2)))
+	assert.Equal(t, Some(int64(2)), o)
+}
@@ -1,12 +1,15 @@
 package null
 
 import (
+	"bytes"
 	"encoding/json"
 	"database/sql"
-	"github.com/lib/pq"
+	"fmt"
+	"reflect"
 	"time"
+	"github.com/lib/pq"
 	"github.com/tsealex/dbutil/dbtype"
-	"match-up/backend/util/jsonb"
+	"github.com/tsealex/dbutil/dbtype/geom"
 	"database/sql/driver"
 )
 
@@ -14,34 +17,181 @@ type Nullable interface {
 	IsValid() bool
 }
 
-/////////
-//
-type String struct {
-	sql.NullString
+// Value is a T which may be NULL, the zero value being NULL. T is either a
+// sql.Scanner and driver.Valuer, a type database/sql handles itself (e.g.
+// int64, string or time.Time), or otherwise a type stored as JSON (e.g. a
+// struct or map).
+type Value[T any] struct {
+	V     T
+	Valid bool
 }
 
-func (v String) MarshalJSON() ([]byte, error) {
+func New[T any](v T) Value[T] {
+	return Value[T]{V: v, Valid: true}
+}
+
+// Returns a NULL value if ptr is nil.
+func FromPtr[T any](ptr *T) Value[T] {
+	if ptr == nil {
+		return Value[T]{}
+	}
+	return New(*ptr)
+}
+
+// Returns nil if v is NULL.
+func (v Value[T]) Ptr() *T {
+	if !v.Valid {
+		return nil
+	}
+	return &v.V
+}
+
+func (v *Value[T]) Scan(src interface{}) error {
+	var zero T
+	if src == nil {
+		v.V, v.Valid = zero, false
+		return nil
+	}
+	v.V = zero
+	if s, ok := interface{}(&v.V).(sql.Scanner); ok {
+		if err := s.Scan(src); err != nil {
+			return err
+		}
+	} else if isJSON(reflect.TypeOf(&v.V).Elem()) {
+		var data []byte
+		switch val := src.(type) {
+		case []byte:
+			data = val
+		case string:
+			data = []byte(val)
+		default:
+			return fmt.Errorf("cannot scan %T into %T", src, v.V)
+		}
+		if err := json.Unmarshal(data, &v.V); err != nil {
+			return err
+		}
+	} else {
+		tmp := sql.Null[T]{}
+		if err := tmp.Scan(src); err != nil {
+			return err
+		}
+		v.V = tmp.V
+	}
+	v.Valid = true
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (v Value[T]) Value() (driver.Value, error) {
+	if !v.Valid {
+		return nil, nil
+	}
+	var val driver.Valuer
+	if tmp, ok := interface{}(v.V).(driver.Valuer); ok {
+		val = tmp
+	} else if tmp, ok := interface{}(&v.V).(driver.Valuer); ok {
+		val = tmp
+	}
+	if val != nil {
+		res, err := val.Value()
+		// Valid nil slices, e.g. pq arrays, are empty rather than NULL.
+		if res == nil && err == nil && reflect.ValueOf(v.V).Kind() == reflect.Slice {
+			return "{}", nil
+		}
+		return res, err
+	}
+	if isJSON(reflect.TypeOf(&v.V).Elem()) {
+		data, err := json.Marshal(v.V)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v.V)
+}
+
+func (v Value[T]) MarshalJSON() ([]byte, error) {
 	if v.Valid {
-		return json.Marshal(v.String)
+		return json.Marshal(v.V)
 	}
 	return json.Marshal(nil)
 }
 
-func (v *String) UnmarshalJSON(data []byte) error {
-	var ptr *string
-	if err := json.Unmarshal(data, &ptr); err != nil {
+func (v *Value[T]) UnmarshalJSON(data []byte) error {
+	var zero T
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		v.V, v.Valid = zero, false
+		return nil
+	}
+	v.V = zero
+	if err := json.Unmarshal(data, &v.V); err != nil {
 		return err
 	}
-	if ptr != nil {
-		v.Valid = true
-		v.String = *ptr
-	} else {
-		v.Valid = false
+	v.Valid = true
+	return nil
+}
+
+func (v Value[T]) IsValid() bool {
+	return v.Valid
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Whether values of type t are stored as JSON, i.e. are of no type that
+// database/sql converts by itself.
+func isJSON(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != timeType
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	case reflect.Map, reflect.Array, reflect.Interface:
+		return true
 	}
+	return false
+}
+
+// Helpers of the types which predate Value. Those are structs rather than
+// aliases of Value, so they keep the fields they always had, and their methods
+// only forward to the Value of their fields.
+
+func valueOf[T any](v T, valid bool) Value[T] {
+	return Value[T]{V: v, Valid: valid}
+}
+
+// Sets the fields to what read gets into a Value.
+func readInto[T any](v *T, valid *bool, read func(*Value[T]) error) error {
+	tmp := Value[T]{}
+	if err := read(&tmp); err != nil {
+		return err
+	}
+	*v, *valid = tmp.V, tmp.Valid
 	return nil
 }
 
-func (v *String) IsValid() bool {
+func scanInto[T any](v *T, valid *bool, src interface{}) error {
+	return readInto(v, valid, func(tmp *Value[T]) error { return tmp.Scan(src) })
+}
+
+func unmarshalInto[T any](v *T, valid *bool, data []byte) error {
+	return readInto(v, valid, func(tmp *Value[T]) error { return tmp.UnmarshalJSON(data) })
+}
+
+/////////
+//
+type String struct {
+	sql.NullString
+}
+
+func (v String) MarshalJSON() ([]byte, error) {
+	return valueOf(v.String, v.Valid).MarshalJSON()
+}
+
+func (v *String) UnmarshalJSON(data []byte) error {
+	return unmarshalInto(&v.String, &v.Valid, data)
+}
+
+func (v String) IsValid() bool {
 	return v.Valid
 }
 
@@ -52,30 +202,21 @@ type Int64 struct {
 }
 
 func (v Int64) MarshalJSON() ([]byte, error) {
-	if v.Valid {
-		return json.Marshal(v.Int64)
-	}
-	return json.Marshal(nil)
+	return valueOf(v.Int64, v.Valid).MarshalJSON()
 }
 
 func (v *Int64) UnmarshalJSON(data []byte) error {
-	var ptr *int64
-	if err := json.Unmarshal(data, &ptr); err != nil {
-		return err
-	}
-	if ptr != nil {
-		v.Valid = true
-		v.Int64 = *ptr
-	} else {
-		v.Valid = false
-	}
-	return nil
+	return unmarshalInto(&v.Int64, &v.Valid, data)
 }
 
-func (v *Int64) IsValid() bool {
+func (v Int64) IsValid() bool {
 	return v.Valid
 }
 
+type Int32 = Value[int32]
+
+type Int16 = Value[int16]
+
 /////////
 //
 type Bool struct {
@@ -83,27 +224,14 @@ type Bool struct {
 }
 
 func (v Bool) MarshalJSON() ([]byte, error) {
-	if v.Valid {
-		return json.Marshal(v.Bool)
-	}
-	return json.Marshal(nil)
+	return valueOf(v.Bool, v.Valid).MarshalJSON()
 }
 
 func (v *Bool) UnmarshalJSON(data []byte) error {
-	var ptr *bool
-	if err := json.Unmarshal(data, &ptr); err != nil {
-		return err
-	}
-	if ptr != nil {
-		v.Valid = true
-		v.Bool = *ptr
-	} else {
-		v.Valid = false
-	}
-	return nil
+	return unmarshalInto(&v.Bool, &v.Valid, data)
 }
 
-func (v *Bool) IsValid() bool {
+func (v Bool) IsValid() bool {
 	return v.Valid
 }
 
@@ -114,30 +242,30 @@ type Float64 struct {
 }
 
 func (v Float64) MarshalJSON() ([]byte, error) {
-	if v.Valid {
-		return json.Marshal(v.Float64)
-	}
-	return json.Marshal(nil)
+	return valueOf(v.Float64, v.Valid).MarshalJSON()
 }
 
 func (v *Float64) UnmarshalJSON(data []byte) error {
-	var Float64 *float64
-	if err := json.Unmarshal(data, &Float64); err != nil {
-		return err
-	}
-	if Float64 != nil {
-		v.Valid = true
-		v.Float64 = *Float64
-	} else {
-		v.Valid = false
-	}
-	return nil
+	return unmarshalInto(&v.Float64, &v.Valid, data)
 }
 
-func (v *Float64) IsValid() bool {
+func (v Float64) IsValid() bool {
 	return v.Valid
 }
 
+type Float32 = Value[float32]
+
+// A bytea column.
+type Bytes = Value[[]byte]
+
+type UUID = Value[dbtype.UUID]
+
+type Decimal = Value[dbtype.Decimal]
+
+type Duration = Value[dbtype.Duration]
+
+type Interval = Value[dbtype.Interval]
+
 ///////// TODO: Geography
 //
 type Point struct {
@@ -145,94 +273,56 @@ type Point struct {
 	Valid bool
 }
 
-func (v *Point) Scan(value interface{}) error {
-	if value == nil {
-		v.Point, v.Valid = dbtype.Point{0, 0}, false
-		return nil
-	}
-	v.Valid = true
-	return v.Point.Scan(value)
+func (v *Point) Scan(src interface{}) error {
+	return scanInto(&v.Point, &v.Valid, src)
 }
 
 // Value implements the driver Valuer interface.
 func (v Point) Value() (driver.Value, error) {
-	if !v.Valid {
-		return nil, nil
-	}
-	return v.Point.Value()
+	return valueOf(v.Point, v.Valid).Value()
 }
 
 func (v Point) MarshalJSON() ([]byte, error) {
-	if v.Valid {
-		return json.Marshal(v.Point)
-	}
-	return json.Marshal(nil)
+	return valueOf(v.Point, v.Valid).MarshalJSON()
 }
 
 func (v *Point) UnmarshalJSON(data []byte) error {
-	var ptr *dbtype.Point
-	if err := json.Unmarshal(data, &ptr); err != nil {
-		return err
-	}
-	if ptr != nil {
-		v.Valid = true
-		v.Point = *ptr
-	} else {
-		v.Valid = false
-	}
-	return nil
+	return unmarshalInto(&v.Point, &v.Valid, data)
 }
 
-func (v *Point) IsValid() bool {
+func (v Point) IsValid() bool {
 	return v.Valid
 }
 
-///////// TODO: JSONB
-//
+// Any geometry, see package geom.
+type Geometry = Value[geom.Any]
+
+///////// Any JSON value. JSON null is a valid value, with a nil Jsonb.V, while
+// SQL NULL is not. Both are encoded as null in JSON, which decodes back to SQL
+// NULL.
 type Jsonb struct {
-	jsonb.Jsonb
+	Jsonb dbtype.JsonbValue
 	Valid bool
 }
 
-func (v *Jsonb) Scan(value interface{}) error {
-	if value == nil {
-		v.Jsonb, v.Valid = nil, false
-		return nil
-	}
-	v.Valid = true
-	return v.Jsonb.Scan(value)
+func (v *Jsonb) Scan(src interface{}) error {
+	return scanInto(&v.Jsonb, &v.Valid, src)
 }
 
 // Value implements the driver Valuer interface.
 func (v Jsonb) Value() (driver.Value, error) {
-	if !v.Valid {
-		return nil, nil
-	}
-	return v.Jsonb.Value()
+	return valueOf(v.Jsonb, v.Valid).Value()
 }
 
 func (v Jsonb) MarshalJSON() ([]byte, error) {
-	if v.Valid {
-		return json.Marshal(v.Jsonb)
-	}
-	return json.Marshal(nil)
+	return valueOf(v.Jsonb, v.Valid).MarshalJSON()
 }
 
 func (v *Jsonb) UnmarshalJSON(data []byte) error {
-	var ptr *jsonb.Jsonb
-	if err := json.Unmarshal(data, &ptr); err != nil {
-		return err
-	}
-	if ptr != nil {
-		v.Valid = true
-		v.Jsonb = *ptr
-	} else {
-		v.Valid = false
-	}
-	return nil
+	return unmarshalInto(&v.Jsonb, &v.Valid, data)
 }
 
-func (v *Jsonb) IsValid() bool {
+func (v Jsonb) IsValid() bool {
 	return v.Valid
 }
 
@@ -243,26 +333,39 @@ type Time struct {
 }
 
 func (v Time) MarshalJSON() ([]byte, error) {
-	if v.Valid {
-		return json.Marshal(v.Time)
-	}
-	return json.Marshal(nil)
+	return valueOf(v.Time, v.Valid).MarshalJSON()
 }
 
 func (v *Time) UnmarshalJSON(data []byte) error {
-	var ptr *time.Time
-	if err := json.Unmarshal(data, &ptr); err != nil {
-		return err
-	}
-	if ptr != nil {
-		v.Valid = true
-		v.Time = *ptr
-	} else {
-		v.Valid = false
-	}
-	return nil
+	return unmarshalInto(&v.Time, &v.Valid, data)
 }
 
-func (v *Time) IsValid() bool {
+func (v Time) IsValid() bool {
 	return v.Valid
 }
+
+type Date = Value[dbtype.Date]
+
+type Int4Range = Value[dbtype.Int4Range]
+
+type Int8Range = Value[dbtype.Int8Range]
+
+type NumRange = Value[dbtype.NumRange]
+
+type TsRange = Value[dbtype.TsRange]
+
+type TstzRange = Value[dbtype.TstzRange]
+
+type DateRange = Value[dbtype.DateRange]
+
+type Int4Multirange = Value[dbtype.Int4Multirange]
+
+type Int8Multirange = Value[dbtype.Int8Multirange]
+
+type NumMultirange = Value[dbtype.NumMultirange]
+
+type TsMultirange = Value[dbtype.TsMultirange]
+
+type TstzMultirange = Value[dbtype.TstzMultirange]
+
+type DateMultirange = Value[dbtype.DateMultirange]
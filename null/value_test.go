@@ -0,0 +1,257 @@
+package null
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/dbtype"
+)
+
+type address struct {
+	City string `json:"city"`
+}
+
+func TestValue_Scan(t *testing.T) {
+	s := Value[string]{}
+	assert.NoError(t, s.Scan([]byte("x")))
+	assert.Equal(t, New("x"), s)
+	assert.NoError(t, s.Scan(nil))
+	assert.Equal(t, Value[string]{}, s)
+
+	i := Value[int64]{}
+	assert.NoError(t, i.Scan("42"))
+	assert.Equal(t, New(int64(42)), i)
+	assert.Error(t, i.Scan("x"))
+
+	now := time.Now()
+	tm := Value[time.Time]{}
+	assert.NoError(t, tm.Scan(now))
+	assert.Equal(t, New(now), tm)
+
+	// Scanners are used as they are.
+	arr := Value[pq.Int64Array]{}
+	assert.NoError(t, arr.Scan([]byte("{1,2}")))
+	assert.Equal(t, New(pq.Int64Array{1, 2}), arr)
+
+	// Other types are read as JSON.
+	a := Value[address]{}
+	assert.NoError(t, a.Scan([]byte(`{"city":"Oslo"}`)))
+	assert.Equal(t, New(address{City: "Oslo"}), a)
+	m := Value[map[string]int]{}
+	assert.NoError(t, m.Scan(`{"a":1}`))
+	assert.Equal(t, map[string]int{"a": 1}, m.V)
+	assert.Error(t, m.Scan(int64(1)))
+}
+
+func TestValue_Value(t *testing.T) {
+	for _, c := range []struct {
+		v    driver.Valuer
+		want driver.Value
+	}{
+		{String{}, nil},
+		{New("x"), "x"},
+		{New(int64(1)), int64(1)},
+		{New(int32(1)), int64(1)},
+		{New(1.5), 1.5},
+		{New(true), true},
+		{New([]byte("x")), []byte("x")},
+		{New(dbtype.Point{Lat: 1, Long: 2}), "SRID=4326;POINT(2 1)"},
+		{New(pq.StringArray{"a"}), `{"a"}`},
+		{New(pq.BoolArray(nil)), "{}"},
+		{New(address{City: "Oslo"}), `{"city":"Oslo"}`},
+	} {
+		res, err := c.v.Value()
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, res)
+	}
+}
+
+func TestValue_JSON(t *testing.T) {
+	s := struct {
+		I Value[int64]
+		S Value[string]
+		F Value[pq.Float64Array]
+		A *Value[address]
+	}{I: New(int64(1)), A: &Value[address]{}}
+	data, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"I":1,"S":null,"F":null,"A":null}`, string(data))
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"I":null,"S":"x","F":[1.5],"A":{"city":"Oslo"}}`), &s))
+	assert.False(t, s.I.IsValid())
+	assert.Equal(t, New("x"), s.S)
+	assert.Equal(t, New(pq.Float64Array{1.5}), s.F)
+	assert.Equal(t, "Oslo", s.A.V.City)
+	assert.Error(t, json.Unmarshal([]byte(`{"I":"x"}`), &s))
+
+	assert.Nil(t, Value[string]{}.Ptr())
+	assert.Equal(t, "x", *New("x").Ptr())
+	assert.Equal(t, Value[string]{}, FromPtr[string](nil))
+}
+
+// The types which predate Value keep their fields.
+func TestNamed(t *testing.T) {
+	s := String{}
+	assert.NoError(t, s.Scan([]byte("x")))
+	assert.Equal(t, "x", s.String)
+	assert.True(t, s.IsValid())
+	i := Int64{}
+	assert.NoError(t, i.Scan(int64(2)))
+	assert.Equal(t, int64(2), i.Int64)
+	p := Point{}
+	assert.NoError(t, p.Scan("0101000020E61000000000000000000040000000000000F03F"))
+	assert.Equal(t, dbtype.Point{Lat: 1, Long: 2, SRID: 4326}, p.Point)
+	assert.NoError(t, p.Scan(nil))
+	assert.Equal(t, Point{}, p)
+	arr := BoolArray{}
+	assert.NoError(t, arr.Scan([]byte("{t,f}")))
+	assert.Equal(t, pq.BoolArray{true, false}, arr.BoolArray)
+	assert.True(t, arr.Valid)
+
+	for _, c := range []struct {
+		v    driver.Valuer
+		want driver.Value
+	}{
+		{String{}, nil},
+		{Float64{sql.NullFloat64{Float64: 1.5, Valid: true}}, 1.5},
+		{Point{Point: dbtype.Point{Lat: 1, Long: 2}, Valid: true}, "SRID=4326;POINT(2 1)"},
+		{Point{}, nil},
+		{StringArray{Valid: true}, "{}"},
+		{Int64Array{}, nil},
+	} {
+		res, err := c.v.Value()
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, res)
+	}
+
+	v := struct {
+		S String
+		B Bool
+		F Float64
+		T Time
+		A Int64Array
+	}{}
+	data := `{"S":"x","B":false,"F":1.5,"T":"2020-01-02T03:04:05Z","A":[1,2]}`
+	assert.NoError(t, json.Unmarshal([]byte(data), &v))
+	assert.True(t, v.B.Valid)
+	assert.False(t, v.B.Bool)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), v.T.Time)
+	assert.Equal(t, pq.Int64Array{1, 2}, v.A.Int64Array)
+	out, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, data, string(out))
+	assert.NoError(t, json.Unmarshal([]byte(`{"S":null,"B":null,"F":null,"T":null,"A":null}`), &v))
+	assert.False(t, v.S.Valid || v.B.Valid || v.F.Valid || v.T.Valid || v.A.Valid)
+}
+
+func TestGeometry(t *testing.T) {
+	g := Geometry{}
+	assert.NoError(t, g.Scan([]byte("0102000020E61000000200000000000000000000000000000000000000000000000000F03F000000000000F03F")))
+	assert.True(t, g.Valid)
+	v, err := g.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "SRID=4326;LINESTRING(0 0,1 1)", v)
+	assert.NoError(t, g.Scan(nil))
+	v, err = g.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestJsonb(t *testing.T) {
+	j := Jsonb{}
+	assert.NoError(t, j.Scan(nil))
+	assert.False(t, j.Valid)
+	v, err := j.Value()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	// JSON null is not SQL NULL.
+	assert.NoError(t, j.Scan([]byte(`null`)))
+	assert.True(t, j.Valid)
+	assert.Nil(t, j.Jsonb.V)
+	v, err = j.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`null`), v)
+
+	for _, src := range []string{`{"a":{"b":[]}}`, `[1,"x",null]`, `"x"`, `1.5`, `true`} {
+		assert.NoError(t, j.Scan([]byte(src)), src)
+		assert.True(t, j.Valid)
+		v, err = j.Value()
+		assert.NoError(t, err)
+		assert.Equal(t, src, string(v.([]byte)))
+		data, err := json.Marshal(j)
+		assert.NoError(t, err)
+		assert.Equal(t, src, string(data))
+	}
+
+	s := struct{ A, B Jsonb }{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"A":[1,{"b":2}],"B":null}`), &s))
+	assert.True(t, s.A.Valid)
+	assert.Equal(t, []interface{}{json.Number("1"), map[string]interface{}{"b": json.Number("2")}},
+		s.A.Jsonb.V)
+	assert.False(t, s.B.Valid)
+	data, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"A":[1,{"b":2}],"B":null}`, string(data))
+}
+
+func TestValue_Scalars(t *testing.T) {
+	i16 := Int16{}
+	assert.NoError(t, i16.Scan(int64(-5)))
+	assert.Equal(t, New(int16(-5)), i16)
+	assert.Error(t, i16.Scan(int64(1<<20)))
+	i32 := Int32{}
+	assert.NoError(t, i32.Scan([]byte("70000")))
+	assert.Equal(t, New(int32(70000)), i32)
+	assert.Error(t, i32.Scan(int64(1<<40)))
+	f32 := Float32{}
+	assert.NoError(t, f32.Scan(1.5))
+	assert.Equal(t, New(float32(1.5)), f32)
+
+	b := Bytes{}
+	src := []byte{0, 1}
+	assert.NoError(t, b.Scan(src))
+	src[0] = 9
+	assert.Equal(t, []byte{0, 1}, b.V)
+	v, err := b.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1}, v)
+
+	u := UUID{}
+	assert.NoError(t, u.Scan("a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"))
+	assert.True(t, u.Valid)
+	v, err = u.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", v)
+
+	d := Decimal{}
+	assert.NoError(t, d.Scan([]byte("0.10")))
+	v, err = d.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "0.10", v)
+	assert.NoError(t, d.Scan(nil))
+	assert.False(t, d.Valid)
+
+	dur := Duration{}
+	assert.NoError(t, dur.Scan("01:00:00"))
+	assert.Equal(t, New(dbtype.Duration(time.Hour)), dur)
+
+	s := struct {
+		I Int16
+		B Bytes
+		U UUID
+		D Decimal
+		T Duration
+	}{}
+	data := `{"I":3,"B":"AAE=","U":"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11","D":1.10,"T":"2s"}`
+	assert.NoError(t, json.Unmarshal([]byte(data), &s))
+	out, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, data, string(out))
+	assert.NoError(t, json.Unmarshal([]byte(`{"I":null,"B":null,"U":null,"D":null,"T":null}`), &s))
+	assert.False(t, s.I.Valid || s.B.Valid || s.U.Valid || s.D.Valid || s.T.Valid)
+}
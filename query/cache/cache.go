@@ -0,0 +1,279 @@
+package cache
+
+// Hot code paths tend to rebuild the very same SQLRecipe over and over, paying
+// for both the string building on our side and the parsing on the server side.
+// Cache memoizes the SQL rendered for a recipe shape, identified by a key the
+// caller picks, and keeps a pool of prepared statements per database handle.
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Preparer is implemented by *sql.DB, *sql.Conn, *sql.Tx and *sqlx.DB.
+type Preparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// SQLSTATE raised by Postgres when a prepared statement does not exist.
+const invalidStmtName = "26000"
+
+type Stats struct {
+	SQLHits    uint64
+	SQLMisses  uint64
+	StmtHits   uint64
+	StmtMisses uint64
+	Evictions  uint64
+}
+
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	sqls  *lru
+	stmts map[Preparer]*lru
+
+	sqlHits    uint64
+	sqlMisses  uint64
+	stmtHits   uint64
+	stmtMisses uint64
+	evictions  uint64
+}
+
+// Creates a cache holding at most size rendered queries, and at most size
+// prepared statements for each database handle. A size of 0 means unbounded.
+func New(size int) *Cache {
+	if size < 0 {
+		panic("size must be greater than or equal to 0")
+	}
+	return &Cache{
+		size:  size,
+		sqls:  newLRU(size, nil),
+		stmts: map[Preparer]*lru{},
+	}
+}
+
+// Returns the SQL rendered for the recipe shape identified by key. build is
+// only called on a miss, and its result is only cached if it succeeds.
+func (c *Cache) SQL(key string, build func() (string, error)) (string, error) {
+	c.mu.Lock()
+	q, ok := c.sqls.get(key)
+	c.mu.Unlock()
+	if ok {
+		atomic.AddUint64(&c.sqlHits, 1)
+		return q.(string), nil
+	}
+	atomic.AddUint64(&c.sqlMisses, 1)
+	res, err := build()
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	atomic.AddUint64(&c.evictions, uint64(c.sqls.put(key, res)))
+	c.mu.Unlock()
+	return res, nil
+}
+
+// A prepared statement checked out of a Cache. Close must be called once the
+// caller is done with it, which releases it rather than closing it: the
+// underlying statement is only closed after it has left the pool and its last
+// user has released it.
+type Stmt struct {
+	*sql.Stmt
+	c    *Cache
+	ref  *stmtRef
+	once sync.Once
+}
+
+func (s *Stmt) Close() error {
+	s.once.Do(func() {
+		s.c.mu.Lock()
+		defer s.c.mu.Unlock()
+		s.ref.release()
+	})
+	return nil
+}
+
+// Tracks the users of a pooled statement. Guarded by Cache.mu.
+type stmtRef struct {
+	stmt    *sql.Stmt
+	users   int
+	evicted bool
+}
+
+func (r *stmtRef) release() {
+	r.users--
+	if r.evicted && r.users == 0 {
+		r.stmt.Close()
+	}
+}
+
+func (r *stmtRef) evict() {
+	r.evicted = true
+	if r.users == 0 {
+		r.stmt.Close()
+	}
+}
+
+// Returns a prepared statement for q on db, preparing it on a miss. The
+// statement must be closed once no longer used; statements evicted from the
+// pool in the meantime stay usable until then.
+func (c *Cache) Stmt(ctx context.Context, db Preparer, q string) (*Stmt, error) {
+	c.mu.Lock()
+	pool := c.pool(db)
+	ref, ok := pool.get(q)
+	if ok {
+		stmt := c.checkout(ref.(*stmtRef))
+		c.mu.Unlock()
+		atomic.AddUint64(&c.stmtHits, 1)
+		return stmt, nil
+	}
+	c.mu.Unlock()
+	atomic.AddUint64(&c.stmtMisses, 1)
+	prepared, err := db.PrepareContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	// Another goroutine may have prepared the same query in the meantime, or
+	// the handle may have been invalidated; keep a single statement either way.
+	pool = c.pool(db)
+	if ref, ok := pool.get(q); ok {
+		stmt := c.checkout(ref.(*stmtRef))
+		c.mu.Unlock()
+		prepared.Close()
+		return stmt, nil
+	}
+	r := &stmtRef{stmt: prepared}
+	stmt := c.checkout(r)
+	atomic.AddUint64(&c.evictions, uint64(pool.put(q, r)))
+	c.mu.Unlock()
+	return stmt, nil
+}
+
+// Must be called with c.mu held.
+func (c *Cache) checkout(r *stmtRef) *Stmt {
+	r.users++
+	return &Stmt{Stmt: r.stmt, c: c, ref: r}
+}
+
+// Combines SQL and Stmt.
+func (c *Cache) Prepare(ctx context.Context, db Preparer, key string,
+	build func() (string, error)) (*Stmt, error) {
+	q, err := c.SQL(key, build)
+	if err != nil {
+		return nil, err
+	}
+	return c.Stmt(ctx, db, q)
+}
+
+// Executes the recipe identified by key through a cached statement. If the
+// server reports that it has forgotten the statement (e.g. behind a pooler
+// running DISCARD ALL), the statements of db are dropped and the execution is
+// retried once with a freshly prepared statement. Other errors, including a
+// broken connection, are never retried since the statement may have run.
+func (c *Cache) Exec(ctx context.Context, db Preparer, key string,
+	build func() (string, error), args ... interface{}) (res sql.Result, err error) {
+	for retry := 0; retry < 2; retry++ {
+		var stmt *Stmt
+		if stmt, err = c.Prepare(ctx, db, key, build); err != nil {
+			return
+		}
+		res, err = stmt.ExecContext(ctx, args...)
+		stmt.Close()
+		if !c.ResetOnError(db, err) || !notExecuted(err) {
+			return
+		}
+	}
+	return
+}
+
+// Same as Exec, but for queries returning rows. The rows stay valid after the
+// statement is released.
+func (c *Cache) Query(ctx context.Context, db Preparer, key string,
+	build func() (string, error), args ... interface{}) (rows *sql.Rows, err error) {
+	for retry := 0; retry < 2; retry++ {
+		var stmt *Stmt
+		if stmt, err = c.Prepare(ctx, db, key, build); err != nil {
+			return
+		}
+		rows, err = stmt.QueryContext(ctx, args...)
+		stmt.Close()
+		if !c.ResetOnError(db, err) || !notExecuted(err) {
+			return
+		}
+	}
+	return
+}
+
+// Invalidates the statements of db if err indicates that the connection was
+// reset, in which case the server has forgotten about them (e.g. behind a
+// pooler running DISCARD ALL). Returns whether the statements were
+// invalidated.
+func (c *Cache) ResetOnError(db Preparer, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || notExecuted(err) {
+		c.Invalidate(db)
+		return true
+	}
+	return false
+}
+
+// Reports whether err proves that the server rejected the statement without
+// running it, which makes it safe to run again.
+func notExecuted(err error) bool {
+	var state interface {
+		SQLState() string
+	}
+	return errors.As(err, &state) && state.SQLState() == invalidStmtName
+}
+
+// Forgets every statement prepared on db, closing those not in use. Must be
+// called before closing db itself.
+func (c *Cache) Invalidate(db Preparer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pool, ok := c.stmts[db]; ok {
+		pool.clear()
+		delete(c.stmts, db)
+	}
+}
+
+// Drops every rendered query and every prepared statement, closing those not
+// in use.
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sqls.clear()
+	for db, pool := range c.stmts {
+		pool.clear()
+		delete(c.stmts, db)
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	return Stats{
+		SQLHits:    atomic.LoadUint64(&c.sqlHits),
+		SQLMisses:  atomic.LoadUint64(&c.sqlMisses),
+		StmtHits:   atomic.LoadUint64(&c.stmtHits),
+		StmtMisses: atomic.LoadUint64(&c.stmtMisses),
+		Evictions:  atomic.LoadUint64(&c.evictions),
+	}
+}
+
+// Must be called with c.mu held.
+func (c *Cache) pool(db Preparer) *lru {
+	pool, ok := c.stmts[db]
+	if !ok {
+		pool = newLRU(c.size, func(ref interface{}) {
+			ref.(*stmtRef).evict()
+		})
+		c.stmts[db] = pool
+	}
+	return pool
+}
@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"testing"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync/atomic"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/query/clause"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+// A driver that accepts any query, counting prepares, closes and executions,
+// and failing the next execution as if the server had forgotten the statement
+// when asked to, or every execution as if the connection broke.
+type fakeDriver struct {
+	prepared int32
+	closed   int32
+	reset    int32
+	broken   int32
+	execs    int32
+}
+
+type stateErr string
+
+func (e stateErr) Error() string {
+	return "pq: prepared statement does not exist"
+}
+
+func (e stateErr) SQLState() string {
+	return string(e)
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	atomic.AddInt32(&c.d.prepared, 1)
+	return &fakeStmt{c.d}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+type fakeStmt struct {
+	d *fakeDriver
+}
+
+func (s *fakeStmt) Close() error {
+	atomic.AddInt32(&s.d.closed, 1)
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	atomic.AddInt32(&s.d.execs, 1)
+	if atomic.LoadInt32(&s.d.broken) == 1 {
+		return nil, driver.ErrBadConn
+	}
+	if atomic.CompareAndSwapInt32(&s.d.reset, 1, 0) {
+		return nil, stateErr("26000")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (r *fakeRows) Columns() []string {
+	return nil
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	return io.EOF
+}
+
+var drv = &fakeDriver{}
+
+func init() {
+	sql.Register("cachetest", drv)
+}
+
+func TestCache_SQL(t *testing.T) {
+	c := New(2)
+	builds := 0
+	build := func() (string, error) {
+		builds++
+		return clause.SQL().Read("a").Select("t")
+	}
+	for i := 0; i < 3; i++ {
+		q, err := c.SQL("a", build)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT a FROM t", q)
+	}
+	assert.Equal(t, 1, builds)
+	assert.Equal(t, Stats{SQLHits: 2, SQLMisses: 1}, c.Stats())
+
+	// "a" is the least recently used one once "c" comes in.
+	c.SQL("b", build)
+	c.SQL("c", build)
+	c.SQL("a", build)
+	assert.Equal(t, 4, builds)
+	assert.Equal(t, uint64(2), c.Stats().Evictions)
+}
+
+func TestCache_Stmt(t *testing.T) {
+	db, err := sql.Open("cachetest", "")
+	assert.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+	c := New(1)
+	prepared := atomic.LoadInt32(&drv.prepared)
+
+	s1, err := c.Stmt(ctx, db, "SELECT 1")
+	assert.NoError(t, err)
+	s2, err := c.Stmt(ctx, db, "SELECT 1")
+	assert.NoError(t, err)
+	assert.True(t, s1.Stmt == s2.Stmt)
+	assert.Equal(t, prepared+1, atomic.LoadInt32(&drv.prepared))
+	s2.Close()
+
+	// Evicts the first statement, which stays open while s1 still uses it.
+	closed := atomic.LoadInt32(&drv.closed)
+	s3, err := c.Stmt(ctx, db, "SELECT 2")
+	assert.NoError(t, err)
+	assert.Equal(t, closed, atomic.LoadInt32(&drv.closed))
+	assert.Equal(t, 1, c.stmts[db].len())
+	_, err = s1.Exec()
+	assert.NoError(t, err)
+	s1.Close()
+	assert.Equal(t, closed+1, atomic.LoadInt32(&drv.closed))
+	_, err = s1.Exec()
+	assert.Error(t, err)
+
+	// Same for invalidation.
+	c.Invalidate(db)
+	assert.Empty(t, c.stmts)
+	_, err = s3.Exec()
+	assert.NoError(t, err)
+	s3.Close()
+	assert.Equal(t, closed+2, atomic.LoadInt32(&drv.closed))
+	assert.Equal(t, Stats{StmtHits: 1, StmtMisses: 2, Evictions: 1}, c.Stats())
+}
+
+func TestCache_Exec(t *testing.T) {
+	db, err := sql.Open("cachetest", "")
+	assert.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+	c := New(4)
+	build := func() (string, error) {
+		return clause.SQL().Delete(exp.Relation("t"))
+	}
+
+	res, err := c.Exec(ctx, db, "delete", build)
+	assert.NoError(t, err)
+	n, _ := res.RowsAffected()
+	assert.Equal(t, int64(1), n)
+
+	// A reset session drops the pool and prepares the statement again.
+	prepared := atomic.LoadInt32(&drv.prepared)
+	atomic.StoreInt32(&drv.reset, 1)
+	_, err = c.Exec(ctx, db, "delete", build)
+	assert.NoError(t, err)
+	assert.True(t, atomic.LoadInt32(&drv.prepared) > prepared)
+
+	rows, err := c.Query(ctx, db, "delete", build)
+	assert.NoError(t, err)
+	assert.False(t, rows.Next())
+	rows.Close()
+}
+
+func TestCache_ExecBrokenConn(t *testing.T) {
+	db, err := sql.Open("cachetest", "")
+	assert.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+	c := New(4)
+	build := func() (string, error) {
+		return clause.SQL().Delete(exp.Relation("t"))
+	}
+	atomic.StoreInt32(&drv.broken, 1)
+	defer atomic.StoreInt32(&drv.broken, 0)
+
+	// database/sql has its own retries; count them to tell ours apart.
+	stmt, err := db.Prepare("DELETE FROM t")
+	assert.NoError(t, err)
+	execs := atomic.LoadInt32(&drv.execs)
+	_, err = stmt.Exec()
+	assert.ErrorIs(t, err, driver.ErrBadConn)
+	attempts := atomic.LoadInt32(&drv.execs) - execs
+	stmt.Close()
+
+	// The statement may have run, so it is not run again.
+	execs = atomic.LoadInt32(&drv.execs)
+	_, err = c.Exec(ctx, db, "delete", build)
+	assert.ErrorIs(t, err, driver.ErrBadConn)
+	assert.Equal(t, attempts, atomic.LoadInt32(&drv.execs)-execs)
+	assert.Empty(t, c.stmts)
+}
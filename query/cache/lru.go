@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"container/list"
+)
+
+type entry struct {
+	key   string
+	value interface{}
+}
+
+// lru is a fixed-size least-recently-used map. It is not safe for concurrent
+// use; Cache guards it with its own mutex.
+type lru struct {
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	// Called with every value pushed out by a newer one.
+	onEvict func(value interface{})
+}
+
+func newLRU(size int, onEvict func(interface{})) *lru {
+	return &lru{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+		onEvict: onEvict,
+	}
+}
+
+func (l *lru) get(key string) (interface{}, bool) {
+	if el, ok := l.entries[key]; ok {
+		l.order.MoveToFront(el)
+		return el.Value.(*entry).value, true
+	}
+	return nil, false
+}
+
+// Returns the number of entries evicted to make room for the new one.
+func (l *lru) put(key string, value interface{}) (evicted int) {
+	if el, ok := l.entries[key]; ok {
+		el.Value.(*entry).value = value
+		l.order.MoveToFront(el)
+		return
+	}
+	l.entries[key] = l.order.PushFront(&entry{key: key, value: value})
+	for l.size > 0 && l.order.Len() > l.size {
+		l.remove(l.order.Back())
+		evicted++
+	}
+	return
+}
+
+func (l *lru) remove(el *list.Element) {
+	e := l.order.Remove(el).(*entry)
+	delete(l.entries, e.key)
+	if l.onEvict != nil {
+		l.onEvict(e.value)
+	}
+}
+
+func (l *lru) clear() {
+	for l.order.Len() > 0 {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *lru) len() int {
+	return l.order.Len()
+}
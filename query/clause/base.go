@@ -4,12 +4,23 @@ import (
 	"github.com/tsealex/dbutil/query"
 	"bytes"
 	"github.com/tsealex/dbutil/query/exp"
+	"fmt"
 )
 
 type Clause interface {
 	ToSQL(*query.SQLContext, *bytes.Buffer) error
 }
 
+// Implemented by the clauses of this package, so that whole recipes can be
+// inspected and rewritten.
+type expClause interface {
+	Clause
+	exps() []exp.Exp
+	// Returns a copy of the clause with the expressions returned by exps
+	// replaced.
+	withExps(exps []exp.Exp) Clause
+}
+
 type HavingClause struct {
 	cond *exp.CondExp
 }
@@ -28,12 +39,20 @@ func Having(cond ... interface{}) *HavingClause {
 
 func (hc *HavingClause) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
 	buf.WriteString("HAVING ")
-	if err = hc.cond.ToSQL(ctx, buf); err != nil {
+	if err = exp.Simplify(hc.cond).ToSQL(ctx, buf); err != nil {
 		return
 	}
 	return
 }
 
+func (hc *HavingClause) exps() []exp.Exp {
+	return []exp.Exp{hc.cond}
+}
+
+func (hc *HavingClause) withExps(exps []exp.Exp) Clause {
+	return &HavingClause{cond: toCond(exps[0])}
+}
+
 type GroupByClause struct {
 	cols []exp.Exp
 }
@@ -49,12 +68,20 @@ func GroupBy(cols ... interface{}) *GroupByClause {
 
 func (oc *GroupByClause) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
 	buf.WriteString("GROUP BY ")
-	if err = concatExps(",", ctx, buf, oc.cols); err != nil {
+	if err = concatExps(ctx.Comma(), ctx, buf, oc.cols); err != nil {
 		return
 	}
 	return
 }
 
+func (oc *GroupByClause) exps() []exp.Exp {
+	return oc.cols
+}
+
+func (oc *GroupByClause) withExps(exps []exp.Exp) Clause {
+	return &GroupByClause{cols: exps}
+}
+
 type OrderByClause struct {
 	cols []exp.Exp
 }
@@ -64,6 +91,9 @@ func Order() *OrderByClause {
 }
 
 func (oc *OrderByClause) By(order string, cols ... interface{}) *OrderByClause {
+	if order != "" {
+		order = " " + order
+	}
 	for _, col := range cols {
 		e := getExp(col)
 		oc.cols = append(oc.cols, exp.RightUnary(e, order))
@@ -79,12 +109,20 @@ const (
 
 func (oc *OrderByClause) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
 	buf.WriteString("ORDER BY ")
-	if err = concatExps(",", ctx, buf, oc.cols); err != nil {
+	if err = concatExps(ctx.Comma(), ctx, buf, oc.cols); err != nil {
 		return
 	}
 	return
 }
 
+func (oc *OrderByClause) exps() []exp.Exp {
+	return oc.cols
+}
+
+func (oc *OrderByClause) withExps(exps []exp.Exp) Clause {
+	return &OrderByClause{cols: exps}
+}
+
 type OnConflictClause struct {
 	cols    []exp.Exp
 	write   []exp.Exp
@@ -107,22 +145,34 @@ func (oc *OnConflictClause) DoNothing() *OnConflictClause {
 }
 
 func (oc *OnConflictClause) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
-	buf.WriteString("ON CONFLICT (")
-	if err = concatExps(",", ctx, buf, oc.cols); err != nil {
-		return
+	buf.WriteString("ON CONFLICT")
+	if len(oc.cols) > 0 {
+		buf.WriteString(" (")
+		if err = concatExps(ctx.Comma(), ctx, buf, oc.cols); err != nil {
+			return
+		}
+		buf.WriteByte(')')
 	}
-	buf.WriteByte(')')
 	if oc.nothing || len(oc.write) == 0 {
 		buf.WriteString(" DO NOTHING")
 	} else {
 		buf.WriteString(" DO UPDATE SET ")
-		if err = concatExps(",", ctx, buf, oc.write); err != nil {
+		if err = concatExps(ctx.Comma(), ctx, buf, oc.write); err != nil {
 			return
 		}
 	}
 	return
 }
 
+func (oc *OnConflictClause) exps() []exp.Exp {
+	return append(append([]exp.Exp{}, oc.cols...), oc.write...)
+}
+
+func (oc *OnConflictClause) withExps(exps []exp.Exp) Clause {
+	n := len(oc.cols)
+	return &OnConflictClause{cols: exps[:n:n], write: exps[n:], nothing: oc.nothing}
+}
+
 func OnConflict(cols ... interface{}) *OnConflictClause {
 	res := OnConflictClause{}
 	for _, col := range cols {
@@ -133,11 +183,51 @@ func OnConflict(cols ... interface{}) *OnConflictClause {
 	return &res
 }
 
+type LimitClause struct {
+	keyword string
+	count   exp.Exp
+}
+
+// Limits the number of rows to count, an integer or an expression.
+func Limit(count interface{}) *LimitClause {
+	return &LimitClause{keyword: "LIMIT", count: limitExp(count)}
+}
+
+// Skips the first count rows, an integer or an expression.
+func Offset(count interface{}) *LimitClause {
+	return &LimitClause{keyword: "OFFSET", count: limitExp(count)}
+}
+
+func limitExp(count interface{}) exp.Exp {
+	if e, ok := count.(exp.Exp); ok {
+		return e
+	}
+	return exp.Literal(count)
+}
+
+func (lc *LimitClause) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	buf.WriteString(lc.keyword)
+	buf.WriteByte(' ')
+	return lc.count.ToSQL(ctx, buf)
+}
+
+func (lc *LimitClause) exps() []exp.Exp {
+	return []exp.Exp{lc.count}
+}
+
+func (lc *LimitClause) withExps(exps []exp.Exp) Clause {
+	return &LimitClause{keyword: lc.keyword, count: exps[0]}
+}
+
 type SQLRecipe struct {
 	read  []exp.Exp    // Any but AssignExp, CondExp, RelationExp and SchemaExp
 	write []exp.Exp    // AssignExp
 	cond  *exp.CondExp // CondExp
 	addlClauses []Clause
+	ctx   *query.SQLContext
+	scopes []Scope
+	unscoped []Scope
+	deleted int
 }
 
 func SQL() *SQLRecipe {
@@ -149,6 +239,37 @@ func (r *SQLRecipe) AddClause(clauses ... Clause) *SQLRecipe {
 	return r
 }
 
+// Renders the next query into ctx instead of a new context, so that the caller
+// can inspect it afterwards (e.g. its ParamNames). Since a context accumulates
+// placeholder indexes, a fresh one should be given for every query.
+func (r *SQLRecipe) UseContext(ctx *query.SQLContext) *SQLRecipe {
+	r.ctx = ctx
+	return r
+}
+
+func (r *SQLRecipe) context() *query.SQLContext {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return query.NewSQLContext()
+}
+
+// Adds scopes to apply to every statement built from r, in order, after the
+// default ones (see SetDefaultScopes).
+func (r *SQLRecipe) Use(scopes ... Scope) *SQLRecipe {
+	r.scopes = append(r.scopes, scopes...)
+	return r
+}
+
+// Exempts the statements built from r, and their subqueries, from the given
+// scopes, whether default or added with Use, e.g. for maintenance jobs
+// reading across tenants. Scopes have to be named, so that opting out of a
+// policy is always deliberate.
+func (r *SQLRecipe) Unscoped(scopes ... Scope) *SQLRecipe {
+	r.unscoped = append(r.unscoped, scopes...)
+	return r
+}
+
 func (r *SQLRecipe) Read(exps ... interface{}) *SQLRecipe {
 	tmp := make([]exp.Exp, len(exps))
 	for i, e := range exps {
@@ -171,14 +292,14 @@ func (r *SQLRecipe) Write(col exp.Exp, val interface{}) *SQLRecipe {
 }
 
 func (r *SQLRecipe) Where(cond ... interface{}) *SQLRecipe {
-	tmp := make([]exp.Exp, len(cond))
-	for i, e := range cond {
+	var tmp []exp.Exp
+	for _, e := range cond {
 		if t := getExp(e); t != nil {
-			tmp[i] = t
+			tmp = append(tmp, t)
 		}
 	}
 	if r.cond != nil {
-		r.cond = r.cond.And(tmp...)
+		r.cond = exp.And(append([]exp.Exp{r.cond}, tmp...)...)
 	} else {
 		r.cond = exp.And(tmp...)
 	}
@@ -190,122 +311,268 @@ func (r *SQLRecipe) Select(tables ... interface{}) (q string, err error) {
 	for i, tb := range tables {
 		tableExps[i] = getExp(tb)
 	}
+	return r.build(SelectStmt, tableExps)
+}
+
+func (r *SQLRecipe) Update(table exp.Exp) (q string, err error) {
+	return r.build(UpdateStmt, []exp.Exp{table})
+}
+
+func (r *SQLRecipe) Delete(table exp.Exp) (q string, err error) {
+	return r.build(DeleteStmt, []exp.Exp{table})
+}
+
+func (r *SQLRecipe) Insert(table exp.Exp) (q string, err error) {
+	return r.build(InsertStmt, []exp.Exp{table})
+}
+
+func (r *SQLRecipe) build(kind string, tables []exp.Exp) (q string, err error) {
 	buf := &bytes.Buffer{}
-	ctx := query.NewSQLContext()
-	buf.WriteString("SELECT ")
-	if err = concatExps(",", ctx, buf, r.read); err != nil {
+	if err = r.render(r.context(), buf, kind, tables); err != nil {
 		return
 	}
-	if len(tableExps) > 0 {
-		buf.WriteString(" FROM ")
-		if err = concatExps(",", ctx, buf, tableExps); err != nil {
-			return
+	q = buf.String()
+	return
+}
+
+// Applies the scopes of r, then writes the resulting statement.
+func (r *SQLRecipe) render(ctx *query.SQLContext, buf *bytes.Buffer, kind string,
+	tables []exp.Exp) (err error) {
+	stmt, err := r.statement(kind, tables)
+	if err != nil {
+		return
+	}
+	r = stmt.Recipe
+	if stmt.Kind == SelectStmt {
+		return r.writeSelect(ctx, buf, stmt.Tables)
+	}
+	if len(stmt.Tables) != 1 {
+		return fmt.Errorf("%s requires exactly one table", stmt.Kind)
+	}
+	switch stmt.Kind {
+	case InsertStmt:
+		return r.writeInsert(ctx, buf, stmt.Tables[0])
+	case UpdateStmt:
+		return r.writeUpdate(ctx, buf, stmt.Tables[0])
+	case DeleteStmt:
+		return r.writeDelete(ctx, buf, stmt.Tables[0])
+	}
+	return fmt.Errorf("unknown statement kind %q", stmt.Kind)
+}
+
+// Returns the statement r stands for once its scopes are applied. Scopes are
+// given copies of r and of the table list, so r itself is left untouched.
+func (r *SQLRecipe) statement(kind string, tables []exp.Exp) (*Statement, error) {
+	stmt := &Statement{Kind: kind, Recipe: r, Tables: tables}
+	scopes := r.allScopes()
+	if len(scopes) == 0 && len(r.unscoped) == 0 && r.deleted == withoutDeleted {
+		return stmt, nil
+	}
+	stmt.Recipe = r.clone()
+	stmt.Recipe.scopes = nil
+	stmt.Tables = append([]exp.Exp{}, tables...)
+	if len(r.unscoped) > 0 || r.deleted != withoutDeleted {
+		// Subqueries in clauses of other packages can't be reached, and are
+		// scoped as usual.
+		if res, err := stmt.Recipe.Rewrite(r.inherit); err == nil {
+			stmt.Recipe = res
+		}
+		if res, err := rewriteExps(stmt.Tables, r.inherit); err == nil {
+			stmt.Tables = res
 		}
 	}
-	if r.cond != nil {
-		buf.WriteString(" WHERE ")
-		if err = r.cond.ToSQL(ctx, buf); err != nil {
+	for _, s := range scopes {
+		if err := s.Scope(stmt); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// Returns the scopes of the statements built from r: the default ones, then
+// those added with Use, except those r is exempt from.
+func (r *SQLRecipe) allScopes() []Scope {
+	var res []Scope
+	for _, list := range [][]Scope{DefaultScopes(), r.scopes} {
+		for _, s := range list {
+			if !hasScope(res, s) && !hasScope(r.unscoped, s) {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+// Passes the opt-outs of r on to subqueries: the scopes r is exempt from, and
+// its soft-delete switch unless they have one of their own.
+func (r *SQLRecipe) inherit(e exp.Exp) exp.Exp {
+	sub, ok := e.(*SubqueryExp)
+	if !ok {
+		return e
+	}
+	sub = sub.Unscoped(r.unscoped...)
+	if sub.Recipe.deleted == withoutDeleted {
+		sub.Recipe.deleted = r.deleted
+	}
+	return sub
+}
+
+func (r *SQLRecipe) writeSelect(ctx *query.SQLContext, buf *bytes.Buffer,
+	tables []exp.Exp) (err error) {
+	buf.WriteString("SELECT ")
+	if err = concatExps(ctx.Comma(), ctx, buf, r.read); err != nil {
+		return
+	}
+	if len(tables) > 0 {
+		ctx.Newline(buf, " ")
+		buf.WriteString("FROM ")
+		if err = concatExps(ctx.Comma(), ctx, buf, tables); err != nil {
 			return
 		}
 	}
+	if err = r.writeWhere(ctx, buf); err != nil {
+		return
+	}
 	// Parses additional clauses.
-	r.parseClauses(ctx, buf)
-	q = buf.String()
-	return
+	return r.parseClauses(ctx, buf)
 }
 
-func (r *SQLRecipe) Update(table exp.Exp) (q string, err error) {
-	buf := &bytes.Buffer{}
-	ctx := query.NewSQLContext()
+func (r *SQLRecipe) writeUpdate(ctx *query.SQLContext, buf *bytes.Buffer,
+	table exp.Exp) (err error) {
 	buf.WriteString("UPDATE ")
 	if err = table.ToSQL(ctx, buf); err != nil {
 		return
 	}
 	if len(r.write) > 0 {
-		buf.WriteString(" SET ")
-		if err = concatExps(",", ctx, buf, r.write); err != nil {
-			return
-		}
-	}
-	if r.cond != nil {
-		buf.WriteString(" WHERE ")
-		if err = r.cond.ToSQL(ctx, buf); err != nil {
+		ctx.Newline(buf, " ")
+		buf.WriteString("SET ")
+		if err = concatExps(ctx.Comma(), ctx, buf, r.write); err != nil {
 			return
 		}
 	}
-	if len(r.read) > 0 {
-		buf.WriteString(" RETURNING ")
-		if err = concatExps(",", ctx, buf, r.read); err != nil {
-			return
-		}
+	if err = r.writeWhere(ctx, buf); err != nil {
+		return
 	}
 	// Parses additional clauses.
-	r.parseClauses(ctx, buf)
-	q = buf.String()
-	return
+	if err = r.parseClauses(ctx, buf); err != nil {
+		return
+	}
+	return r.writeReturning(ctx, buf)
 }
 
-func (r *SQLRecipe) Delete(table exp.Exp) (q string, err error) {
-	buf := &bytes.Buffer{}
-	ctx := query.NewSQLContext()
+func (r *SQLRecipe) writeDelete(ctx *query.SQLContext, buf *bytes.Buffer,
+	table exp.Exp) (err error) {
 	buf.WriteString("DELETE FROM ")
 	if err = table.ToSQL(ctx, buf); err != nil {
 		return
 	}
-	if r.cond != nil {
-		buf.WriteString(" WHERE ")
-		if err = r.cond.ToSQL(ctx, buf); err != nil {
-			return
-		}
-	}
-	if len(r.read) > 0 {
-		buf.WriteString(" RETURNING ")
-		if err = concatExps(",", ctx, buf, r.read); err != nil {
-			return
-		}
+	if err = r.writeWhere(ctx, buf); err != nil {
+		return
 	}
 	// Parses additional clauses.
-	r.parseClauses(ctx, buf)
-	q = buf.String()
-	return
+	if err = r.parseClauses(ctx, buf); err != nil {
+		return
+	}
+	return r.writeReturning(ctx, buf)
 }
 
-func (r *SQLRecipe) Insert(table exp.Exp) (q string, err error) {
-	buf := &bytes.Buffer{}
-	ctx := query.NewSQLContext()
+func (r *SQLRecipe) writeInsert(ctx *query.SQLContext, buf *bytes.Buffer,
+	table exp.Exp) (err error) {
 	buf.WriteString("INSERT INTO ")
 	if err = table.ToSQL(ctx, buf); err != nil {
 		return
 	}
 	if len(r.write) > 0 {
 		ctx.WriteStatus = query.ColumnOnly
-		buf.WriteString("' (")
-		if err = concatExps(",", ctx, buf, r.write); err != nil {
+		buf.WriteString(" (")
+		if err = concatExps(ctx.Comma(), ctx, buf, r.write); err != nil {
 			return
 		}
 		ctx.WriteStatus = query.ValueOnly
-		buf.WriteString(") VALUES (")
-		if err = concatExps(",", ctx, buf, r.write); err != nil {
+		buf.WriteByte(')')
+		ctx.Newline(buf, " ")
+		buf.WriteString("VALUES (")
+		if err = concatExps(ctx.Comma(), ctx, buf, r.write); err != nil {
 			return
 		}
 		buf.WriteByte(')')
 		ctx.WriteStatus = query.Regular
 	}
+	// Parses additional clauses.
+	if err = r.parseClauses(ctx, buf); err != nil {
+		return
+	}
+	return r.writeReturning(ctx, buf)
+}
+
+// Conditions are simplified first, and left out if always true.
+func (r *SQLRecipe) writeWhere(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	if r.cond == nil {
+		return
+	}
+	cond := exp.Simplify(r.cond)
+	if l, ok := cond.(*exp.LiteralExp); ok && !l.IsExpression() && l.Value == true {
+		return
+	}
+	ctx.Newline(buf, " ")
+	buf.WriteString("WHERE ")
+	return cond.ToSQL(ctx, buf)
+}
+
+func (r *SQLRecipe) writeReturning(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
 	if len(r.read) > 0 {
-		buf.WriteString(" RETURNING ")
-		if err = concatExps(",", ctx, buf, r.read); err != nil {
-			return
-		}
+		ctx.Newline(buf, " ")
+		buf.WriteString("RETURNING ")
+		err = concatExps(ctx.Comma(), ctx, buf, r.read)
 	}
-	// Parses additional clauses.
-	r.parseClauses(ctx, buf)
-	q = buf.String()
 	return
 }
 
+// Returns a copy of r with fn applied to each of its expressions, including
+// those of its clauses, as by exp.Rewrite. Fails if r has clauses from other
+// packages, which cannot be looked into.
+func (r *SQLRecipe) Rewrite(fn func(exp.Exp) exp.Exp) (res *SQLRecipe, err error) {
+	res = r.clone()
+	if res.read, err = rewriteExps(r.read, fn); err != nil {
+		return nil, err
+	}
+	if res.write, err = rewriteExps(r.write, fn); err != nil {
+		return nil, err
+	}
+	if r.cond != nil {
+		var cond exp.Exp
+		if cond, err = exp.Rewrite(r.cond, fn); err != nil {
+			return nil, err
+		}
+		res.cond = toCond(cond)
+	}
+	for i, c := range r.addlClauses {
+		ec, ok := c.(expClause)
+		if !ok {
+			return nil, fmt.Errorf("cannot inspect clause of type %T", c)
+		}
+		exps, err := rewriteExps(ec.exps(), fn)
+		if err != nil {
+			return nil, err
+		}
+		res.addlClauses[i] = ec.withExps(exps)
+	}
+	return res, nil
+}
+
+func (r *SQLRecipe) clone() *SQLRecipe {
+	res := *r
+	res.read = append([]exp.Exp{}, r.read...)
+	res.write = append([]exp.Exp{}, r.write...)
+	res.addlClauses = append([]Clause{}, r.addlClauses...)
+	res.scopes = append([]Scope{}, r.scopes...)
+	res.unscoped = append([]Scope{}, r.unscoped...)
+	return &res
+}
+
 func (r *SQLRecipe) parseClauses(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
 	for _, clause := range r.addlClauses {
-		buf.WriteByte(' ')
+		ctx.Newline(buf, " ")
 		if err = clause.ToSQL(ctx, buf); err != nil {
 			return
 		}
@@ -335,3 +602,21 @@ func getExp(e interface{}) exp.Exp {
 	// TODO: Handle other invalid type.
 	return nil
 }
+
+func rewriteExps(exps []exp.Exp, fn func(exp.Exp) exp.Exp) ([]exp.Exp, error) {
+	res := make([]exp.Exp, len(exps))
+	for i, e := range exps {
+		var err error
+		if res[i], err = exp.Rewrite(e, fn); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func toCond(e exp.Exp) *exp.CondExp {
+	if c, ok := e.(*exp.CondExp); ok {
+		return c
+	}
+	return exp.And(e)
+}
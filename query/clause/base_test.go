@@ -1,8 +1,11 @@
 package clause
 
 import (
+	"database/sql/driver"
 	"testing"
 	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/query/exp"
+	"github.com/tsealex/dbutil/query"
 )
 
 func TestSQLRecipe_Insert(t *testing.T) {
@@ -12,3 +15,361 @@ func TestSQLRecipe_Insert(t *testing.T) {
 
 
 }
+
+func TestSQLRecipe_Where(t *testing.T) {
+	// Filters that end up empty leave no WHERE clause.
+	q, err := SQL().Read(exp.All).Where(exp.Or(), exp.Literal(true)).Where().Select("t")
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM t`, q)
+	q, err = SQL().Read(exp.All).Where(exp.And(exp.Column("a").Eq(exp.Literal(1)))).
+		Where(exp.Not(exp.Or(exp.Column("b"), exp.Literal(false)))).Select("t")
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM t WHERE ((a=1) AND  NOT b)`, q)
+}
+
+func TestSQLRecipe_Pretty(t *testing.T) {
+	pretty := func() *query.SQLContext {
+		ctx := query.NewSQLContext()
+		ctx.Pretty = true
+		return ctx
+	}
+	a, b := exp.Column("a"), exp.Column("b")
+	sub := SQL().Read(exp.Column("uid")).Where(b.Gt(exp.Unbind()))
+	q, err := SQL().UseContext(pretty()).Read(a, exp.As("n", exp.Func("count", exp.All))).
+		Where(a.Eq(exp.Unbind()), exp.Or(b, exp.Binary(a, " IN ", Subquery(sub, "o")))).
+		AddClause(GroupBy(a), Order().By(DESC, a), Limit(10)).
+		Select(exp.Join(exp.Relation("t"), exp.LeftJoin, exp.Relation("u"), a.Eq(b)))
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT a, count(*) AS n
+FROM t
+LEFT JOIN u ON a = b
+WHERE a = $1
+  AND (b OR a IN (
+    SELECT uid
+    FROM o
+    WHERE b > $2
+  ))
+GROUP BY a
+ORDER BY a DESC
+LIMIT 10`, q)
+
+	q, err = SQL().UseContext(pretty()).Write(a, exp.Unbind()).Write(b, exp.Literal(1)).
+		AddClause(OnConflict(a).Write(b,
+			exp.Column("b").SetRelation(exp.Relation("excluded")).Add(exp.Literal(1)))).
+		Read(exp.Column("id")).Insert(exp.Relation("t"))
+	assert.NoError(t, err)
+	assert.Equal(t, `INSERT INTO t (a, b)
+VALUES ($1, 1)
+ON CONFLICT (a) DO UPDATE SET b = excluded.b + 1
+RETURNING id`, q)
+
+	q, err = SQL().UseContext(pretty()).Write(a, a.Sub(exp.Literal(1))).Where(b).
+		Update(exp.Relation("t"))
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE t\nSET a = a - 1\nWHERE b", q)
+}
+
+func TestInterpolate(t *testing.T) {
+	q, err := SQL().Read(exp.Column("id")).
+		Where(exp.Column("name").Eq(exp.TaggedUnbind("name")),
+			exp.Column("alias").NotEq(exp.Unbind()),
+			exp.Column("nick").Eq(exp.TaggedUnbind("name"))).
+		Select(exp.Relation("users"))
+	assert.NoError(t, err)
+	res, err := Interpolate(q, `O'Brien`, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT id FROM users WHERE `+
+		`((name='O''Brien') AND (alias<>NULL) AND (nick='O''Brien'))`, res)
+
+	res, err = Interpolate(`SELECT '$1', E'\'$1', "$1", a$1, $q$ $1 $q$, $1 `+
+		`-- $1`+"\n"+`/* /* $1 */ $1 */ $10`, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT '$1', E'\'$1', "$1", a$1, $q$ $1 $q$, 1 `+
+		`-- $1`+"\n"+`/* /* $1 */ $1 */ 10`, res)
+
+	_, err = Interpolate(`SELECT $2`, 1)
+	assert.Error(t, err)
+}
+
+func TestTenantPolicy(t *testing.T) {
+	tenants := Tenant("tenant_id", exp.TaggedUnbind("tenant"), "orders", "s.items")
+	orders, users := exp.Relation("orders"), exp.Relation("users")
+	build := func(r *SQLRecipe, tables ... interface{}) string {
+		q, err := r.Select(tables...)
+		assert.NoError(t, err)
+		return q
+	}
+
+	r := SQL().Use(tenants).Read(exp.All).Where(exp.Column("id").Eq(exp.Unbind()))
+	assert.Equal(t, `SELECT * FROM orders,users WHERE `+
+		`((id=$1) AND (orders.tenant_id=$2))`, build(r, orders, users))
+	// The recipe itself is left as is.
+	assert.Equal(t, `SELECT * FROM orders WHERE (id=$1)`, build(SQL().Read(exp.All).
+		Where(exp.Column("id").Eq(exp.Unbind())), orders))
+
+	// Inner sides of joins are filtered by the join, the others by subqueries.
+	on := exp.Column("id").SetRelation(exp.Relation("o")).
+		Eq(exp.Column("oid").SetRelation(users))
+	o := exp.As("o", orders)
+	assert.Equal(t, `SELECT * FROM users LEFT JOIN orders AS o ON `+
+		`((o.id=users.oid) AND (o.tenant_id=$1))`,
+		build(SQL().Use(tenants).Read(exp.All), exp.Join(users, exp.LeftJoin, o, on)))
+	assert.Equal(t, `SELECT * FROM orders AS o JOIN users ON (o.id=users.oid) `+
+		`WHERE (o.tenant_id=$1)`,
+		build(SQL().Use(tenants).Read(exp.All), exp.Join(o, exp.InnerJoin, users, on)))
+	assert.Equal(t, `SELECT * FROM users FULL JOIN ((SELECT * FROM orders WHERE `+
+		`(orders.tenant_id=$1))) AS o ON (o.id=users.oid)`,
+		build(SQL().Use(tenants).Read(exp.All), exp.Join(users, exp.FullJoin, o, on)))
+
+	// Subqueries are scoped too, sharing the placeholder.
+	sub := Subquery(SQL().Read(exp.Column("uid")), exp.As("x", orders))
+	assert.Equal(t, `SELECT * FROM users WHERE ((id IN (SELECT uid FROM orders AS x `+
+		`WHERE (x.tenant_id=$1))) AND (name=$2))`,
+		build(SQL().Use(tenants).Read(exp.All).Where(exp.Binary(exp.Column("id"), " IN ", sub),
+			exp.Column("name").Eq(exp.Unbind())), users))
+	// Only registered tables of the given schema are scoped.
+	items := exp.Relation("items")
+	assert.Equal(t, `SELECT * FROM items`, build(SQL().Use(tenants).Read(exp.All), items))
+	ctx := query.NewSQLContext()
+	ctx.ReqSchema = true
+	assert.Equal(t, `SELECT * FROM s.items WHERE (s.items.tenant_id=$1)`,
+		build(SQL().Use(tenants).Read(exp.All).UseContext(ctx),
+			exp.Relation("items").SetSchema(exp.Schema("s"))))
+
+	q, err := SQL().Use(tenants).Write(exp.Column("tenant_id"), exp.Literal(7)).
+		Write(exp.Column("total"), exp.Unbind()).Insert(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `INSERT INTO orders (total,tenant_id) VALUES ($1,$2)`, q)
+	q, err = SQL().Use(tenants).Write(exp.Column("total"), exp.Unbind()).Update(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `UPDATE orders SET total=$1 WHERE (orders.tenant_id=$2)`, q)
+	q, err = SQL().Use(tenants).Delete(o)
+	assert.NoError(t, err)
+	assert.Equal(t, `DELETE FROM orders AS o WHERE (o.tenant_id=$1)`, q)
+
+	// Statements which could bypass the policy are refused.
+	_, err = SQL().Use(tenants).Read(exp.All).Select("orders")
+	assert.Error(t, err)
+	_, err = SQL().Use(tenants).Read(exp.All).
+		Where("id IN (SELECT uid FROM Orders)").Select(users)
+	assert.Error(t, err)
+	_, err = SQL().Use(tenants).Write(exp.Column("tenant_id"), exp.Unbind()).Update(orders)
+	assert.Error(t, err)
+	_, err = SQL().Use(tenants).Write(exp.Column("id"), exp.Unbind()).
+		AddClause(OnConflict(exp.Column("id")).Write(exp.Column("tenant_id"),
+			exp.Unbind())).Insert(orders)
+	assert.Error(t, err)
+	_, err = SQL().Use(tenants).Read(exp.All).Where("id = 1").Select(users)
+	assert.NoError(t, err)
+}
+
+func TestDefaultScopes(t *testing.T) {
+	tenants := Tenant("tenant_id", exp.TaggedUnbind("tenant"), "orders")
+	SetDefaultScopes(tenants)
+	defer SetDefaultScopes()
+	orders, users := exp.Relation("orders"), exp.Relation("users")
+	sub := func() *SubqueryExp {
+		return Subquery(SQL().Read(exp.Column("uid")), orders)
+	}
+
+	// Every recipe is scoped, without a Use, and only once with one.
+	q, err := SQL().Read(exp.All).Select(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM orders WHERE (orders.tenant_id=$1)`, q)
+	q, err = SQL().Use(tenants).Read(exp.All).Select(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM orders WHERE (orders.tenant_id=$1)`, q)
+	q, err = SQL().Read(exp.All).Where(exp.Binary(exp.Column("id"), " IN ", sub())).Select(users)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM users WHERE (id IN (SELECT uid FROM orders `+
+		`WHERE (orders.tenant_id=$1)))`, q)
+	_, err = SQL().Read(exp.All).Select("orders")
+	assert.Error(t, err)
+
+	// Opting out is explicit, and covers subqueries.
+	q, err = SQL().Unscoped(tenants).Read(exp.All).
+		Where(exp.Binary(exp.Column("id"), " IN ", sub())).Select(users)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM users WHERE (id IN (SELECT uid FROM orders))`, q)
+	q, err = SQL().Unscoped(tenants).Use(tenants).Read(exp.All).Select(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM orders`, q)
+	q, err = SQL().Unscoped(SoftDelete("deleted_at", "orders")).Read(exp.All).Select(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM orders WHERE (orders.tenant_id=$1)`, q)
+}
+
+func TestSoftDeletePolicy(t *testing.T) {
+	deletes := SoftDelete("deleted_at", "users")
+	users, orders := exp.Relation("users"), exp.Relation("orders")
+	byId := exp.Column("id").Eq(exp.Unbind())
+	on := exp.Column("uid").SetRelation(orders).Eq(exp.Column("id").SetRelation(exp.Relation("u")))
+
+	q, err := SQL().Use(deletes).Read(exp.All).Where(byId).Select(users)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM users WHERE ((id=$1) AND (users.deleted_at IS NULL))`, q)
+	q, err = SQL().Use(deletes).Read(exp.All).
+		Select(exp.Join(orders, exp.LeftJoin, exp.As("u", users), on))
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM orders LEFT JOIN users AS u ON `+
+		`((orders.uid=u.id) AND (u.deleted_at IS NULL))`, q)
+	q, err = SQL().Use(deletes).WithDeleted().Read(exp.All).Select(users)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM users`, q)
+	q, err = SQL().Use(deletes).OnlyDeleted().Read(exp.All).Select(users)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM users WHERE (users.deleted_at IS NOT NULL)`, q)
+
+	q, err = SQL().Use(deletes).Where(byId).Read(exp.Column("id")).Delete(users)
+	assert.NoError(t, err)
+	assert.Equal(t, `UPDATE users SET deleted_at=now() `+
+		`WHERE ((id=$1) AND (users.deleted_at IS NULL)) RETURNING id`, q)
+	q, err = SQL().Use(deletes).Where(byId).WithDeleted().Delete(users)
+	assert.NoError(t, err)
+	assert.Equal(t, `DELETE FROM users WHERE (id=$1)`, q)
+	q, err = SQL().Use(deletes).OnlyDeleted().Delete(users)
+	assert.NoError(t, err)
+	assert.Equal(t, `DELETE FROM users WHERE (users.deleted_at IS NOT NULL)`, q)
+	q, err = SQL().Use(deletes).Where(byId).Delete(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `DELETE FROM orders WHERE (id=$1)`, q)
+
+	// Restores a user.
+	q, err = SQL().Use(deletes).OnlyDeleted().Write(exp.Column("deleted_at"), exp.Literal(nil)).
+		Where(byId).Update(users)
+	assert.NoError(t, err)
+	assert.Equal(t, `UPDATE users SET deleted_at=NULL `+
+		`WHERE ((id=$1) AND (users.deleted_at IS NOT NULL))`, q)
+
+	// As a default scope, reaching subqueries, which follow the switches.
+	SetDefaultScopes(deletes)
+	defer SetDefaultScopes()
+	byUser := func() *SQLRecipe {
+		return SQL().Read(exp.All).Where(exp.Binary(exp.Column("uid"), " IN ",
+			Subquery(SQL().Read(exp.Column("id")), users)))
+	}
+	q, err = byUser().Select(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM orders WHERE (uid IN (SELECT id FROM users `+
+		`WHERE (users.deleted_at IS NULL)))`, q)
+	q, err = byUser().WithDeleted().Select(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM orders WHERE (uid IN (SELECT id FROM users))`, q)
+	q, err = byUser().OnlyDeleted().Select(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM orders WHERE (uid IN (SELECT id FROM users `+
+		`WHERE (users.deleted_at IS NOT NULL)))`, q)
+	q, err = SQL().WithDeleted().Read(exp.All).Where(exp.Binary(exp.Column("uid"), " IN ",
+		Subquery(SQL().OnlyDeleted().Read(exp.Column("id")), users))).Select(orders)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM orders WHERE (uid IN (SELECT id FROM users `+
+		`WHERE (users.deleted_at IS NOT NULL)))`, q)
+}
+
+// Subqueries in FROM lists are scoped, including those made by other scopes,
+// whatever their order.
+func TestSoftDeletePolicy_Tables(t *testing.T) {
+	soft := SoftDelete("deleted_at", "orders")
+	tenants := Tenant("tenant_id", exp.TaggedUnbind("tenant"), "orders")
+	orders, users := exp.Relation("orders"), exp.Relation("users")
+	q, err := SQL().Use(soft).Read(exp.All).Select(exp.As("x", Subquery(SQL().Read(exp.All), orders)))
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM ((SELECT * FROM orders WHERE (orders.deleted_at IS NULL))) AS x`, q)
+
+	on := exp.Column("id").SetRelation(exp.Relation("o")).Eq(exp.Column("oid").SetRelation(users))
+	join := exp.Join(users, exp.FullJoin, exp.As("o", orders), on)
+	for _, scopes := range [][]Scope{{tenants, soft}, {soft, tenants}} {
+		q, err = SQL().Use(scopes...).Read(exp.All).Select(join)
+		assert.NoError(t, err)
+		assert.Contains(t, q, `FULL JOIN ((SELECT * FROM orders WHERE `)
+		assert.Contains(t, q, `(orders.tenant_id=$1)`)
+		assert.Contains(t, q, `(orders.deleted_at IS NULL)`)
+	}
+}
+
+func TestStatement_Fingerprint(t *testing.T) {
+	users := exp.Relation("users")
+	stmt := func(r *SQLRecipe) uint64 {
+		f, err := (&Statement{Kind: SelectStmt, Recipe: r, Tables: []exp.Exp{users}}).Fingerprint()
+		assert.NoError(t, err)
+		return f
+	}
+	byName := func(name interface{}) *SQLRecipe {
+		return SQL().Read(exp.Column("id")).Where(exp.Column("name").Eq(exp.Literal(name))).
+			AddClause(Order().By(DESC, exp.Column("id")))
+	}
+	f := stmt(byName("alice"))
+	assert.Equal(t, f, stmt(byName("bob")))
+	assert.Equal(t, f, stmt(byName("bob").Where(exp.Literal(true))))
+	assert.NotEqual(t, f, stmt(byName("bob").AddClause(GroupBy(exp.Column("id")))))
+	assert.NotEqual(t, f, stmt(SQL().Read(exp.Column("id")).
+		Where(exp.Column("name").Eq(exp.Literal("alice"))).AddClause(Order().By(ASC, exp.Column("id")))))
+
+	// Subqueries and scopes are taken into account.
+	sub := func(v interface{}) *SQLRecipe {
+		return SQL().Read(exp.All).Where(exp.Binary(exp.Column("id"), " IN ",
+			Subquery(SQL().Read(exp.Column("uid")).Where(exp.Column("v").Eq(exp.Literal(v))), "t")))
+	}
+	assert.Equal(t, stmt(sub(1)), stmt(sub(2)))
+	assert.NotEqual(t, stmt(sub(1)), stmt(byName("alice")))
+	assert.NotEqual(t, f, stmt(byName("alice").Use(SoftDelete("deleted_at", "users"))))
+}
+
+type optionalInt struct {
+	v       int
+	present bool
+}
+
+func (o optionalInt) IsPresent() bool {
+	return o.present
+}
+
+func (o optionalInt) Value() (driver.Value, error) {
+	return int64(o.v), nil
+}
+
+type nullableInt struct {
+	optionalInt
+	null bool
+}
+
+func (o nullableInt) IsValid() bool {
+	return !o.null
+}
+
+func TestSQLRecipe_WritePatch(t *testing.T) {
+	one := 1
+	patch := struct {
+		A      optionalInt
+		B      optionalInt
+		C      *int
+		D      *int        `db:"full_name"`
+		E      nullableInt `db:",notnull"`
+		Name   string
+		Hidden *int        `db:"-"`
+		hidden *int
+	}{A: optionalInt{v: 2, present: true}, D: &one, Name: "x", Hidden: &one, hidden: &one}
+	r := SQL()
+	args, err := r.WritePatch(&patch)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{optionalInt{v: 2, present: true}, 1}, args)
+	q, err := r.Where(exp.Column("id").Eq(exp.Unbind())).Update(exp.Relation("t"))
+	assert.NoError(t, err)
+	assert.Equal(t, `UPDATE t SET a=$1,full_name=$2 WHERE (id=$3)`, q)
+
+	// NULL is only written to nullable columns, and not at all if refused.
+	patch.E = nullableInt{optionalInt: optionalInt{present: true}, null: true}
+	r = SQL()
+	_, err = r.WritePatch(patch)
+	assert.Error(t, err)
+	patch.E.null = false
+	args, err = r.WritePatch(patch)
+	assert.NoError(t, err)
+	assert.Len(t, args, 3)
+	q, err = r.Update(exp.Relation("t"))
+	assert.NoError(t, err)
+	assert.Equal(t, `UPDATE t SET a=$1,full_name=$2,e=$3`, q)
+
+	_, err = SQL().WritePatch(1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package clause
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+// Returns q with each placeholder $n replaced by args[n-1] written as an
+// escaped literal, so that a logged query can be pasted into psql as is. The
+// same placeholder may appear several times (see TaggedUnbind). Placeholders
+// within string literals, quoted identifiers and comments are left untouched.
+// The result is meant for humans; never execute it in place of q.
+func Interpolate(q string, args ... interface{}) (string, error) {
+	buf := &bytes.Buffer{}
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == '\'':
+			// E'' strings allow backslash escapes.
+			escaped := i > 0 && (q[i-1] == 'E' || q[i-1] == 'e') &&
+				(i == 1 || !isIdentChar(q[i-2]))
+			end := skipQuoted(q, i, '\'', escaped)
+			buf.WriteString(q[i:end])
+			i = end
+		case c == '"':
+			end := skipQuoted(q, i, '"', false)
+			buf.WriteString(q[i:end])
+			i = end
+		case c == '-' && strings.HasPrefix(q[i:], "--"):
+			end := strings.IndexByte(q[i:], '\n')
+			if end < 0 {
+				end = len(q) - i
+			}
+			buf.WriteString(q[i : i+end])
+			i += end
+		case c == '/' && strings.HasPrefix(q[i:], "/*"):
+			end := skipComment(q, i)
+			buf.WriteString(q[i:end])
+			i = end
+		case c == '$' && (i == 0 || !isIdentChar(q[i-1])):
+			j := i + 1
+			for j < len(q) && q[j] >= '0' && q[j] <= '9' {
+				j++
+			}
+			if j == i+1 {
+				// Not a placeholder, maybe a dollar-quoted string.
+				end := skipDollarQuoted(q, i)
+				buf.WriteString(q[i:end])
+				i = end
+				continue
+			}
+			n, err := strconv.Atoi(q[i+1 : j])
+			if err != nil || n < 1 || n > len(args) {
+				return "", fmt.Errorf("no argument for placeholder %s", q[i:j])
+			}
+			if err = exp.WriteLiteral(nil, buf, args[n-1]); err != nil {
+				return "", err
+			}
+			i = j
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	return buf.String(), nil
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '$' || c >= 0x80
+}
+
+// Returns the index right after the quoted token starting at q[i]. Doubled
+// quotes are part of the token.
+func skipQuoted(q string, i int, quote byte, escaped bool) int {
+	for j := i + 1; j < len(q); j++ {
+		if escaped && q[j] == '\\' {
+			j++
+		} else if q[j] == quote {
+			if j+1 < len(q) && q[j+1] == quote {
+				j++
+			} else {
+				return j + 1
+			}
+		}
+	}
+	return len(q)
+}
+
+// Block comments nest in Postgres.
+func skipComment(q string, i int) int {
+	depth := 0
+	for j := i; j < len(q)-1; j++ {
+		if q[j] == '/' && q[j+1] == '*' {
+			depth++
+			j++
+		} else if q[j] == '*' && q[j+1] == '/' {
+			depth--
+			j++
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(q)
+}
+
+// Returns the index right after the $tag$...$tag$ string starting at q[i], or
+// i+1 if there is none.
+func skipDollarQuoted(q string, i int) int {
+	j := i + 1
+	for j < len(q) && isIdentChar(q[j]) && q[j] != '$' {
+		j++
+	}
+	if j >= len(q) || q[j] != '$' || j > i+1 && q[i+1] >= '0' && q[i+1] <= '9' {
+		return i + 1
+	}
+	tag := q[i : j+1]
+	if end := strings.Index(q[j+1:], tag); end >= 0 {
+		return j + 1 + end + len(tag)
+	}
+	return len(q)
+}
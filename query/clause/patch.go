@@ -0,0 +1,83 @@
+package clause
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+// Implemented by null.Optional.
+type optional interface {
+	IsPresent() bool
+}
+
+// Implemented by null.Optional, whose present values may still be NULL.
+type nullable interface {
+	IsValid() bool
+}
+
+// Writes the fields of patch, a struct or a pointer to one (e.g. made by
+// dynamic.Object.CreatePatch), which are present: null.Optional fields which
+// are present, including as NULL, and pointers which are not nil. Fields of
+// other types are skipped. The columns are named by the db tag if any, as with
+// sqlx, or else by the field name in lowercase; fields tagged with the notnull
+// option, e.g. `db:",notnull"`, may not be NULL. Values are bound to
+// placeholders, and returned in the order of the assignments, which follow
+// those written before.
+func (r *SQLRecipe) WritePatch(patch interface{}) ([]interface{}, error) {
+	v := reflect.ValueOf(patch)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("patch must be a struct, got %T", patch)
+	}
+	var args []interface{}
+	var cols []*exp.ColumnExp
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		col := strings.ToLower(f.Name)
+		opts := strings.Split(f.Tag.Get("db"), ",")
+		if opts[0] == "-" {
+			continue
+		} else if opts[0] != "" {
+			col = opts[0]
+		}
+		val := v.Field(i)
+		if o, ok := val.Interface().(optional); ok {
+			if !o.IsPresent() {
+				continue
+			}
+			if n, ok := o.(nullable); ok && !n.IsValid() && hasOption(opts[1:], "notnull") {
+				return nil, fmt.Errorf("field %s may not be null", f.Name)
+			}
+		} else if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				continue
+			}
+			val = val.Elem()
+		} else {
+			continue
+		}
+		cols = append(cols, exp.Column(col))
+		args = append(args, val.Interface())
+	}
+	// Nothing is written unless the whole patch is valid.
+	for _, col := range cols {
+		r.Write(col, exp.Unbind())
+	}
+	return args, nil
+}
+
+func hasOption(opts []string, name string) bool {
+	for _, opt := range opts {
+		if strings.TrimSpace(opt) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package clause
+
+import (
+	"fmt"
+	"strings"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+// Set of tables registered to a scope, keyed by lowercase name or
+// "schema.name".
+type tableSet map[string]bool
+
+func (s tableSet) add(tables ... string) {
+	for _, tb := range tables {
+		s[strings.ToLower(tb)] = true
+	}
+}
+
+func (s tableSet) has(rel *exp.RelationExp) bool {
+	name := strings.ToLower(rel.Name)
+	if s[name] {
+		return true
+	}
+	return rel.Schema != nil && s[strings.ToLower(rel.Schema.Name)+"."+name]
+}
+
+// Whether raw SQL mentions a table of s, or might.
+func (s tableSet) mentioned(raw string) bool {
+	words := strings.FieldsFunc(strings.ToLower(raw), func(c rune) bool {
+		return c > 0x7f || !isIdentChar(byte(c))
+	})
+	for _, w := range words {
+		if s[w] {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the condition the rows of rel, referred to as alias if given, must
+// meet, or nil if they are not restricted.
+type tableFilter func(rel *exp.RelationExp, alias string) exp.Exp
+
+// Applies f to the FROM item e. The condition for a table goes to where if all
+// its rows are kept in the result, i.e. if it is not on the nullable side of
+// an outer join. Otherwise it goes to the condition of the join the table is
+// filtered by, or the table is replaced with a filtered subquery.
+func filterFrom(e exp.Exp, nullable bool, f tableFilter, where *[]exp.Exp) (exp.Exp, error) {
+	switch n := e.(type) {
+	case *exp.JoinExp:
+		var on []exp.Exp
+		left, err := filterSide(n.Left, nullable || n.Kind == exp.RightJoin ||
+			n.Kind == exp.FullJoin, n.Kind == exp.InnerJoin || n.Kind == exp.RightJoin,
+			f, where, &on)
+		if err != nil {
+			return nil, err
+		}
+		right, err := filterSide(n.Right, nullable || n.Kind == exp.LeftJoin ||
+			n.Kind == exp.FullJoin, n.Kind == exp.InnerJoin || n.Kind == exp.LeftJoin,
+			f, where, &on)
+		if err != nil {
+			return nil, err
+		}
+		if left == n.Left && right == n.Right && len(on) == 0 {
+			return e, nil
+		}
+		cond := n.On
+		if len(on) > 0 {
+			if cond != nil {
+				on = append([]exp.Exp{cond}, on...)
+			}
+			cond = exp.Simplify(exp.And(on...))
+		}
+		return exp.Join(left, n.Kind, right, cond), nil
+	case *exp.AliasExp:
+		switch n.SubExp.(type) {
+		case *exp.RelationExp:
+		case *SubqueryExp, *exp.FuncExp, *exp.LiteralExp:
+			return e, nil
+		default:
+			return nil, fmt.Errorf("cannot scope table %s", n.Name)
+		}
+	case *SubqueryExp, *exp.FuncExp, *exp.LiteralExp:
+		return e, nil
+	}
+	rel, alias := relationOf(e)
+	if rel == nil {
+		return nil, fmt.Errorf("cannot scope table %T", e)
+	}
+	if cond := f(rel, alias); cond != nil {
+		if nullable {
+			return filteredTable(rel, alias, f), nil
+		}
+		*where = append(*where, cond)
+	}
+	return e, nil
+}
+
+// Applies f to a side of a join, adding the condition for a table to on if
+// the join filters that side.
+func filterSide(e exp.Exp, nullable bool, filtered bool, f tableFilter,
+	where *[]exp.Exp, on *[]exp.Exp) (exp.Exp, error) {
+	if rel, alias := relationOf(e); rel != nil && nullable && filtered {
+		if cond := f(rel, alias); cond != nil {
+			*on = append(*on, cond)
+		}
+		return e, nil
+	}
+	return filterFrom(e, nullable, f, where)
+}
+
+// Returns a subquery selecting the rows of rel meeting f, under the same name.
+func filteredTable(rel *exp.RelationExp, alias string, f tableFilter) exp.Exp {
+	if alias == "" {
+		alias = rel.Name
+	}
+	r := SQL().Read(exp.All).Where(f(rel, ""))
+	return exp.As(alias, Subquery(r, rel))
+}
+
+// Returns the relation e refers to as a table, and its alias if any.
+func relationOf(e exp.Exp) (*exp.RelationExp, string) {
+	switch n := e.(type) {
+	case *exp.RelationExp:
+		return n, ""
+	case *exp.AliasExp:
+		if rel, ok := n.SubExp.(*exp.RelationExp); ok {
+			return rel, n.Name
+		}
+	}
+	return nil, ""
+}
+
+func toInterfaces(exps []exp.Exp) []interface{} {
+	res := make([]interface{}, len(exps))
+	for i, e := range exps {
+		res[i] = e
+	}
+	return res
+}
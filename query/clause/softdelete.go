@@ -0,0 +1,121 @@
+package clause
+
+import (
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+// Which soft-deleted rows a recipe deals with (see SoftDeletePolicy).
+const (
+	withoutDeleted = iota
+	withDeleted    = iota
+	onlyDeleted    = iota
+)
+
+// SoftDeletePolicy is a scope for tables whose rows are marked as deleted by
+// setting Column, rather than removed. Reads and updates of registered tables,
+// including joins and subqueries, skip rows where Column is set, and deletes
+// become updates setting it to now(). Like other policies, it should be a
+// default scope:
+//
+//   SetDefaultScopes(SoftDelete("deleted_at", "users"))
+//   q, err := SQL().Where(exp.Column("id").Eq(exp.Unbind())).
+//   	Delete(exp.Relation("users"))
+//   // UPDATE users SET deleted_at=now()
+//   // WHERE ((id=$1) AND (users.deleted_at IS NULL))
+//
+// Recipes may opt out with WithDeleted, which also makes deletes actually
+// remove rows, or only deal with deleted rows with OnlyDeleted, e.g. to
+// restore or purge them. Subqueries follow the switch of the statement they
+// are in, unless they have their own.
+type SoftDeletePolicy struct {
+	Column string
+	tables tableSet
+}
+
+func SoftDelete(column string, tables ... string) *SoftDeletePolicy {
+	p := &SoftDeletePolicy{Column: column, tables: tableSet{}}
+	return p.Register(tables...)
+}
+
+// Registers tables, by name or as "schema.name" to only match those of the
+// given schema. Names are case-insensitive.
+func (p *SoftDeletePolicy) Register(tables ... string) *SoftDeletePolicy {
+	p.tables.add(tables...)
+	return p
+}
+
+func (p *SoftDeletePolicy) Scope(stmt *Statement) (err error) {
+	// Subqueries get the policy too, wherever they are, including those other
+	// scopes made of the tables.
+	scope := func(e exp.Exp) exp.Exp {
+		if sub, ok := e.(*SubqueryExp); ok {
+			return sub.Use(p)
+		}
+		return e
+	}
+	r, err := stmt.Recipe.Rewrite(scope)
+	if err != nil {
+		return
+	}
+	tables, err := rewriteExps(stmt.Tables, scope)
+	if err != nil {
+		return
+	}
+	stmt.Recipe, stmt.Tables = r, tables
+	filter := func(rel *exp.RelationExp, alias string) exp.Exp {
+		if !p.tables.has(rel) {
+			return nil
+		}
+		if alias != "" {
+			rel = exp.Relation(alias)
+		}
+		col := exp.Column(p.Column).SetRelation(rel)
+		if r.deleted == onlyDeleted {
+			return col.IsNot(exp.Literal(nil))
+		}
+		return col.Is(exp.Literal(nil))
+	}
+	if r.deleted == withDeleted && stmt.Kind != DeleteStmt {
+		return
+	}
+	switch stmt.Kind {
+	case SelectStmt:
+		var where []exp.Exp
+		for i, tb := range stmt.Tables {
+			if stmt.Tables[i], err = filterFrom(tb, false, filter, &where); err != nil {
+				return
+			}
+		}
+		if len(where) > 0 {
+			r.Where(toInterfaces(where)...)
+		}
+	case UpdateStmt, DeleteStmt:
+		rel, alias := relationOf(stmt.Tables[0])
+		if rel == nil || !p.tables.has(rel) {
+			return
+		}
+		if stmt.Kind == DeleteStmt && r.deleted == withoutDeleted {
+			// Rows deleted already keep their deletion time.
+			stmt.Kind = UpdateStmt
+			r.write = []exp.Exp{exp.Assign(exp.Column(p.Column), exp.Func("now"))}
+		}
+		if r.deleted != withDeleted {
+			r.Where(filter(rel, alias))
+		}
+	}
+	return
+}
+
+// Makes soft-delete scopes leave the statements built from r as they are:
+// deleted rows are read and updated like the others, and deletes remove rows.
+func (r *SQLRecipe) WithDeleted() *SQLRecipe {
+	r.deleted = withDeleted
+	return r
+}
+
+// Makes soft-delete scopes restrict the statements built from r to deleted
+// rows. Deletes remove them.
+func (r *SQLRecipe) OnlyDeleted() *SQLRecipe {
+	r.deleted = onlyDeleted
+	return r
+}
@@ -0,0 +1,126 @@
+package clause
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"github.com/tsealex/dbutil/query"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+// Statement kinds.
+const (
+	SelectStmt = "SELECT"
+	InsertStmt = "INSERT"
+	UpdateStmt = "UPDATE"
+	DeleteStmt = "DELETE"
+)
+
+// Statement is a recipe together with what it is meant to be rendered as.
+type Statement struct {
+	Kind   string
+	Recipe *SQLRecipe
+	// The FROM list of a SELECT, or the single target of the other kinds.
+	Tables []exp.Exp
+	// Placeholder tags bound to fixed indexes, e.g. to keep the $n of a parsed
+	// query pointing at the same arguments.
+	Params map[string]int
+}
+
+// Safe for concurrent use, as long as the recipe is not given a context with
+// SQLRecipe.UseContext.
+func (s *Statement) Build() (q string, err error) {
+	r := s.Recipe
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = query.NewSQLContext()
+		// Relations are qualified with their schema when they have one.
+		ctx.ReqSchema = true
+		for tag, i := range s.Params {
+			ctx.Bind(tag, i)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err = r.render(ctx, buf, s.Kind, s.Tables); err != nil {
+		return
+	}
+	q = buf.String()
+	return
+}
+
+// Returns a hash of the shape of the statement once scoped, which ignores the
+// values of literals and placeholders (see exp.Fingerprint), e.g. to group
+// query statistics.
+func (s *Statement) Fingerprint() (uint64, error) {
+	stmt, err := s.Recipe.statement(s.Kind, s.Tables)
+	if err != nil {
+		return 0, err
+	}
+	r := stmt.Recipe
+	h := fnv.New64a()
+	section := func(name string, exps []exp.Exp) {
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(exps))
+		for _, e := range exps {
+			binary.Write(h, binary.LittleEndian, exp.Fingerprint(e))
+		}
+	}
+	section(stmt.Kind, stmt.Tables)
+	section("read", r.read)
+	section("write", r.write)
+	var where []exp.Exp
+	if r.cond != nil {
+		where = append(where, exp.Simplify(r.cond))
+	}
+	section("where", where)
+	for _, c := range r.addlClauses {
+		switch n := c.(type) {
+		case *OnConflictClause:
+			section(fmt.Sprintf("%T %t", c, n.nothing || len(n.write) == 0), n.exps())
+		case *LimitClause:
+			section(n.keyword, n.exps())
+		case expClause:
+			section(fmt.Sprintf("%T", c), n.exps())
+		default:
+			buf := &bytes.Buffer{}
+			if err = c.ToSQL(query.NewSQLContext(), buf); err != nil {
+				return 0, err
+			}
+			fmt.Fprintf(h, "%T\x00%s\x00", c, buf.Bytes())
+		}
+	}
+	return h.Sum64(), nil
+}
+
+// Scope adjusts statements right before they are rendered, e.g. to enforce a
+// policy on the tables they touch (see SetDefaultScopes and SQLRecipe.Use).
+// The statement holds copies of the recipe and table list, which may be
+// changed freely, but the expressions themselves are shared and must be
+// replaced rather than modified. Scopes are compared with ==, so they should be pointers or other
+// comparable values.
+type Scope interface {
+	Scope(stmt *Statement) error
+}
+
+var defaultScopes struct {
+	sync.RWMutex
+	scopes []Scope
+}
+
+// Sets the scopes applied to every statement built by this package, whatever
+// recipe it is built from, including subqueries, before those added with
+// SQLRecipe.Use. Policies which must hold for the whole application, e.g.
+// tenant scoping, belong here rather than in Use, which is easily forgotten.
+// Recipes opt out with SQLRecipe.Unscoped. Replaces the scopes set before.
+func SetDefaultScopes(scopes ... Scope) {
+	defaultScopes.Lock()
+	defer defaultScopes.Unlock()
+	defaultScopes.scopes = append([]Scope{}, scopes...)
+}
+
+func DefaultScopes() []Scope {
+	defaultScopes.RLock()
+	defer defaultScopes.RUnlock()
+	return defaultScopes.scopes
+}
@@ -0,0 +1,85 @@
+package clause
+
+import (
+	"bytes"
+	"github.com/tsealex/dbutil/query"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+// SubqueryExp is a SELECT used within another statement, e.g. as a derived
+// table or with exp.Binary(col, " IN ", sub). It is rendered with the context
+// of the enclosing statement, so placeholders are numbered across both.
+type SubqueryExp struct {
+	exp.BaseExp
+	Recipe *SQLRecipe
+	Tables []exp.Exp
+}
+
+func Subquery(r *SQLRecipe, tables ... interface{}) *SubqueryExp {
+	res := &SubqueryExp{Recipe: r}
+	for _, tb := range tables {
+		res.Tables = append(res.Tables, getExp(tb))
+	}
+	res.Exp = res
+	return res
+}
+
+// Returns a copy of s whose recipe has the given scopes added, unless it
+// already had them.
+func (s *SubqueryExp) Use(scopes ... Scope) *SubqueryExp {
+	res := *s
+	res.Recipe = s.Recipe.clone()
+	for _, sc := range scopes {
+		if !hasScope(res.Recipe.scopes, sc) {
+			res.Recipe.scopes = append(res.Recipe.scopes, sc)
+		}
+	}
+	res.Exp = &res
+	return &res
+}
+
+// Returns a copy of s whose recipe is exempt from the given scopes.
+func (s *SubqueryExp) Unscoped(scopes ... Scope) *SubqueryExp {
+	res := *s
+	res.Recipe = s.Recipe.clone()
+	for _, sc := range scopes {
+		if !hasScope(res.Recipe.unscoped, sc) {
+			res.Recipe.unscoped = append(res.Recipe.unscoped, sc)
+		}
+	}
+	res.Exp = &res
+	return &res
+}
+
+func hasScope(scopes []Scope, sc Scope) bool {
+	for _, s := range scopes {
+		if s == sc {
+			return true
+		}
+	}
+	return false
+}
+
+func (s SubqueryExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	status := ctx.WriteStatus
+	ctx.WriteStatus = query.Regular
+	buf.WriteByte('(')
+	// Pretty subqueries are indented on lines of their own.
+	ctx.Nest()
+	ctx.Newline(buf, "")
+	err = s.Recipe.render(ctx, buf, SelectStmt, s.Tables)
+	ctx.Unnest()
+	if err != nil {
+		return
+	}
+	ctx.Newline(buf, "")
+	buf.WriteByte(')')
+	ctx.WriteStatus = status
+	return
+}
+
+// Statements that fail to be scoped all have the fingerprint 0.
+func (s *SubqueryExp) Fingerprint() uint64 {
+	res, _ := (&Statement{Kind: SelectStmt, Recipe: s.Recipe, Tables: s.Tables}).Fingerprint()
+	return res
+}
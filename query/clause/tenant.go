@@ -0,0 +1,153 @@
+package clause
+
+import (
+	"fmt"
+	"strings"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+// TenantPolicy is a scope restricting statements to the rows of a single
+// tenant. For each registered table they touch, it requires Column to equal
+// Value: the condition is added to reads, updates and deletes, including
+// joins and subqueries, and the column is set on inserts. Statements it
+// cannot verify, e.g. with raw SQL mentioning a registered table, fail to
+// build instead. Value is typically a TaggedUnbind so that the tenant is
+// given as an argument. The policy should be a default scope, so that it
+// applies to every statement:
+//
+//   tenants := Tenant("tenant_id", exp.TaggedUnbind("tenant"), "orders")
+//   SetDefaultScopes(tenants)
+//   q, err := SQL().Read(exp.All).Select(exp.Relation("orders"))
+//   // SELECT * FROM orders WHERE (orders.tenant_id=$1)
+type TenantPolicy struct {
+	Column string
+	Value  exp.Exp
+	tables tableSet
+}
+
+func Tenant(column string, value exp.Exp, tables ... string) *TenantPolicy {
+	p := &TenantPolicy{Column: column, Value: value, tables: tableSet{}}
+	return p.Register(tables...)
+}
+
+// Registers tables, by name or as "schema.name" to only match those of the
+// given schema. Names are case-insensitive.
+func (p *TenantPolicy) Register(tables ... string) *TenantPolicy {
+	p.tables.add(tables...)
+	return p
+}
+
+// The condition for the rows of rel, referred to as alias if given.
+func (p *TenantPolicy) predicate(rel *exp.RelationExp, alias string) exp.Exp {
+	if !p.tables.has(rel) {
+		return nil
+	}
+	if alias != "" {
+		rel = exp.Relation(alias)
+	}
+	return exp.Column(p.Column).SetRelation(rel).Eq(p.Value)
+}
+
+func (p *TenantPolicy) Scope(stmt *Statement) (err error) {
+	// Subqueries get the policy too, and apply it when they are rendered.
+	var unverified error
+	scope := func(e exp.Exp) exp.Exp {
+		switch n := e.(type) {
+		case *SubqueryExp:
+			return n.Use(p)
+		case *exp.LiteralExp:
+			if n.IsExpression() && p.tables.mentioned(n.Value.(string)) && unverified == nil {
+				unverified = fmt.Errorf("cannot verify tenant scope of %q", n.Value)
+			}
+		}
+		return e
+	}
+	r, err := stmt.Recipe.Rewrite(scope)
+	if err != nil {
+		return
+	}
+	tables, err := rewriteExps(stmt.Tables, scope)
+	if err != nil {
+		return
+	}
+	if unverified != nil {
+		return unverified
+	}
+	stmt.Recipe, stmt.Tables = r, tables
+
+	if stmt.Kind == SelectStmt {
+		var where []exp.Exp
+		for i, tb := range tables {
+			if tables[i], err = filterFrom(tb, false, p.predicate, &where); err != nil {
+				return
+			}
+		}
+		if len(where) > 0 {
+			r.Where(toInterfaces(where)...)
+		}
+		return
+	}
+	if len(tables) != 1 {
+		return
+	}
+	rel, alias := relationOf(tables[0])
+	if rel == nil || !p.tables.has(rel) {
+		// Raw SQL mentioning no registered table is fine.
+		if _, ok := tables[0].(*exp.LiteralExp); ok || rel != nil {
+			return
+		}
+		return fmt.Errorf("cannot verify tenant scope of %s target %T", stmt.Kind, tables[0])
+	}
+	switch stmt.Kind {
+	case InsertStmt:
+		// Whatever the value given, if any, the tenant's is used.
+		var write []exp.Exp
+		for _, w := range r.write {
+			if !p.assigns(w) {
+				write = append(write, w)
+			}
+		}
+		r.write = append(write, exp.Assign(exp.Column(p.Column), p.Value))
+	case UpdateStmt:
+		if err = p.checkWrite(r.write); err != nil {
+			return
+		}
+		r.Where(p.predicate(rel, alias))
+	case DeleteStmt:
+		r.Where(p.predicate(rel, alias))
+	}
+	// Rows must not be moved to another tenant upon conflict either.
+	for _, c := range r.addlClauses {
+		if oc, ok := c.(*OnConflictClause); ok {
+			if err = p.checkWrite(oc.write); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (p *TenantPolicy) checkWrite(write []exp.Exp) error {
+	for _, w := range write {
+		if p.assigns(w) {
+			return fmt.Errorf("cannot update tenant column %s", p.Column)
+		}
+	}
+	return nil
+}
+
+// Whether e assigns the tenant column.
+func (p *TenantPolicy) assigns(e exp.Exp) bool {
+	a, ok := e.(*exp.AssignExp)
+	if !ok {
+		return false
+	}
+	switch col := a.Col.(type) {
+	case *exp.ColumnExp:
+		return strings.EqualFold(col.Name, p.Column)
+	case *exp.LiteralExp:
+		raw, _ := col.Value.(string)
+		return strings.EqualFold(strings.TrimSpace(raw), p.Column)
+	}
+	return true
+}
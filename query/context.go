@@ -1,17 +1,34 @@
 package query
 
+import (
+	"bytes"
+	"strings"
+)
+
 type SQLContext struct {
 	TagMap    map[string]int
 	ReqSchema bool
+	// Postgres if nil.
+	Dialect *Dialect
 	// For insertion
 	WriteStatus uint8
+	// Name of the column (or the tag) each placeholder index is bound to, when
+	// it can be told from the query. Used to redact sensitive arguments.
+	ParamNames map[int]string
+	// Writes statements over several lines, one per clause, and leaves out
+	// the parentheses that operator precedence makes redundant.
+	Pretty bool
+	// Indentation unit of pretty output, two spaces if empty.
+	Indent string
 
 	index int
+	depth int
 }
 
 func NewSQLContext() *SQLContext {
 	return &SQLContext{
-		TagMap: map[string]int{},
+		TagMap:     map[string]int{},
+		ParamNames: map[int]string{},
 	}
 }
 
@@ -20,6 +37,69 @@ func (ctx *SQLContext) NextIndex() int {
 	return ctx.index
 }
 
+// Makes tag render as placeholder index i, keeping the indexes handed out
+// afterwards past it.
+func (ctx *SQLContext) Bind(tag string, i int) {
+	ctx.TagMap[tag] = i
+	if i > ctx.index {
+		ctx.index = i
+	}
+}
+
+// Safe to call on a nil context.
+func (ctx *SQLContext) GetDialect() *Dialect {
+	if ctx == nil || ctx.Dialect == nil {
+		return Postgres
+	}
+	return ctx.Dialect
+}
+
+// Returns the last placeholder index handed out.
+func (ctx *SQLContext) Index() int {
+	return ctx.index
+}
+
+func (ctx *SQLContext) NameParam(i int, name string) {
+	if ctx.ParamNames == nil {
+		ctx.ParamNames = map[int]string{}
+	}
+	ctx.ParamNames[i] = name
+}
+
+// Starts a new line at the current depth if ctx is pretty, and otherwise
+// writes sep. Safe to call on a nil context.
+func (ctx *SQLContext) Newline(buf *bytes.Buffer, sep string) {
+	if ctx == nil || !ctx.Pretty {
+		buf.WriteString(sep)
+		return
+	}
+	indent := ctx.Indent
+	if indent == "" {
+		indent = "  "
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(strings.Repeat(indent, ctx.depth))
+}
+
+// Indents the lines started by Newline one level deeper, until Unnest.
+func (ctx *SQLContext) Nest() {
+	ctx.depth += 1
+}
+
+func (ctx *SQLContext) Unnest() {
+	if ctx.depth > 0 {
+		ctx.depth -= 1
+	}
+}
+
+// Returns the separator of list items. Safe to call on a nil context.
+func (ctx *SQLContext) Comma() string {
+	if ctx != nil && ctx.Pretty {
+		return ", "
+	}
+	return ","
+}
+
 func (ctx *SQLContext) GetTagIndex(tag string) int {
 	if i, ok := ctx.TagMap[tag]; ok {
 		return i
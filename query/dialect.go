@@ -0,0 +1,17 @@
+package query
+
+// Dialect holds what differs between the DBMSes we render SQL for.
+type Dialect struct {
+	Name string
+	// Character used to quote identifiers; embedded ones are doubled.
+	IdentQuote byte
+	// Longest identifier accepted, in bytes. Postgres silently truncates
+	// longer ones, so we refuse them instead.
+	MaxIdentLen int
+}
+
+var (
+	Postgres = &Dialect{Name: "postgres", IdentQuote: '"', MaxIdentLen: 63}
+	MySQL    = &Dialect{Name: "mysql", IdentQuote: '`', MaxIdentLen: 64}
+	SQLite   = &Dialect{Name: "sqlite", IdentQuote: '"'}
+)
@@ -16,127 +16,155 @@ type BaseExp struct {
 }
 
 func (b *BaseExp) Add(exp Exp) *BinaryExp {
-	return Binary(b, "+", exp)
+	return Binary(b.Exp, "+", exp)
 }
 
 func (b *BaseExp) Sub(exp Exp) *BinaryExp {
-	return Binary(b, "-", exp)
+	return Binary(b.Exp, "-", exp)
 }
 
 func (b *BaseExp) Mul(exp Exp) *BinaryExp {
-	return Binary(b, "*", exp)
+	return Binary(b.Exp, "*", exp)
 }
 
 func (b *BaseExp) Div(exp Exp) *BinaryExp {
-	return Binary(b, "/", exp)
+	return Binary(b.Exp, "/", exp)
 }
 
 func (b *BaseExp) Mod(exp Exp) *BinaryExp {
-	return Binary(b, "%", exp)
+	return Binary(b.Exp, "%", exp)
 }
 
+// Renders as ^, the exponentiation operator of Postgres. It used to render as
+// %, i.e. the modulo.
 func (b *BaseExp) Expo(exp Exp) *BinaryExp {
-	return Binary(b, "%", exp)
+	return Binary(b.Exp, "^", exp)
 }
 
 func (b *BaseExp) Is(exp *LiteralExp) *BinaryExp {
-	return Binary(b, " IS ", exp)
+	return Binary(b.Exp, " IS ", exp)
 }
 
 func (b *BaseExp) IsNot(exp *LiteralExp) *BinaryExp {
-	return Binary(b, " IS NOT ", exp)
+	return Binary(b.Exp, " IS NOT ", exp)
 }
 
 func (b *BaseExp) Gt(exp Exp) *BinaryExp {
-	return Binary(b, ">", exp)
+	return Binary(b.Exp, ">", exp)
 }
 
 func (b *BaseExp) Lt(exp Exp) *BinaryExp {
-	return Binary(b, "<", exp)
+	return Binary(b.Exp, "<", exp)
 }
 
 func (b *BaseExp) Gte(exp Exp) *BinaryExp {
-	return Binary(b, ">=", exp)
+	return Binary(b.Exp, ">=", exp)
 }
 
 func (b *BaseExp) Lte(exp Exp) *BinaryExp {
-	return Binary(b, "<=", exp)
+	return Binary(b.Exp, "<=", exp)
 }
 
 func (b *BaseExp) RightShift(exp Exp) *BinaryExp {
-	return Binary(b, ">>", exp)
+	return Binary(b.Exp, ">>", exp)
 }
 
 func (b *BaseExp) LeftShift(exp Exp) *BinaryExp {
-	return Binary(b, "<<", exp)
+	return Binary(b.Exp, "<<", exp)
 }
 
 func (b *BaseExp) Concat(exp Exp) *BinaryExp {
-	return Binary(b, "||", exp)
+	return Binary(b.Exp, "||", exp)
 }
 
 func (b *BaseExp) Union(exp Exp) *BinaryExp {
-	return Binary(b, "|", exp)
+	return Binary(b.Exp, "|", exp)
 }
 
 func (b *BaseExp) Overlap(exp Exp) *BinaryExp {
-	return Binary(b, "&&", exp)
+	return Binary(b.Exp, "&&", exp)
 }
 
 func (b *BaseExp) Intersect(exp Exp) *BinaryExp {
-	return Binary(b, "&", exp)
+	return Binary(b.Exp, "&", exp)
 }
 
 func (b *BaseExp) Contain(exp Exp) *BinaryExp {
-	return Binary(b, "@>", exp)
+	return Binary(b.Exp, "@>", exp)
 }
 
 func (b *BaseExp) ContainedBy(exp Exp) *BinaryExp {
-	return Binary(b, "<@", exp)
+	return Binary(b.Exp, "<@", exp)
+}
+
+// Whether two ranges are adjacent.
+func (b *BaseExp) Adjacent(exp Exp) *BinaryExp {
+	return Binary(b.Exp, "-|-", exp)
+}
+
+// Whether a range does not extend to the right of another.
+func (b *BaseExp) NotExtendRight(exp Exp) *BinaryExp {
+	return Binary(b.Exp, "&<", exp)
+}
+
+// Whether a range does not extend to the left of another.
+func (b *BaseExp) NotExtendLeft(exp Exp) *BinaryExp {
+	return Binary(b.Exp, "&>", exp)
 }
 
 func (b *BaseExp) Eq(exp Exp) *BinaryExp {
-	return Binary(b, "=", exp)
+	return Binary(b.Exp, "=", exp)
 }
 
 func (b *BaseExp) NotEq(exp Exp) *BinaryExp {
-	return Binary(b, "<>", exp)
+	return Binary(b.Exp, "<>", exp)
+}
+
+// Whether the value is an element of array, e.g. an Array or a placeholder for
+// a pq.Array. Renders as =ANY(array), which unlike IN also works with an empty
+// array.
+func (b *BaseExp) In(array Exp) *BinaryExp {
+	return Binary(b.Exp, "=", Func("ANY", array))
+}
+
+func (b *BaseExp) NotIn(array Exp) *BinaryExp {
+	return Binary(b.Exp, "<>", Func("ALL", array))
 }
 
 func (b *BaseExp) Match(exp Exp, caseSens bool) *BinaryExp {
 	if caseSens {
-		return Binary(b, "~", exp)
+		return Binary(b.Exp, "~", exp)
 	} else {
-		return Binary(b, "~*", exp)
+		return Binary(b.Exp, "~*", exp)
 	}
 }
 
 func (b *BaseExp) NotMatch(exp Exp, caseSens bool) *BinaryExp {
 	if caseSens {
-		return Binary(b, "!~", exp)
+		return Binary(b.Exp, "!~", exp)
 	} else {
-		return Binary(b, "!~*", exp)
+		return Binary(b.Exp, "!~*", exp)
 	}
 }
 
 func (b *BaseExp) Like(pattern *LiteralExp) *BinaryExp {
-	return Binary(b, " ~~ ", pattern)
+	return Binary(b.Exp, " ~~ ", pattern)
 }
 
 func (b *BaseExp) ILike(pattern *LiteralExp) *BinaryExp {
-	return Binary(b, " ~~* ", pattern)
+	return Binary(b.Exp, " ~~* ", pattern)
 }
 
 func (b *BaseExp) NotLike(pattern *LiteralExp) *BinaryExp {
-	return Binary(b, " !~~ ", pattern)
+	return Binary(b.Exp, " !~~ ", pattern)
 }
 
 func (b *BaseExp) NotILike(pattern *LiteralExp) *BinaryExp {
-	return Binary(b, " !~~* ", pattern)
+	return Binary(b.Exp, " !~~* ", pattern)
 }
 
 func (b *BaseExp) SimilarTo(pattern *LiteralExp) *BinaryExp {
-	return Binary(b, " SIMILAR TO ", pattern)
+	return Binary(b.Exp, " SIMILAR TO ", pattern)
 }
 
 func (b BaseExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
@@ -150,7 +178,6 @@ type LiteralExp struct {
 }
 
 func Literal(value interface{}) (res *LiteralExp) {
-	// TODO: type check, return nil if not a primitive type
 	res = &LiteralExp{Value: value}
 	res.isExp = false
 	res.Exp = res
@@ -158,18 +185,22 @@ func Literal(value interface{}) (res *LiteralExp) {
 }
 
 func Expression(value string) (res *LiteralExp) {
-	// TODO: type check, return nil if not a primitive type
 	res = &LiteralExp{Value: value}
 	res.isExp = true
 	res.Exp = res
 	return res
 }
 
+// Whether l is raw SQL rather than a value.
+func (l *LiteralExp) IsExpression() bool {
+	return l.isExp
+}
+
 func (l LiteralExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
-	if str, ok := l.Value.(string); !l.isExp && ok {
-		_, err = buf.WriteString(fmt.Sprintf(`'%s'`, str))
+	if str, ok := l.Value.(string); l.isExp && ok {
+		_, err = buf.WriteString(str)
 	} else {
-		_, err = buf.WriteString(fmt.Sprint(l.Value))
+		err = WriteLiteral(ctx, buf, l.Value)
 	}
 	return
 }
@@ -182,29 +213,32 @@ type ArrayExp struct {
 }
 
 func Array(values ... interface{}) *ArrayExp {
-	// TODO: type check, return nil if no a primitive type nor Exp instance.
 	res := &ArrayExp{Values: values}
 	res.Exp = res
 	return res
 }
 
 func (a ArrayExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	// ARRAY[] has no type, while '{}' takes that of its context.
+	if len(a.Values) == 0 {
+		buf.WriteString("'{}'")
+		return
+	}
+	if err = a.checkElems(); err != nil {
+		return
+	}
 	buf.WriteString("ARRAY")
 	buf.WriteByte('[')
 	for i, val := range a.Values {
 		if i > 0 {
-			buf.WriteByte(',')
+			buf.WriteString(ctx.Comma())
 		}
 		if exp, ok := val.(Exp); ok {
 			if err = exp.ToSQL(ctx, buf); err != nil {
 				return
 			}
-		} else {
-			if str, ok := val.(string); ok {
-				_, err = buf.WriteString(fmt.Sprintf(`'%s'`, str))
-			} else {
-				_, err = buf.WriteString(fmt.Sprint(val))
-			}
+		} else if err = WriteLiteral(ctx, buf, val); err != nil {
+			return
 		}
 	}
 	buf.WriteByte(']')
@@ -216,8 +250,10 @@ type UnbindExp struct {
 	Tag string // Optional
 }
 
-func Unbind() *UnbindExp {
-	return &UnbindExp{}
+func Unbind() (res *UnbindExp) {
+	res = &UnbindExp{}
+	res.Exp = res
+	return res
 }
 
 func TaggedUnbind(tag string) (res *UnbindExp) {
@@ -231,6 +267,9 @@ func (u UnbindExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
 	var i int
 	if u.Tag != "" {
 		i = ctx.GetTagIndex(u.Tag)
+		if _, ok := ctx.ParamNames[i]; !ok {
+			ctx.NameParam(i, u.Tag)
+		}
 	} else {
 		i = ctx.NextIndex()
 	}
@@ -273,18 +312,62 @@ func Binary(left Exp, op string, right Exp) *BinaryExp {
 }
 
 func (b BinaryExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	if _, err = binaryType(b.Op, TypeOf(b.LeftExp), TypeOf(b.RightExp)); err != nil {
+		return
+	} else if ctx.Pretty {
+		return b.pretty(ctx, buf)
+	}
 	buf.WriteByte('(')
+	from := ctx.Index()
 	if err = b.LeftExp.ToSQL(ctx, buf); err != nil {
 		return
 	}
+	nameParam(ctx, b.RightExp, b.LeftExp, from)
 	buf.WriteString(b.Op)
+	from = ctx.Index()
 	if err = b.RightExp.ToSQL(ctx, buf); err != nil {
 		return
 	}
+	nameParam(ctx, b.LeftExp, b.RightExp, from)
 	buf.WriteByte(')')
 	return
 }
 
+// Names the placeholders val was just rendered to after col, if col is a
+// column; from is the last index handed out before val. Looks through
+// arrays, groups, casts and function calls, e.g. col = ANY($1) or
+// col IN ($1,$2), but gives up on anything else (e.g. a subquery) since its
+// placeholders may be bound to other columns.
+func nameParam(ctx *query.SQLContext, col Exp, val Exp, from int) {
+	c, ok := col.(*ColumnExp)
+	if !ok {
+		return
+	}
+	plain := true
+	var tags []string
+	Walk(val, func(e Exp) bool {
+		switch e := e.(type) {
+		case *UnbindExp:
+			if e.Tag != "" {
+				tags = append(tags, e.Tag)
+			}
+		case *LiteralExp, *ArrayExp, *GroupExp, *CastExp, *FuncExp:
+		default:
+			plain = false
+		}
+		return plain
+	})
+	if !plain {
+		return
+	}
+	for _, tag := range tags {
+		ctx.NameParam(ctx.TagMap[tag], c.Name)
+	}
+	for i := from + 1; i <= ctx.Index(); i++ {
+		ctx.NameParam(i, c.Name)
+	}
+}
+
 type UnaryExp struct {
 	BaseExp
 	SubExp Exp
@@ -313,7 +396,13 @@ func Not(exp Exp) *UnaryExp {
 }
 
 func (u UnaryExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	if err = u.check(); err != nil {
+		return
+	} else if ctx.Pretty {
+		return u.pretty(ctx, buf)
+	}
 	if u.pre {
+		separate(buf, u.Op)
 		buf.WriteString(u.Op)
 	}
 	if err = u.SubExp.ToSQL(ctx, buf); err != nil {
@@ -338,7 +427,7 @@ func Cond(op string, exps ... Exp) *CondExp {
 }
 
 func (c *CondExp) And(exps ... Exp) *CondExp {
-	var tmp = make([]Exp, len(exps) + 1)
+	var tmp = make([]Exp, 0, len(exps) + 1)
 	tmp = append(tmp, c)
 	tmp = append(tmp, exps...)
 	return And(tmp...)
@@ -353,6 +442,14 @@ func Or(exps ... Exp) *CondExp {
 }
 
 func (c CondExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	for _, exp := range c.Exps {
+		if err = checkBoolean(c.Op, exp); err != nil {
+			return
+		}
+	}
+	if ctx.Pretty && len(c.Exps) > 0 {
+		return c.pretty(ctx, buf, true)
+	}
 	buf.WriteByte('(')
 	for i, exp := range c.Exps {
 		if i > 0 {
@@ -3,7 +3,12 @@ package exp
 import (
 	"testing"
 	"bytes"
+	"database/sql"
+	"math"
+	"time"
 	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/dbtype"
+	"github.com/tsealex/dbutil/null"
 )
 
 func TestLiteral(t *testing.T) {
@@ -29,3 +34,59 @@ func TestLiteral(t *testing.T) {
 
 }
 
+
+func TestWriteLiteral(t *testing.T) {
+	ts := time.Date(2018, 3, 4, 5, 6, 7, 8000, time.FixedZone("", -5*3600))
+	cases := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, `NULL`},
+		{(*int)(nil), `NULL`},
+		{[]byte(nil), `NULL`},
+		{"it's", `'it''s'`},
+		{`C:\dir's`, `E'C:\\dir''s'`},
+		{[]byte{0xde, 0xad}, `E'\\xdead'::bytea`},
+		{ts, `'2018-03-04T05:06:07.000008-05:00'::timestamptz`},
+		{int8(-3), `-3`},
+		{uint16(3), `3`},
+		{float32(0.5), `0.5`},
+		{math.Inf(-1), `'-Infinity'::float8`},
+		{true, `true`},
+		{[]string{"a", "b'"}, `ARRAY['a','b''']`},
+		{[]int{}, `'{}'`},
+		{sql.NullString{String: "x", Valid: true}, `'x'`},
+		{sql.NullInt64{}, `NULL`},
+		{dbtype.Point{Lat: 1, Long: 2}, `'SRID=4326;POINT(2 1)'`},
+		{dbtype.Jsonb{"a": "it's"}, `'{"a":"it''s"}'`},
+		{dbtype.JsonbValue{V: []int{1}}, `'[1]'`},
+		{null.New(dbtype.JsonbValue{V: "x"}), `'"x"'`},
+		{null.New([]byte{0xde, 0xad}), `E'\\xdead'::bytea`},
+		{null.Bytes{}, `NULL`},
+	}
+	for _, c := range cases {
+		b := bytes.Buffer{}
+		assert.NoError(t, WriteLiteral(nil, &b, c.value))
+		assert.Equal(t, c.want, b.String())
+	}
+	assert.Error(t, WriteLiteral(nil, &bytes.Buffer{}, "a\x00"))
+	assert.Error(t, WriteLiteral(nil, &bytes.Buffer{}, struct{}{}))
+
+	b := bytes.Buffer{}
+	assert.NoError(t, Array(1, "it's", Column("c")).ToSQL(nil, &b))
+	assert.Equal(t, `ARRAY[1,'it''s',c]`, b.String())
+}
+
+func TestBaseExp_Expo(t *testing.T) {
+	assert.Equal(t, `(a^2)`, toSQL(t, Column("a").Expo(Literal(2))))
+	assert.Equal(t, `(a%2)`, toSQL(t, Column("a").Mod(Literal(2))))
+	assert.Equal(t, Double, TypeOf(Column("a").SetType(Integer).Expo(Literal(2))))
+}
+
+func TestAgo(t *testing.T) {
+	e := Column("created_at").SetType(TimestampTz).Gte(Ago(Unbind()))
+	assert.Equal(t, `(created_at>=(now()-($1)::interval))`, toSQL(t, e))
+	assert.Equal(t, TimestampTz, TypeOf(FromNow(TaggedUnbind("ttl"))))
+	assert.Equal(t, `(now()+('P1DT2H')::interval)`,
+		toSQL(t, FromNow(Literal(dbtype.Interval{Days: 1, Microseconds: 2 * 3600e6}))))
+}
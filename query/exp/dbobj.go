@@ -10,6 +10,7 @@ type ColumnExp struct {
 	Name     string
 	Relation *RelationExp
 	Quoted   bool
+	Type     *Type // Optional
 }
 
 func Column(name string) *ColumnExp {
@@ -29,6 +30,13 @@ func (c *ColumnExp) SetRelation(r *RelationExp) *ColumnExp {
 	return c
 }
 
+// Declares the SQL type of c, which operators it is used with are checked
+// against.
+func (c *ColumnExp) SetType(t *Type) *ColumnExp {
+	c.Type = t
+	return c
+}
+
 func (c *ColumnExp) Quote() *ColumnExp {
 	c.Quoted = true
 	return c
@@ -49,11 +57,11 @@ func (c ColumnExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
 		}
 		buf.WriteByte('.')
 	}
-	if c.Quoted {
-		name = `"` + name + `""`
+	if name == "*" {
+		buf.WriteString(name)
+		return
 	}
-	buf.WriteString(name)
-	return
+	return writeIdent(ctx, buf, name, c.Quoted)
 }
 
 type RelationExp struct {
@@ -88,17 +96,13 @@ func (r RelationExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error)
 	name := r.Name
 	// Consult the context to see whether schema name is required here. Note
 	// that, in some portion of a query, schema names are not required.
-	if ctx.ReqSchema && r.Schema != nil {
+	if ctx != nil && ctx.ReqSchema && r.Schema != nil {
 		if err = r.Schema.ToSQL(ctx, buf); err != nil {
 			return
 		}
 		buf.WriteByte('.')
 	}
-	if r.Quoted {
-		name = `"` + name + `""`
-	}
-	buf.WriteString(name)
-	return
+	return writeIdent(ctx, buf, name, r.Quoted)
 }
 
 type SchemaExp struct {
@@ -124,12 +128,7 @@ func (s *SchemaExp) Unquote() *SchemaExp {
 }
 
 func (s SchemaExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
-	name := s.Name
-	if s.Quoted {
-		name = `"` + name + `""`
-	}
-	buf.WriteString(name)
-	return
+	return writeIdent(ctx, buf, s.Name, s.Quoted)
 }
 
 type AssignExp struct {
@@ -150,13 +149,60 @@ func (a AssignExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
 			return
 		}
 	}
-	if ctx.WriteStatus != query.Regular {
-		buf.WriteString("=")
+	if ctx.WriteStatus == query.Regular {
+		if ctx.Pretty {
+			buf.WriteString(" = ")
+		} else {
+			buf.WriteString("=")
+		}
 	}
 	if ctx.WriteStatus != query.ColumnOnly {
+		from := ctx.Index()
 		if err = a.RightExp.ToSQL(ctx, buf); err != nil {
 			return
 		}
+		nameParam(ctx, a.Col, a.RightExp, from)
+	}
+	return
+}
+
+// Join kinds.
+const (
+	InnerJoin = "JOIN"
+	LeftJoin  = "LEFT JOIN"
+	RightJoin = "RIGHT JOIN"
+	FullJoin  = "FULL JOIN"
+	CrossJoin = "CROSS JOIN"
+)
+
+type JoinExp struct {
+	BaseExp
+	Left  Exp
+	Kind  string
+	Right Exp
+	On    Exp // May be nil, e.g. for cross joins.
+}
+
+func Join(left Exp, kind string, right Exp, on Exp) *JoinExp {
+	res := &JoinExp{Left: left, Kind: kind, Right: right, On: on}
+	res.Exp = res
+	return res
+}
+
+func (j JoinExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	if err = j.Left.ToSQL(ctx, buf); err != nil {
+		return
+	}
+	// Joins start lines of their own.
+	ctx.Newline(buf, " ")
+	buf.WriteString(j.Kind)
+	buf.WriteByte(' ')
+	if err = j.Right.ToSQL(ctx, buf); err != nil {
+		return
+	}
+	if j.On != nil {
+		buf.WriteString(" ON ")
+		err = j.On.ToSQL(ctx, buf)
 	}
 	return
 }
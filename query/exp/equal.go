@@ -0,0 +1,139 @@
+package exp
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"reflect"
+	"github.com/tsealex/dbutil/query"
+)
+
+// Implemented by expressions of other packages which are not Nodes, so that
+// they can take part in fingerprints.
+type Fingerprinter interface {
+	Fingerprint() uint64
+}
+
+// Whether a and b are the same tree: nodes of the same types, with the same
+// attributes and values. Untagged placeholders are all equal.
+func Equal(a, b Exp) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	na, ok := a.(Node)
+	if !ok {
+		return reflect.DeepEqual(a, b)
+	}
+	if label(a) != label(b) {
+		return false
+	}
+	switch n := a.(type) {
+	case *LiteralExp:
+		if !reflect.DeepEqual(n.Value, b.(*LiteralExp).Value) {
+			return false
+		}
+	case *ArrayExp:
+		m := b.(*ArrayExp)
+		if len(n.Values) != len(m.Values) {
+			return false
+		}
+		for i, val := range n.Values {
+			if _, ok := val.(Exp); !ok && !reflect.DeepEqual(val, m.Values[i]) {
+				return false
+			}
+		}
+	case *UnbindExp:
+		if n.Tag != b.(*UnbindExp).Tag {
+			return false
+		}
+	}
+	ca, cb := na.Children(), b.(Node).Children()
+	if len(ca) != len(cb) {
+		return false
+	}
+	for i := range ca {
+		if !Equal(ca[i], cb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns a hash of the shape of e, which ignores the values of literals and
+// placeholders: expressions differing only in those have the same
+// fingerprint, like pg_stat_statements' queryid. Arrays of literals have the
+// same fingerprint whatever their length.
+func Fingerprint(e Exp) uint64 {
+	h := fnv.New64a()
+	writeShape(h, e)
+	return h.Sum64()
+}
+
+func writeShape(w io.Writer, e Exp) {
+	if e == nil {
+		io.WriteString(w, "nil\x00")
+		return
+	}
+	n, ok := e.(Node)
+	if !ok {
+		if f, ok := e.(Fingerprinter); ok {
+			fmt.Fprintf(w, "%T\x00%d\x00", e, f.Fingerprint())
+			return
+		}
+		// Nothing is known about it but the SQL it renders to.
+		buf := &bytes.Buffer{}
+		e.ToSQL(query.NewSQLContext(), buf)
+		fmt.Fprintf(w, "%T\x00%s\x00", e, buf.Bytes())
+		return
+	}
+	children := n.Children()
+	fmt.Fprintf(w, "%s\x00%d\x00", label(e), len(children))
+	for _, child := range children {
+		writeShape(w, child)
+	}
+}
+
+// Returns the attributes of the node e, except for its children and the
+// values of literals and placeholders.
+func label(e Exp) string {
+	switch n := e.(type) {
+	case *LiteralExp:
+		if n.isExp {
+			return fmt.Sprintf("raw %v", n.Value)
+		}
+		return "?"
+	case *UnbindExp:
+		return "?"
+	case *ArrayExp:
+		return "array"
+	case *GroupExp:
+		return "group"
+	case *BinaryExp:
+		return "binary " + n.Op
+	case *UnaryExp:
+		return fmt.Sprintf("unary %s %t", n.Op, n.pre)
+	case *CondExp:
+		return "cond " + n.Op
+	case *FuncExp:
+		return "func " + n.Name
+	case *CastExp:
+		return "cast " + n.Type
+	case *AliasExp:
+		return "alias " + n.Name
+	case *ColumnExp:
+		return fmt.Sprintf("column %s %t", n.Name, n.Quoted)
+	case *RelationExp:
+		return fmt.Sprintf("relation %s %t", n.Name, n.Quoted)
+	case *SchemaExp:
+		return fmt.Sprintf("schema %s %t", n.Name, n.Quoted)
+	case *AssignExp:
+		return "assign"
+	case *JoinExp:
+		return "join " + n.Kind
+	}
+	return fmt.Sprintf("%T", e)
+}
@@ -0,0 +1,46 @@
+package exp
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqual(t *testing.T) {
+	build := func(v interface{}, tag string) Exp {
+		return And(Column("a").SetRelation(Relation("t")).Eq(Literal(v)),
+			Func("f", Array(1, Column("b")), TaggedUnbind(tag)), Not(Expression("c")))
+	}
+	assert.True(t, Equal(build(1, "x"), build(1, "x")))
+	assert.False(t, Equal(build(1, "x"), build(2, "x")))
+	assert.False(t, Equal(build(1, "x"), build(int64(1), "x")))
+	assert.False(t, Equal(build(1, "x"), build(1, "y")))
+	assert.False(t, Equal(Column("a"), Column("a").Quote()))
+	assert.False(t, Equal(Column("a"), Relation("a")))
+	assert.False(t, Equal(Array(1, 2), Array(1, 3)))
+	assert.False(t, Equal(Or(Column("a")), And(Column("a"))))
+	assert.True(t, Equal(Unbind(), Unbind()))
+	assert.True(t, Equal(nil, nil))
+	assert.False(t, Equal(Column("a"), nil))
+
+	// Duplicates with the same tag are removed when simplifying.
+	assert.Equal(t, `(a=$1)`, toSQL(t, Simplify(And(
+		Column("a").Eq(TaggedUnbind("x")), Column("a").Eq(TaggedUnbind("x"))))))
+}
+
+func TestFingerprint(t *testing.T) {
+	shape := func(v interface{}, vals ... interface{}) Exp {
+		return And(Column("a").Eq(Literal(v)), Column("b").Eq(Unbind()),
+			Column("c").Eq(Func("any", Array(vals...))))
+	}
+	f := Fingerprint(shape(1, 1, 2))
+	assert.Equal(t, f, Fingerprint(shape("x", 3)))
+	assert.Equal(t, f, Fingerprint(And(Column("a").Eq(TaggedUnbind("a")),
+		Column("b").Eq(Literal(nil)), Column("c").Eq(Func("any", Array())))))
+	assert.NotEqual(t, f, Fingerprint(Or(Column("a").Eq(Literal(1)),
+		Column("b").Eq(Unbind()), Column("c").Eq(Func("any", Array())))))
+	assert.NotEqual(t, Fingerprint(Column("a").Eq(Literal(1))),
+		Fingerprint(Column("b").Eq(Literal(1))))
+	assert.NotEqual(t, Fingerprint(Expression("a = 1")), Fingerprint(Expression("a = 2")))
+	assert.NotEqual(t, Fingerprint(Func("f", Column("a"), Column("b"))),
+		Fingerprint(Func("f", Func("a", Column("b")))))
+}
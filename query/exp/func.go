@@ -12,7 +12,6 @@ type FuncExp struct {
 }
 
 func Func(name string, exps ... Exp) *FuncExp {
-	// TODO: type check
 	res := &FuncExp{Name: name, Args: exps}
 	res.Exp = res
 	return res
@@ -23,7 +22,7 @@ func (f FuncExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
 	buf.WriteByte('(')
 	for i, arg := range f.Args {
 		if i > 0 {
-			buf.WriteByte(',')
+			buf.WriteString(ctx.Comma())
 		}
 		if err = arg.ToSQL(ctx, buf); err != nil {
 			return
@@ -33,6 +32,22 @@ func (f FuncExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
 	return
 }
 
+// now(), the start time of the current transaction.
+func Now() *FuncExp {
+	return Func("now")
+}
+
+// Returns now() - interval, e.g. Ago(Unbind()) with a dbtype.Interval bound
+// to the placeholder, which is cast to an interval.
+func Ago(interval Exp) *BinaryExp {
+	return Now().Sub(Cast("interval", interval))
+}
+
+// Returns now() + interval.
+func FromNow(interval Exp) *BinaryExp {
+	return Now().Add(Cast("interval", interval))
+}
+
 type CastExp struct {
 	BaseExp
 	Type   string
@@ -40,13 +55,20 @@ type CastExp struct {
 }
 
 func Cast(typeName string, exp Exp) *CastExp {
-	// TODO: type check
 	res := &CastExp{Type: typeName, SubExp: exp}
 	res.Exp = res
 	return res
 }
 
 func (c CastExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	if ctx.Pretty && castable(ctx, c.SubExp) {
+		if err = c.SubExp.ToSQL(ctx, buf); err != nil {
+			return
+		}
+		buf.WriteString("::")
+		buf.WriteString(c.Type)
+		return
+	}
 	buf.WriteByte('(')
 	if err = c.SubExp.ToSQL(ctx, buf); err != nil {
 		return
@@ -63,18 +85,35 @@ type AliasExp struct {
 }
 
 func As(name string, exp Exp) *AliasExp {
-	// TODO: type check
 	res := &AliasExp{Name: name, SubExp: exp}
 	res.Exp = res
 	return res
 }
 
 func (a AliasExp) ToSQL(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	// Relations must not be parenthesized, e.g. in FROM clauses. Nor need
+	// other expressions be in pretty output, since AS binds looser than any
+	// operator.
+	var bare bool
+	switch a.SubExp.(type) {
+	case *RelationExp, *ColumnExp:
+		bare = true
+	case *JoinExp:
+		bare = false
+	default:
+		bare = ctx.Pretty
+	}
+	if bare {
+		if err = a.SubExp.ToSQL(ctx, buf); err != nil {
+			return
+		}
+		buf.WriteString(" AS ")
+		return writeIdent(ctx, buf, a.Name, false)
+	}
 	buf.WriteByte('(')
 	if err = a.SubExp.ToSQL(ctx, buf); err != nil {
 		return
 	}
 	buf.WriteString(") AS ")
-	buf.WriteString(a.Name)
-	return
+	return writeIdent(ctx, buf, a.Name, false)
 }
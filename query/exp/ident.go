@@ -0,0 +1,88 @@
+package exp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"github.com/tsealex/dbutil/query"
+)
+
+// Keywords that cannot be used as unquoted column or table names in Postgres,
+// i.e. those marked as reserved, or as usable as function or type names only.
+// https://www.postgresql.org/docs/current/sql-keywords-appendix.html
+var reserved = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		all analyse analyze and any array as asc asymmetric authorization binary
+		both case cast check collate collation column concurrently constraint
+		create cross current_catalog current_date current_role current_schema
+		current_time current_timestamp current_user default deferrable desc
+		distinct do else end except false fetch for foreign freeze from full
+		grant group having ilike in initially inner intersect into is isnull
+		join lateral leading left like limit localtime localtimestamp natural
+		not notnull null offset on only or order outer overlaps placing primary
+		references returning right select session_user similar some symmetric
+		system_user table tablesample then to trailing true union unique user
+		using variadic verbose when where window with`) {
+		reserved[word] = true
+	}
+}
+
+func IsReserved(name string) bool {
+	return reserved[strings.ToLower(name)]
+}
+
+// Returns whether name must be quoted to be read back as is, i.e. whether it
+// is a reserved word, or contains anything but lowercase ASCII letters,
+// underscores, digits and dollar signs (the latter two not leading).
+func NeedsQuote(name string) bool {
+	if name == "" || IsReserved(name) {
+		return true
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c == '_':
+		case c >= '0' && c <= '9', c == '$':
+			if i == 0 {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// Returns name surrounded with the quote character of d, doubling the
+// embedded ones.
+func QuoteIdent(name string, d *query.Dialect) string {
+	q := string(d.IdentQuote)
+	return q + strings.Replace(name, q, q+q, -1) + q
+}
+
+func ValidateIdent(name string, d *query.Dialect) error {
+	if name == "" {
+		return fmt.Errorf("identifier must not be empty")
+	} else if strings.IndexByte(name, 0) >= 0 {
+		return fmt.Errorf("identifier %q contains a NUL character", name)
+	} else if d.MaxIdentLen > 0 && len(name) > d.MaxIdentLen {
+		return fmt.Errorf("identifier %q is longer than %d bytes", name,
+			d.MaxIdentLen)
+	}
+	return nil
+}
+
+// Writes name as an identifier, quoting it if forced to or if it needs to be.
+func writeIdent(ctx *query.SQLContext, buf *bytes.Buffer, name string, force bool) error {
+	d := ctx.GetDialect()
+	if err := ValidateIdent(name, d); err != nil {
+		return err
+	}
+	if force || NeedsQuote(name) {
+		name = QuoteIdent(name, d)
+	}
+	buf.WriteString(name)
+	return nil
+}
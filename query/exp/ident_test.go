@@ -0,0 +1,98 @@
+package exp
+
+import (
+	"testing"
+	"bytes"
+	"fmt"
+	"strings"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/query"
+)
+
+func TestColumn_Quote(t *testing.T) {
+	cases := []struct {
+		col  *ColumnExp
+		want string
+	}{
+		{Column("name"), `name`},
+		{Column("name").Quote(), `"name"`},
+		{Column("Name"), `"Name"`},
+		{Column("user"), `"user"`},
+		{Column("first name"), `"first name"`},
+		{Column(`say "hi"`), `"say ""hi"""`},
+		{Column("1st"), `"1st"`},
+		{Column("a$1"), `a$1`},
+		{Column("*").SetRelation(Relation("Users")), `"Users".*`},
+	}
+	for _, c := range cases {
+		b := bytes.Buffer{}
+		assert.NoError(t, c.col.ToSQL(nil, &b))
+		assert.Equal(t, c.want, b.String())
+	}
+
+	ctx := query.NewSQLContext()
+	ctx.Dialect = query.MySQL
+	ctx.ReqSchema = true
+	b := bytes.Buffer{}
+	assert.NoError(t, Column("a`b").SetRelation(
+		Relation("t").SetSchema(Schema("Main"))).ToSQL(ctx, &b))
+	assert.Equal(t, "`Main`.t.`a``b`", b.String())
+
+	assert.Error(t, Column("").ToSQL(nil, &bytes.Buffer{}))
+	assert.Error(t, Relation("a\x00").ToSQL(nil, &bytes.Buffer{}))
+	assert.Error(t, Column(strings.Repeat("a", 64)).ToSQL(nil, &bytes.Buffer{}))
+}
+
+// Reads back an identifier the way the Postgres lexer does: quoted ones are
+// taken verbatim with doubled quotes collapsed, unquoted ones are folded to
+// lowercase and must not be reserved keywords.
+func lexIdent(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		var res []byte
+		for i := 1; i < len(s); i++ {
+			if s[i] != '"' {
+				res = append(res, s[i])
+			} else if i+1 < len(s) && s[i+1] == '"' {
+				res = append(res, '"')
+				i++
+			} else if i+1 == len(s) {
+				return string(res), nil
+			} else {
+				return "", fmt.Errorf("trailing input after %q", s[:i+1])
+			}
+		}
+		return "", fmt.Errorf("unterminated quoted identifier %q", s)
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		isLetter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+		if !isLetter && (i == 0 || !(c >= '0' && c <= '9' || c == '$')) {
+			return "", fmt.Errorf("unexpected %q in %q", c, s)
+		}
+	}
+	if s == "" || IsReserved(s) {
+		return "", fmt.Errorf("%q is not an identifier", s)
+	}
+	return strings.ToLower(s), nil
+}
+
+func FuzzColumn_ToSQL(f *testing.F) {
+	for _, seed := range []string{"a", "A", "select", `"`, `a""b`, "a b", "_1",
+		"$", "ü", "a.b"} {
+		f.Add(seed, false)
+	}
+	f.Fuzz(func(t *testing.T, name string, quoted bool) {
+		col := Column(name)
+		col.Quoted = quoted
+		b := bytes.Buffer{}
+		if err := col.ToSQL(nil, &b); err != nil {
+			assert.Error(t, ValidateIdent(name, query.Postgres))
+			return
+		} else if name == "*" {
+			return
+		}
+		res, err := lexIdent(b.String())
+		assert.NoError(t, err)
+		assert.Equal(t, name, res)
+	})
+}
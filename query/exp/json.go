@@ -0,0 +1,386 @@
+package exp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Expressions are encoded as objects with a type discriminator, and their
+// subexpressions as args, e.g. a=1 is
+//   {"type":"binary","op":"=","args":[{"type":"column","name":"a"},
+//     {"type":"literal","value":1}]}
+type jsonExp struct {
+	Type    string          `json:"type"`
+	Name    string          `json:"name,omitempty"` // Also the type of casts.
+	Op      string          `json:"op,omitempty"`   // Also the kind of joins.
+	Postfix bool            `json:"postfix,omitempty"`
+	Quoted  bool            `json:"quoted,omitempty"`
+	Tag     string          `json:"tag,omitempty"`
+	SQLType string          `json:"sql_type,omitempty"` // Of columns.
+	Value   json.RawMessage `json:"value,omitempty"`
+	Args    []*jsonExp      `json:"args,omitempty"`
+}
+
+// Encodes e as JSON, to be decoded by a JSONDecoder. Expressions from other
+// packages cannot be encoded, and literal values are encoded with
+// encoding/json, so only their JSON counterparts are decoded back: integers
+// come back as int64, other numbers as float64.
+func EncodeJSON(e Exp) ([]byte, error) {
+	j, err := toJSON(e)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err = enc.Encode(j); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// Lets nodes be given to json.Marshal as is.
+func (b *BaseExp) MarshalJSON() ([]byte, error) {
+	if b.Exp == nil {
+		return nil, fmt.Errorf("cannot encode incomplete expression")
+	}
+	return EncodeJSON(b.Exp)
+}
+
+func toJSON(e Exp) (res *jsonExp, err error) {
+	if e == nil {
+		return nil, fmt.Errorf("cannot encode nil expression")
+	}
+	res = &jsonExp{}
+	var children []Exp
+	if n, ok := e.(Node); ok {
+		children = n.Children()
+	}
+	switch n := e.(type) {
+	case *ArrayExp:
+		res.Type = "array"
+		children = nil
+		for _, val := range n.Values {
+			sub, ok := val.(Exp)
+			if !ok {
+				sub = Literal(val)
+			}
+			children = append(children, sub)
+		}
+	case *LiteralExp:
+		if res.Type = "literal"; n.isExp {
+			res.Type = "raw"
+		}
+		if res.Value, err = json.Marshal(n.Value); err != nil {
+			return
+		}
+	case *UnbindExp:
+		res.Type, res.Tag = "param", n.Tag
+	case *GroupExp:
+		res.Type = "group"
+	case *BinaryExp:
+		res.Type, res.Op = "binary", n.Op
+	case *UnaryExp:
+		res.Type, res.Op, res.Postfix = "unary", n.Op, !n.pre
+	case *CondExp:
+		res.Type, res.Op = "cond", n.Op
+	case *FuncExp:
+		res.Type, res.Name = "func", n.Name
+	case *CastExp:
+		res.Type, res.Name = "cast", n.Type
+	case *AliasExp:
+		res.Type, res.Name = "alias", n.Name
+	case *ColumnExp:
+		res.Type, res.Name, res.Quoted = "column", n.Name, n.Quoted
+		if n.Type != nil {
+			res.SQLType = n.Type.Name
+		}
+	case *RelationExp:
+		res.Type, res.Name, res.Quoted = "relation", n.Name, n.Quoted
+	case *SchemaExp:
+		res.Type, res.Name, res.Quoted = "schema", n.Name, n.Quoted
+	case *AssignExp:
+		res.Type = "assign"
+	case *JoinExp:
+		res.Type, res.Op = "join", n.Kind
+	default:
+		return nil, fmt.Errorf("cannot encode %T", e)
+	}
+	for _, child := range children {
+		sub, err := toJSON(child)
+		if err != nil {
+			return nil, err
+		}
+		res.Args = append(res.Args, sub)
+	}
+	return
+}
+
+// Operators allowed by default, upper-case and without surrounding spaces.
+var DefaultOps = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "%": true, "^": true,
+	"=": true, "<>": true, "<": true, ">": true, "<=": true, ">=": true,
+	"<<": true, ">>": true, "&": true, "|": true, "||": true,
+	"&&": true, "@>": true, "<@": true, "-|-": true, "&<": true, "&>": true,
+	"~": true, "~*": true, "!~": true, "!~*": true,
+	"~~": true, "~~*": true, "!~~": true, "!~~*": true,
+	"IS": true, "IS NOT": true, "SIMILAR TO": true,
+	"AND": true, "OR": true, "NOT": true,
+}
+
+// Functions allowed by default, lower-case.
+var DefaultFuncs = map[string]bool{
+	"lower": true, "upper": true, "length": true, "trim": true, "abs": true,
+	"round": true, "coalesce": true, "now": true, "date_trunc": true,
+	"any": true, "all": true,
+}
+
+// Cast types allowed by default, lower-case.
+var DefaultTypes = map[string]bool{
+	"boolean": true, "smallint": true, "integer": true, "int": true, "bigint": true,
+	"real": true, "double precision": true, "numeric": true, "text": true,
+	"date": true, "timestamp": true, "timestamptz": true, "interval": true,
+	"uuid": true, "jsonb": true, "text[]": true, "integer[]": true, "bigint[]": true,
+}
+
+var joinKinds = map[string]bool{
+	InnerJoin: true, LeftJoin: true, RightJoin: true, FullJoin: true, CrossJoin: true,
+}
+
+// Nesting deeper than that is refused.
+const maxJSONDepth = 100
+
+// JSONDecoder decodes expressions encoded with EncodeJSON, which may come
+// from untrusted clients: since operators, function names and cast types are
+// written to queries as is, only the allowed ones are accepted, and raw SQL is
+// refused unless AllowRaw is set (but for "*"). Identifiers and literals are
+// always escaped.
+type JSONDecoder struct {
+	Ops      map[string]bool // DefaultOps if nil.
+	Funcs    map[string]bool // DefaultFuncs if nil.
+	Types    map[string]bool // DefaultTypes if nil.
+	AllowRaw bool
+}
+
+// Decodes with the default allow-lists, and no raw SQL.
+func DecodeJSON(data []byte) (Exp, error) {
+	return (&JSONDecoder{}).Decode(data)
+}
+
+func (d *JSONDecoder) Decode(data []byte) (Exp, error) {
+	var j jsonExp
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	return d.fromJSON(&j, 0)
+}
+
+func (d *JSONDecoder) fromJSON(j *jsonExp, depth int) (res Exp, err error) {
+	if j == nil {
+		return nil, fmt.Errorf("missing expression")
+	}
+	if depth > maxJSONDepth {
+		return nil, fmt.Errorf("expression nested too deeply")
+	}
+	args := make([]Exp, len(j.Args))
+	for i, arg := range j.Args {
+		if args[i], err = d.fromJSON(arg, depth+1); err != nil {
+			return
+		}
+	}
+	arity := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s takes %d args, got %d", j.Type, n, len(args))
+		}
+		return nil
+	}
+	switch j.Type {
+	case "literal":
+		var val interface{}
+		if val, err = decodeValue(j.Value); err == nil {
+			res, err = Literal(val), arity(0)
+		}
+	case "raw":
+		var raw string
+		if err = json.Unmarshal(j.Value, &raw); err != nil {
+			return
+		}
+		if !d.AllowRaw && raw != "*" {
+			return nil, fmt.Errorf("raw SQL is not allowed")
+		}
+		res, err = Expression(raw), arity(0)
+	case "array":
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			// Values were wrapped into literals.
+			if l, ok := arg.(*LiteralExp); ok && !l.isExp {
+				values[i] = l.Value
+			} else {
+				values[i] = arg
+			}
+		}
+		res = Array(values...)
+	case "param":
+		if res, err = Unbind(), arity(0); j.Tag != "" {
+			res = TaggedUnbind(j.Tag)
+		}
+	case "group":
+		if err = arity(1); err == nil {
+			res = Group(args[0])
+		}
+	case "binary":
+		var op string
+		if op, err = d.op(j.Op, true); err == nil {
+			if err = arity(2); err == nil {
+				res = Binary(args[0], op, args[1])
+			}
+		}
+	case "unary":
+		var op string
+		if op, err = d.op(j.Op, !j.Postfix); err == nil {
+			if err = arity(1); err == nil {
+				if res = LeftUnary(op, args[0]); j.Postfix {
+					res = RightUnary(args[0], op)
+				}
+			}
+		}
+	case "cond":
+		var op string
+		if op, err = d.op(j.Op, true); err == nil {
+			res = Cond(op, args...)
+		}
+	case "func":
+		if !d.allowed(d.Funcs, DefaultFuncs, strings.ToLower(j.Name)) {
+			return nil, fmt.Errorf("function %q is not allowed", j.Name)
+		}
+		res = Func(j.Name, args...)
+	case "cast":
+		if !d.allowed(d.Types, DefaultTypes, strings.ToLower(j.Name)) {
+			return nil, fmt.Errorf("type %q is not allowed", j.Name)
+		}
+		if err = arity(1); err == nil {
+			res = Cast(j.Name, args[0])
+		}
+	case "alias":
+		if err = arity(1); err == nil {
+			res = As(j.Name, args[0])
+		}
+	case "column":
+		c := Column(j.Name)
+		c.Quoted = j.Quoted
+		if j.SQLType != "" {
+			c.Type = ParseType(j.SQLType)
+		}
+		if len(args) > 0 {
+			rel, ok := args[0].(*RelationExp)
+			if !ok {
+				return nil, fmt.Errorf("the relation of a column must be a relation")
+			}
+			c.Relation = rel
+		}
+		res, err = c, arity(len(c.Children()))
+	case "relation":
+		r := Relation(j.Name)
+		r.Quoted = j.Quoted
+		if len(args) > 0 {
+			s, ok := args[0].(*SchemaExp)
+			if !ok {
+				return nil, fmt.Errorf("the schema of a relation must be a schema")
+			}
+			r.Schema = s
+		}
+		res, err = r, arity(len(r.Children()))
+	case "schema":
+		s := Schema(j.Name)
+		s.Quoted = j.Quoted
+		res, err = s, arity(0)
+	case "assign":
+		if err = arity(2); err == nil {
+			res = Assign(args[0], args[1])
+		}
+	case "join":
+		if !joinKinds[j.Op] {
+			return nil, fmt.Errorf("unknown join kind %q", j.Op)
+		}
+		if len(args) == 2 {
+			res = Join(args[0], j.Op, args[1], nil)
+		} else if err = arity(3); err == nil {
+			res = Join(args[0], j.Op, args[1], args[2])
+		}
+	default:
+		return nil, fmt.Errorf("unknown expression type %q", j.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (d *JSONDecoder) allowed(list map[string]bool, def map[string]bool, name string) bool {
+	if list == nil {
+		list = def
+	}
+	return list[name]
+}
+
+// Checks that op is allowed, and returns it as listed, padded with spaces if
+// it is a keyword. Symbols keep a single space on the sides they had any
+// whitespace on; no other whitespace from the input makes it into the SQL.
+func (d *JSONDecoder) op(op string, prefix bool) (string, error) {
+	key := strings.ToUpper(strings.Join(strings.Fields(op), " "))
+	if !d.allowed(d.Ops, DefaultOps, key) {
+		return "", fmt.Errorf("operator %q is not allowed", op)
+	}
+	if strings.IndexFunc(key, func(c rune) bool { return c >= 'A' && c <= 'Z' }) >= 0 {
+		if prefix {
+			return " " + key + " ", nil
+		}
+		return " " + key, nil
+	}
+	res := key
+	if strings.TrimLeftFunc(op, unicode.IsSpace) != op {
+		res = " " + res
+	}
+	if strings.TrimRightFunc(op, unicode.IsSpace) != op {
+		res += " "
+	}
+	return res, nil
+}
+
+// Numbers are decoded as int64 when they can be, as float64 otherwise.
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var val interface{}
+	if err := dec.Decode(&val); err != nil {
+		return nil, err
+	}
+	return convertValue(val)
+}
+
+func convertValue(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case []interface{}:
+		for i, elem := range v {
+			var err error
+			if v[i], err = convertValue(elem); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case map[string]interface{}:
+		return nil, fmt.Errorf("unsupported literal value %s", "object")
+	}
+	return val, nil
+}
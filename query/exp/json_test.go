@@ -0,0 +1,89 @@
+package exp
+
+import (
+	"encoding/json"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSON(t *testing.T) {
+	u := Relation("users").SetSchema(Schema("app").Quote())
+	exps := []Exp{
+		And(Column("age").SetRelation(u).Gte(Literal(int64(18))),
+			Or(Column("name").ILike(Literal("a%")), Not(Column("banned"))),
+			Column("tags").Overlap(Array("a", Column("tag"), 2.5)),
+			Column("n").Is(Literal(nil)),
+			Cast("integer", Func("lower", Column("x"), TaggedUnbind("t"))).Eq(Minus(Unbind()))),
+		As("total", Group(Column("a").Add(Literal(int64(1) << 60)))),
+		RightUnary(Column("a"), " DESC"),
+		Column("a").Assign(Literal([]interface{}{int64(1), "x", true})),
+		Join(As("u", Relation("users")), LeftJoin, Relation("o"), Literal(true)),
+		Join(Relation("a"), CrossJoin, Relation("b"), nil),
+		All,
+	}
+	dec := &JSONDecoder{Ops: map[string]bool{}}
+	for op := range DefaultOps {
+		dec.Ops[op] = true
+	}
+	dec.Ops["DESC"] = true
+	for _, e := range exps {
+		data, err := json.Marshal(e)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		res, err := dec.Decode(data)
+		if assert.NoError(t, err, string(data)) {
+			assert.True(t, Equal(e, res), string(data))
+			assert.Equal(t, toSQL(t, e), toSQL(t, res))
+		}
+	}
+
+	data, err := EncodeJSON(Column("a").SetRelation(Relation("t")).Eq(Literal(1)))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"binary","op":"=","args":[{"type":"column","name":"a",`+
+		`"args":[{"type":"relation","name":"t"}]},{"type":"literal","value":1}]}`, string(data))
+	_, err = EncodeJSON(Literal(make(chan int)))
+	assert.Error(t, err)
+	_, err = json.Marshal(&ColumnExp{Name: "a"})
+	assert.Error(t, err)
+}
+
+func TestJSONDecoder(t *testing.T) {
+	// Keyword operators are padded so that they cannot merge with operands,
+	// and whitespace is normalized.
+	e, err := DecodeJSON([]byte(`{"type":"binary","op":"is\tnot\n","args":[` +
+		`{"type":"column","name":"a"},{"type":"literal","value":null}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `(a IS NOT NULL)`, toSQL(t, e))
+	e, err = DecodeJSON([]byte(`{"type":"binary","op":"\u00a0=\r\n","args":[` +
+		`{"type":"column","name":"a"},{"type":"literal","value":1}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `(a = 1)`, toSQL(t, e))
+
+	for _, src := range []string{
+		`{"type":"raw","value":"1; DROP TABLE users"}`,
+		`{"type":"binary","op":"=1; --","args":[{"type":"literal","value":1},{"type":"literal","value":1}]}`,
+		`{"type":"unary","op":"DESC","postfix":true,"args":[{"type":"column","name":"a"}]}`,
+		`{"type":"func","name":"pg_sleep","args":[{"type":"literal","value":10}]}`,
+		`{"type":"cast","name":"int); DROP TABLE x; --","args":[{"type":"literal","value":1}]}`,
+		`{"type":"join","op":"NATURAL JOIN","args":[{"type":"relation","name":"a"},{"type":"relation","name":"b"}]}`,
+		`{"type":"binary","op":"=","args":[{"type":"literal","value":1}]}`,
+		`{"type":"column","name":"a","args":[{"type":"column","name":"b"}]}`,
+		`{"type":"literal","value":{"a":1}}`,
+		`{"type":"subquery"}`,
+		`{"type":"group","args":[null]}`,
+		`[]`,
+	} {
+		_, err := DecodeJSON([]byte(src))
+		assert.Error(t, err, src)
+	}
+
+	// Identifiers need no allow-list since they are quoted as needed.
+	e, err = DecodeJSON([]byte(`{"type":"column","name":"a\"; DROP TABLE x; --"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `"a""; DROP TABLE x; --"`, toSQL(t, e))
+
+	e, err = (&JSONDecoder{AllowRaw: true}).Decode([]byte(`{"type":"raw","value":"a IS NULL"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `a IS NULL`, toSQL(t, e))
+}
@@ -0,0 +1,164 @@
+package exp
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/tsealex/dbutil/query"
+)
+
+// Layout of inlined timestamps: ISO 8601 with microseconds and time zone.
+const TimestampLayout = "2006-01-02T15:04:05.999999Z07:00"
+
+// Writes v as an SQL literal. Strings are escaped, driver.Valuer instances
+// (e.g. dbtype.Point or null.String) are unwrapped, and slices other than
+// []byte are written as arrays. Only []byte and nullable []byte (null.Bytes)
+// are bytea: the []byte driver values of other Valuers are text, e.g. the JSON
+// of dbtype.Jsonb, and are written as strings of no type.
+func WriteLiteral(ctx *query.SQLContext, buf *bytes.Buffer, v interface{}) error {
+	return writeLiteral(ctx, buf, v, 0)
+}
+
+// Valuers may return other Valuers; depth guards against ones returning
+// themselves.
+func writeLiteral(ctx *query.SQLContext, buf *bytes.Buffer, v interface{}, depth int) (err error) {
+	if isNil(v) {
+		buf.WriteString("NULL")
+		return
+	}
+	switch val := v.(type) {
+	case interface{ Ptr() *[]byte }:
+		if p := val.Ptr(); p != nil {
+			return writeLiteral(ctx, buf, *p, depth)
+		}
+		buf.WriteString("NULL")
+		return
+	case driver.Valuer:
+		if depth > 8 {
+			return fmt.Errorf("%T.Value does not yield a driver value", v)
+		}
+		var dv driver.Value
+		if dv, err = val.Value(); err != nil {
+			return
+		} else if data, ok := dv.([]byte); ok {
+			return writeString(buf, string(data))
+		}
+		return writeLiteral(ctx, buf, dv, depth+1)
+	case string:
+		return writeString(buf, val)
+	case []byte:
+		buf.WriteString(`E'\\x`)
+		buf.WriteString(hex.EncodeToString(val))
+		buf.WriteString(`'::bytea`)
+		return
+	case time.Time:
+		buf.WriteByte('\'')
+		buf.WriteString(val.Format(TimestampLayout))
+		buf.WriteString("'::timestamptz")
+		return
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+		return
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		str := strconv.FormatInt(rv.Int(), 10)
+		separate(buf, str)
+		buf.WriteString(str)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.WriteString(strconv.FormatUint(rv.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		writeFloat(buf, rv.Float(), rv.Type().Bits())
+	case reflect.Bool:
+		buf.WriteString(strconv.FormatBool(rv.Bool()))
+	case reflect.String:
+		return writeString(buf, rv.String())
+	case reflect.Ptr:
+		return writeLiteral(ctx, buf, rv.Elem().Interface(), depth)
+	case reflect.Slice, reflect.Array:
+		if rv.Len() == 0 {
+			// ARRAY[] would need an explicit type.
+			buf.WriteString("'{}'")
+			return
+		}
+		buf.WriteString("ARRAY[")
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err = writeLiteral(ctx, buf, rv.Index(i).Interface(), depth); err != nil {
+				return
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		return fmt.Errorf("unsupported literal type %T", v)
+	}
+	return
+}
+
+func writeFloat(buf *bytes.Buffer, f float64, bits int) {
+	typeName := "float8"
+	if bits == 32 {
+		typeName = "float4"
+	}
+	switch {
+	case math.IsNaN(f):
+		buf.WriteString("'NaN'::" + typeName)
+	case math.IsInf(f, 1):
+		buf.WriteString("'Infinity'::" + typeName)
+	case math.IsInf(f, -1):
+		buf.WriteString("'-Infinity'::" + typeName)
+	default:
+		str := strconv.FormatFloat(f, 'g', -1, bits)
+		separate(buf, str)
+		buf.WriteString(str)
+	}
+}
+
+// Writes a space if next would otherwise start a comment together with what
+// precedes it, as a minus sign right after a subtraction (a--1) would.
+func separate(buf *bytes.Buffer, next string) {
+	if n := buf.Len(); n > 0 && next != "" {
+		last := buf.Bytes()[n-1]
+		if last == '-' && next[0] == '-' || last == '/' && next[0] == '*' {
+			buf.WriteByte(' ')
+		}
+	}
+}
+
+// Quotes are doubled. Backslashes are only special in E'' strings, or in
+// regular ones when standard_conforming_strings is off, so strings containing
+// them are written as E'' strings with the backslashes doubled, which reads
+// the same regardless of the setting.
+func writeString(buf *bytes.Buffer, s string) error {
+	if strings.IndexByte(s, 0) >= 0 {
+		return fmt.Errorf("string literal %q contains a NUL character", s)
+	}
+	if strings.IndexByte(s, '\\') >= 0 {
+		buf.WriteByte('E')
+		s = strings.Replace(s, `\`, `\\`, -1)
+	}
+	buf.WriteByte('\'')
+	buf.WriteString(strings.Replace(s, `'`, `''`, -1))
+	buf.WriteByte('\'')
+	return nil
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Interface, reflect.Slice:
+		return rv.IsNil()
+	}
+	return false
+}
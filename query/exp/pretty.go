@@ -0,0 +1,191 @@
+package exp
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"github.com/tsealex/dbutil/query"
+)
+
+// Precedences of Postgres operators, from the loosest to the tightest. Pretty
+// output only parenthesizes operands which bind looser than their operator.
+const (
+	lowestPrec         = iota // e.g. ASC, or AS.
+	orPrec             = iota
+	andPrec            = iota
+	notPrec            = iota
+	isPrec             = iota
+	comparisonPrec     = iota
+	likePrec           = iota
+	otherPrec          = iota // Any other operator, e.g. || or @>.
+	additivePrec       = iota
+	multiplicativePrec = iota
+	expPrec            = iota
+	unaryPrec          = iota
+	atomPrec           = iota
+)
+
+// Returns the precedence of the binary operator op, and whether it is not
+// associative, i.e. cannot be chained without parentheses.
+func opPrec(op string) (prec int, nonAssoc bool) {
+	switch strings.ToUpper(strings.TrimSpace(op)) {
+	case "OR":
+		return orPrec, false
+	case "AND":
+		return andPrec, false
+	case "IS", "IS NOT", "IS DISTINCT FROM", "IS NOT DISTINCT FROM":
+		return isPrec, true
+	case "=", "<>", "!=", "<", ">", "<=", ">=":
+		return comparisonPrec, true
+	case "LIKE", "ILIKE", "NOT LIKE", "NOT ILIKE", "SIMILAR TO", "IN", "NOT IN":
+		return likePrec, true
+	case "+", "-":
+		return additivePrec, false
+	case "*", "/", "%":
+		return multiplicativePrec, false
+	case "^":
+		return expPrec, false
+	}
+	return otherPrec, false
+}
+
+// Postfix IS NULL and the like bind as IS does, while others, as ASC or
+// NULLS LAST, only follow whole expressions.
+func postfixPrec(op string) int {
+	op = strings.ToUpper(strings.TrimSpace(op))
+	if strings.HasPrefix(op, "IS") || op == "NOTNULL" {
+		return isPrec
+	}
+	return lowestPrec
+}
+
+// Returns how tightly e binds as an operand.
+func precOf(e Exp) int {
+	switch n := e.(type) {
+	case *BinaryExp:
+		prec, _ := opPrec(n.Op)
+		return prec
+	case *CondExp:
+		if len(n.Exps) == 1 {
+			return precOf(n.Exps[0])
+		}
+		prec, _ := opPrec(n.Op)
+		return prec
+	case *UnaryExp:
+		if !n.pre {
+			return postfixPrec(n.Op)
+		} else if isNot(n) {
+			return notPrec
+		}
+		return unaryPrec
+	case *AliasExp, *JoinExp:
+		return lowestPrec
+	}
+	return atomPrec
+}
+
+// Writes e as an operand of an operator of precedence prec, parenthesized if
+// it binds looser, or as tightly when tight is set, e.g. on the right of a
+// left-associative operator. Conditions in parentheses stay on one line.
+func writeOperand(ctx *query.SQLContext, buf *bytes.Buffer, e Exp, prec int, tight bool) (err error) {
+	for c, ok := e.(*CondExp); ok && len(c.Exps) == 1; c, ok = e.(*CondExp) {
+		e = c.Exps[0]
+	}
+	if p := precOf(e); p > prec || p == prec && !tight {
+		return e.ToSQL(ctx, buf)
+	}
+	buf.WriteByte('(')
+	if c, ok := e.(*CondExp); ok {
+		err = c.pretty(ctx, buf, false)
+	} else {
+		err = e.ToSQL(ctx, buf)
+	}
+	buf.WriteByte(')')
+	return
+}
+
+func (b BinaryExp) pretty(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	prec, nonAssoc := opPrec(b.Op)
+	from := ctx.Index()
+	if err = writeOperand(ctx, buf, b.LeftExp, prec, nonAssoc); err != nil {
+		return
+	}
+	nameParam(ctx, b.RightExp, b.LeftExp, from)
+	buf.WriteByte(' ')
+	buf.WriteString(strings.TrimSpace(b.Op))
+	buf.WriteByte(' ')
+	from = ctx.Index()
+	if err = writeOperand(ctx, buf, b.RightExp, prec, true); err != nil {
+		return
+	}
+	nameParam(ctx, b.LeftExp, b.RightExp, from)
+	return
+}
+
+func (u UnaryExp) pretty(ctx *query.SQLContext, buf *bytes.Buffer) (err error) {
+	if !u.pre {
+		prec := postfixPrec(u.Op)
+		if err = writeOperand(ctx, buf, u.SubExp, prec, prec != lowestPrec); err != nil {
+			return
+		}
+		buf.WriteString(u.Op)
+		return
+	}
+	prec := unaryPrec
+	if isNot(&u) {
+		prec = notPrec
+	}
+	op := strings.TrimSpace(u.Op)
+	separate(buf, op)
+	buf.WriteString(op)
+	// Keywords must not run into their operand.
+	if op != "" && unicode.IsLetter(rune(op[len(op)-1])) {
+		buf.WriteByte(' ')
+	}
+	return writeOperand(ctx, buf, u.SubExp, prec, false)
+}
+
+// Conditions of several operands which are not in parentheses have one
+// operand per line.
+func (c CondExp) pretty(ctx *query.SQLContext, buf *bytes.Buffer, lines bool) (err error) {
+	if len(c.Exps) == 1 {
+		return c.Exps[0].ToSQL(ctx, buf)
+	}
+	prec, _ := opPrec(c.Op)
+	op := strings.TrimSpace(c.Op)
+	if lines {
+		ctx.Nest()
+		defer ctx.Unnest()
+	}
+	for i, exp := range c.Exps {
+		if i > 0 {
+			if lines {
+				ctx.Newline(buf, " ")
+			} else {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(op)
+			buf.WriteByte(' ')
+		}
+		if err = writeOperand(ctx, buf, exp, prec, i > 0); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Whether e can be cast without parentheses around it.
+func castable(ctx *query.SQLContext, e Exp) bool {
+	switch n := e.(type) {
+	case *ColumnExp, *UnbindExp, *FuncExp, *ArrayExp, *CastExp, *GroupExp:
+		return true
+	case *LiteralExp:
+		if n.isExp {
+			return false
+		}
+		// Negative numbers are negations, which bind looser than casts.
+		tmp := bytes.Buffer{}
+		return n.ToSQL(ctx, &tmp) == nil && tmp.Len() > 0 && tmp.Bytes()[0] != '-'
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package exp
+
+import (
+	"bytes"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/query"
+)
+
+func toPrettySQL(t *testing.T, e Exp) string {
+	ctx := query.NewSQLContext()
+	ctx.Pretty = true
+	b := bytes.Buffer{}
+	assert.NoError(t, e.ToSQL(ctx, &b))
+	return b.String()
+}
+
+func TestPretty(t *testing.T) {
+	a, b, c := Column("a"), Column("b"), Column("c")
+	cases := []struct {
+		e    Exp
+		want string
+	}{
+		{a.Add(b.Mul(c)), `a + b * c`},
+		{Binary(a.Add(b), "*", c), `(a + b) * c`},
+		{Binary(a.Sub(b), "-", c), `a - b - c`},
+		{a.Sub(Binary(b, "-", c)), `a - (b - c)`},
+		{Minus(a.Add(b)), `-(a + b)`},
+		{a.Sub(Minus(Literal(1))), `a - -1`},
+		{a.Expo(Literal(-1)), `a ^ -1`},
+		{Binary(a.Eq(b), "=", c), `(a = b) = c`},
+		{a.Gt(b.Add(Literal(1))).Is(Literal(nil)), `a > b + 1 IS NULL`},
+		{a.Concat(b).Like(Literal("x%")), `a || b ~~ 'x%'`},
+		{Not(a.Eq(b)), `NOT a = b`},
+		{Not(Or(a, b)), `NOT (a OR b)`},
+		{Not(Not(a)), `NOT NOT a`},
+		{RightUnary(Not(a), " IS NULL"), `(NOT a) IS NULL`},
+		{RightUnary(a.Add(b), " DESC"), `a + b DESC`},
+		{And(a.Eq(Literal(1)), Or(b, c)), "a = 1\n  AND (b OR c)"},
+		{Or(And(a, b), c), "a\n    AND b\n  OR c"},
+		{And(a), `a`},
+		{Cast("int", a.Add(b)), `(a + b)::int`},
+		{Cast("int", Literal(-1)), `(-1)::int`},
+		{Cast("interval", Literal("1 day")), `'1 day'::interval`},
+		{Cast("text", Expression("now()")), `(now())::text`},
+		{As("x", a.Add(b)), `a + b AS x`},
+		{Func("coalesce", a, b.Add(c)), `coalesce(a, b + c)`},
+		{Array(1, a), `ARRAY[1, a]`},
+		{Join(Relation("u"), LeftJoin, Relation("o"), And(a.Eq(b), c)),
+			"u\nLEFT JOIN o ON a = b\n  AND c"},
+		{Group(a.Add(b)), `(a + b)`},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, toPrettySQL(t, c.e))
+	}
+	// Compact output is left as it was.
+	assert.Equal(t, `(a+(b*c))`, toSQL(t, a.Add(b.Mul(c))))
+
+	ctx := query.NewSQLContext()
+	ctx.Pretty, ctx.Indent = true, "\t"
+	buf := bytes.Buffer{}
+	assert.NoError(t, And(a, b).ToSQL(ctx, &buf))
+	assert.Equal(t, "a\n\tAND b", buf.String())
+}
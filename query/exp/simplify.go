@@ -0,0 +1,194 @@
+package exp
+
+import (
+	"strings"
+)
+
+// Returns a normalized equivalent of the condition e, so that conditions built
+// piecemeal render compactly:
+//   - nested ANDs and ORs are flattened, and nil or empty ones, as built from
+//     no filters at all, are dropped;
+//   - true and false constants are folded;
+//   - duplicate operands are removed;
+//   - NOT is pushed through AND and OR (De Morgan), and double NOTs cancel.
+// What is left of an AND or OR with no operands is true or false respectively,
+// and with a single operand, that operand. Operands holding untagged
+// placeholders are never dropped, since that would shift the indexes of the
+// following ones.
+func Simplify(e Exp) Exp {
+	if e == nil {
+		return nil
+	}
+	// Empty conditions are kept until then so that they can be dropped. Only
+	// conditions are replaced, which fit anywhere, so this can't fail.
+	e, _ = Rewrite(e, simplify)
+	if c, ok := e.(*CondExp); ok && len(c.Exps) == 0 {
+		return Literal(isOp(c.Op, "AND"))
+	}
+	return e
+}
+
+func simplify(e Exp) Exp {
+	switch n := e.(type) {
+	case *CondExp:
+		return simplifyCond(n)
+	case *UnaryExp:
+		if isNot(n) {
+			return negate(n.SubExp)
+		}
+	}
+	return e
+}
+
+func simplifyCond(c *CondExp) Exp {
+	and := isOp(c.Op, "AND")
+	if !and && !isOp(c.Op, "OR") {
+		return c
+	}
+	var exps []Exp
+	folded, absorbed := false, false
+	var add func(e Exp)
+	add = func(e Exp) {
+		if sub, ok := e.(*CondExp); ok && (len(sub.Exps) == 0 || isOp(sub.Op, c.Op)) {
+			for _, e := range sub.Exps {
+				add(e)
+			}
+			return
+		}
+		if e == nil {
+			return
+		}
+		if b, ok := constant(e); ok {
+			// true is the identity of AND and absorbs OR, and conversely.
+			folded = true
+			absorbed = absorbed || b != and
+			return
+		}
+		// Operands with untagged placeholders are distinct whatever they look
+		// like, since each stands for its own argument.
+		if !hasUnbind(e) {
+			for _, prev := range exps {
+				if Equal(e, prev) {
+					return
+				}
+			}
+		}
+		exps = append(exps, e)
+	}
+	for _, e := range c.Exps {
+		add(e)
+	}
+	if absorbed {
+		var kept []Exp
+		for _, e := range exps {
+			if hasUnbind(e) {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == 0 {
+			return Literal(!and)
+		}
+		exps = append(kept, Literal(!and))
+	}
+	switch len(exps) {
+	case 0:
+		if folded {
+			return Literal(and)
+		}
+		if len(c.Exps) == 0 {
+			return c
+		}
+		return Cond(c.Op)
+	case 1:
+		// Raw SQL relied on the parentheses of the condition.
+		if l, ok := exps[0].(*LiteralExp); ok && l.isExp {
+			return Group(l)
+		}
+		return exps[0]
+	}
+	if len(exps) == len(c.Exps) {
+		same := true
+		for i := range exps {
+			same = same && exps[i] == c.Exps[i]
+		}
+		if same {
+			return c
+		}
+	}
+	return Cond(c.Op, exps...)
+}
+
+// Returns the simplified negation of e.
+func negate(e Exp) Exp {
+	switch n := e.(type) {
+	case *CondExp:
+		op := ""
+		if isOp(n.Op, "AND") {
+			op = " OR "
+		} else if isOp(n.Op, "OR") {
+			op = " AND "
+		}
+		if op != "" {
+			exps := make([]Exp, 0, len(n.Exps))
+			for _, sub := range n.Exps {
+				if sub != nil {
+					exps = append(exps, negate(sub))
+				}
+			}
+			return simplifyCond(Cond(op, exps...))
+		}
+	case *UnaryExp:
+		if isNot(n) {
+			return n.SubExp
+		}
+	}
+	if b, ok := constant(e); ok {
+		return Literal(!b)
+	}
+	return Not(e)
+}
+
+func isOp(op string, name string) bool {
+	return strings.EqualFold(strings.TrimSpace(op), strings.TrimSpace(name))
+}
+
+func isNot(u *UnaryExp) bool {
+	return u.pre && isOp(u.Op, "NOT")
+}
+
+// Whether e is the constant true or false, and which.
+func constant(e Exp) (value bool, ok bool) {
+	switch n := e.(type) {
+	case *LiteralExp:
+		if !n.isExp {
+			value, ok = n.Value.(bool)
+			return
+		}
+		raw, _ := n.Value.(string)
+		switch strings.ToLower(strings.TrimSpace(raw)) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	case *GroupExp:
+		return constant(n.SubExp)
+	}
+	return false, false
+}
+
+// Whether e holds untagged placeholders, or might.
+func hasUnbind(e Exp) (res bool) {
+	Walk(e, func(e Exp) bool {
+		switch n := e.(type) {
+		case *UnbindExp:
+			res = res || n.Tag == ""
+		case Node:
+		default:
+			// Expressions from other packages may hide anything.
+			res = true
+		}
+		return !res
+	})
+	return
+}
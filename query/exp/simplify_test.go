@@ -0,0 +1,52 @@
+package exp
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimplify(t *testing.T) {
+	a, b, c := Column("a").Eq(Literal(1)), Column("b").Lt(Literal(2)), Column("c")
+	cases := []struct {
+		e    Exp
+		want string
+	}{
+		{And(a, And(b, c)), `((a=1) AND (b<2) AND c)`},
+		{And(Literal(true), a, And(), nil, Or()), `(a=1)`},
+		{And(a, Or(b, Or(c, a))), `((a=1) AND ((b<2) OR c OR (a=1)))`},
+		{Or(a, Literal(true), b), `true`},
+		{And(a, Expression("FALSE")), `false`},
+		{Or(Literal(false), Expression("x OR y")), `(x OR y)`},
+		{And(a, b, Column("a").Eq(Literal(1)), b), `((a=1) AND (b<2))`},
+		{Not(And(a, Or(b, Not(c)))), `( NOT (a=1) OR ( NOT (b<2) AND c))`},
+		{Not(Not(Not(a))), ` NOT (a=1)`},
+		{Not(Or(Literal(false), a)), ` NOT (a=1)`},
+		{Or(a, And(Literal(true), And())), `true`},
+		{And(), `true`},
+		{Or(), `false`},
+		// Other expressions are left alone.
+		{Column("a").Add(Literal(1)), `(a+1)`},
+		{Func("f", And(a, And(b))), `f(((a=1) AND (b<2)))`},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, toSQL(t, Simplify(c.e)))
+	}
+
+	// Untagged placeholders are kept so that the others keep their index, and
+	// they make predicates distinct.
+	p := Column("p").Eq(Unbind())
+	assert.Equal(t, `((p=$1) AND (q=$2) AND false)`, toSQL(t, Simplify(
+		And(Or(p, Literal(false)), Literal(false), Column("q").Eq(Unbind())))))
+	assert.Equal(t, `((p=$1) AND (p=$2))`, toSQL(t, Simplify(And(p, p))))
+
+	// Unchanged conditions are kept as they are.
+	cond := And(a, b)
+	assert.True(t, Simplify(cond) == Exp(cond))
+	assert.Nil(t, Simplify(nil))
+}
+
+func TestCondExp_And(t *testing.T) {
+	c := And(Column("a")).And(Column("b"))
+	assert.Len(t, c.Exps, 2)
+	assert.NotNil(t, c.Exps[0])
+}
@@ -0,0 +1,440 @@
+package exp
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Type categories, as in pg_type.typcategory. Operators are checked against
+// the categories of their operands.
+type Category uint8
+
+const (
+	UnknownCategory  Category = iota
+	NumericCategory  Category = iota
+	StringCategory   Category = iota
+	BooleanCategory  Category = iota
+	DateTimeCategory Category = iota
+	TimespanCategory Category = iota
+	ArrayCategory    Category = iota
+	JSONCategory     Category = iota
+	RangeCategory    Category = iota
+	OtherCategory    Category = iota // Only compared by name.
+)
+
+// Type is an SQL type. Expressions of unknown type, i.e. a nil *Type, are
+// never type errors, so typing is optional.
+type Type struct {
+	Name     string
+	Category Category
+	Elem     *Type // Of arrays and ranges.
+	// Order in which numeric types are promoted.
+	rank int
+}
+
+var (
+	SmallInt    = &Type{Name: "smallint", Category: NumericCategory, rank: 1}
+	Integer     = &Type{Name: "integer", Category: NumericCategory, rank: 2}
+	BigInt      = &Type{Name: "bigint", Category: NumericCategory, rank: 3}
+	Numeric     = &Type{Name: "numeric", Category: NumericCategory, rank: 4}
+	Real        = &Type{Name: "real", Category: NumericCategory, rank: 5}
+	Double      = &Type{Name: "double precision", Category: NumericCategory, rank: 6}
+	Text        = &Type{Name: "text", Category: StringCategory}
+	Boolean     = &Type{Name: "boolean", Category: BooleanCategory}
+	Date        = &Type{Name: "date", Category: DateTimeCategory}
+	Timestamp   = &Type{Name: "timestamp", Category: DateTimeCategory}
+	TimestampTz = &Type{Name: "timestamptz", Category: DateTimeCategory}
+	Interval    = &Type{Name: "interval", Category: TimespanCategory}
+	Jsonb       = &Type{Name: "jsonb", Category: JSONCategory}
+	Bytea       = &Type{Name: "bytea", Category: OtherCategory}
+	UUID        = &Type{Name: "uuid", Category: OtherCategory}
+	Int4Range   = &Type{Name: "int4range", Category: RangeCategory, Elem: Integer}
+	Int8Range   = &Type{Name: "int8range", Category: RangeCategory, Elem: BigInt}
+	NumRange    = &Type{Name: "numrange", Category: RangeCategory, Elem: Numeric}
+	TsRange     = &Type{Name: "tsrange", Category: RangeCategory, Elem: Timestamp}
+	TstzRange   = &Type{Name: "tstzrange", Category: RangeCategory, Elem: TimestampTz}
+	DateRange   = &Type{Name: "daterange", Category: RangeCategory, Elem: Date}
+	// Multiranges mix with ranges of the same elements, but only in the
+	// operators comparing their extents (see sameElems).
+	Int4Multirange = &Type{Name: "int4multirange", Category: RangeCategory, Elem: Integer}
+	Int8Multirange = &Type{Name: "int8multirange", Category: RangeCategory, Elem: BigInt}
+	NumMultirange  = &Type{Name: "nummultirange", Category: RangeCategory, Elem: Numeric}
+	TsMultirange   = &Type{Name: "tsmultirange", Category: RangeCategory, Elem: Timestamp}
+	TstzMultirange = &Type{Name: "tstzmultirange", Category: RangeCategory, Elem: TimestampTz}
+	DateMultirange = &Type{Name: "datemultirange", Category: RangeCategory, Elem: Date}
+)
+
+// Type names and aliases, lower-case.
+var typeNames = map[string]*Type{
+	"smallint": SmallInt, "int2": SmallInt,
+	"integer": Integer, "int": Integer, "int4": Integer,
+	"bigint": BigInt, "int8": BigInt,
+	"numeric": Numeric, "decimal": Numeric,
+	"real": Real, "float4": Real,
+	"double precision": Double, "float8": Double, "float": Double,
+	"text": Text, "varchar": Text, "character varying": Text, "char": Text,
+	"character": Text, "bpchar": Text, "name": Text,
+	"boolean": Boolean, "bool": Boolean,
+	"date": Date,
+	"timestamp": Timestamp, "timestamp without time zone": Timestamp,
+	"timestamptz": TimestampTz, "timestamp with time zone": TimestampTz,
+	"interval": Interval,
+	"json": Jsonb, "jsonb": Jsonb,
+	"bytea": Bytea,
+	"uuid": UUID,
+	"int4range": Int4Range, "int8range": Int8Range, "numrange": NumRange,
+	"tsrange": TsRange, "tstzrange": TstzRange, "daterange": DateRange,
+	"int4multirange": Int4Multirange, "int8multirange": Int8Multirange,
+	"nummultirange": NumMultirange, "tsmultirange": TsMultirange,
+	"tstzmultirange": TstzMultirange, "datemultirange": DateMultirange,
+}
+
+func ArrayOf(elem *Type) *Type {
+	return &Type{Name: elem.Name + "[]", Category: ArrayCategory, Elem: elem}
+}
+
+// Returns the type named name, e.g. "int8" or "varchar(20)[]". Unknown names
+// give types of OtherCategory.
+func ParseType(name string) *Type {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if strings.HasSuffix(name, "[]") {
+		return ArrayOf(ParseType(strings.TrimSuffix(name, "[]")))
+	}
+	// Type modifiers don't matter here.
+	base := name
+	if i := strings.IndexByte(name, '('); i >= 0 {
+		if j := strings.IndexByte(name, ')'); j > i {
+			base = strings.TrimSpace(name[:i] + name[j+1:])
+		}
+	}
+	if t, ok := typeNames[base]; ok {
+		return t
+	}
+	return &Type{Name: name, Category: OtherCategory}
+}
+
+func (t *Type) String() string {
+	if t == nil {
+		return "unknown"
+	}
+	return t.Name
+}
+
+func sameType(a, b *Type) bool {
+	if a.Category != b.Category {
+		return false
+	}
+	switch a.Category {
+	case NumericCategory, StringCategory, JSONCategory:
+		return true
+	case ArrayCategory:
+		return a.Elem == nil || b.Elem == nil || sameType(a.Elem, b.Elem)
+	case DateTimeCategory:
+		// Dates and timestamps compare with each other.
+		return true
+	}
+	return a.Name == b.Name
+}
+
+// Whether a and b are ranges or multiranges of the same elements. Unlike the
+// other operators, those comparing the extents of ranges, e.g. && or @>, mix
+// ranges with multiranges.
+func sameElems(a, b *Type) bool {
+	if a.Category != RangeCategory || b.Category != RangeCategory {
+		return false
+	}
+	if a.Elem != nil && b.Elem != nil {
+		return a.Elem.Name == b.Elem.Name
+	}
+	return a.Name == b.Name
+}
+
+// Result types of functions, by lower-case name.
+var funcTypes = map[string]*Type{
+	"count": BigInt, "now": TimestampTz, "current_timestamp": TimestampTz,
+	"lower": Text, "upper": Text, "trim": Text, "concat": Text,
+	"length": Integer, "char_length": Integer,
+	"to_jsonb": Jsonb, "jsonb_build_object": Jsonb, "jsonb_agg": Jsonb,
+	"bool_and": Boolean, "bool_or": Boolean, "exists": Boolean,
+}
+
+// Returns the type of the result of e, or nil if unknown.
+func TypeOf(e Exp) *Type {
+	switch n := e.(type) {
+	case *ColumnExp:
+		return n.Type
+	case *LiteralExp:
+		if n.isExp {
+			return nil
+		}
+		return typeOfValue(n.Value)
+	case *ArrayExp:
+		for _, val := range n.Values {
+			var t *Type
+			if sub, ok := val.(Exp); ok {
+				t = TypeOf(sub)
+			} else {
+				t = typeOfValue(val)
+			}
+			if t != nil {
+				return ArrayOf(t)
+			}
+		}
+		return &Type{Name: "anyarray", Category: ArrayCategory}
+	case *GroupExp:
+		return TypeOf(n.SubExp)
+	case *AliasExp:
+		return TypeOf(n.SubExp)
+	case *CastExp:
+		return ParseType(n.Type)
+	case *CondExp:
+		return Boolean
+	case *FuncExp:
+		return funcTypes[strings.ToLower(n.Name)]
+	case *UnaryExp:
+		if isNot(n) {
+			return Boolean
+		} else if !n.pre {
+			// e.g. ASC, or IS NULL.
+			return nil
+		}
+		return TypeOf(n.SubExp)
+	case *BinaryExp:
+		t, _ := binaryType(n.Op, TypeOf(n.LeftExp), TypeOf(n.RightExp))
+		return t
+	}
+	return nil
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// Strings are of unknown type, since Postgres coerces string literals to the
+// type they are used as.
+func typeOfValue(v interface{}) *Type {
+	switch v.(type) {
+	case nil, string, driver.Valuer:
+		return nil
+	case time.Time:
+		return TimestampTz
+	case []byte:
+		return Bytea
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() && !rv.Type().Implements(valuerType) {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return Boolean
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return SmallInt
+	case reflect.Int32, reflect.Uint16:
+		return Integer
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return BigInt
+	case reflect.Uint, reflect.Uint64:
+		return Numeric
+	case reflect.Float32:
+		return Real
+	case reflect.Float64:
+		return Double
+	case reflect.Slice, reflect.Array:
+		elem := reflect.New(rv.Type().Elem()).Elem().Interface()
+		if t := typeOfValue(elem); t != nil {
+			return ArrayOf(t)
+		}
+	}
+	return nil
+}
+
+// Returns the type of the result of l op r, or an error if op cannot be
+// applied to them. Operators it doesn't know of always type check.
+func binaryType(op string, l *Type, r *Type) (*Type, error) {
+	op = strings.ToUpper(strings.TrimSpace(op))
+	both := l != nil && r != nil
+	mismatch := func() (*Type, error) {
+		return nil, fmt.Errorf("operator %s cannot be applied to %s and %s", op, l, r)
+	}
+	is := func(t *Type, cats ... Category) bool {
+		if t == nil {
+			return true
+		}
+		for _, c := range cats {
+			if t.Category == c {
+				return true
+			}
+		}
+		return false
+	}
+	switch op {
+	case "=", "<>", "!=", "<", ">", "<=", ">=":
+		if both && !sameType(l, r) {
+			return mismatch()
+		}
+		return Boolean, nil
+	case "IS", "IS NOT":
+		return Boolean, nil
+	case "~~", "~~*", "!~~", "!~~*", "SIMILAR TO", "~", "~*", "!~", "!~*":
+		if !is(l, StringCategory) || !is(r, StringCategory) {
+			return mismatch()
+		}
+		return Boolean, nil
+	case "@>", "<@":
+		// Ranges contain ranges of the same type, or elements of theirs.
+		rng, elem := l, r
+		if op == "<@" {
+			rng, elem = r, l
+		}
+		if rng != nil && rng.Category == RangeCategory {
+			if elem != nil && !sameElems(rng, elem) && (rng.Elem == nil || !sameType(rng.Elem, elem)) {
+				return mismatch()
+			}
+			return Boolean, nil
+		} else if elem != nil && elem.Category == RangeCategory {
+			if rng != nil {
+				return mismatch()
+			}
+			return Boolean, nil
+		}
+		if both && (l.Category != r.Category || !is(l, ArrayCategory, JSONCategory)) {
+			return mismatch()
+		}
+		if !is(l, ArrayCategory, JSONCategory) || !is(r, ArrayCategory, JSONCategory) {
+			return mismatch()
+		}
+		return Boolean, nil
+	case "&&":
+		if !is(l, ArrayCategory, RangeCategory) || !is(r, ArrayCategory, RangeCategory) ||
+			both && !sameType(l, r) && !sameElems(l, r) {
+			return mismatch()
+		}
+		return Boolean, nil
+	case "-|-", "&<", "&>":
+		if !is(l, RangeCategory) || !is(r, RangeCategory) || both && !sameElems(l, r) {
+			return mismatch()
+		}
+		return Boolean, nil
+	case "||":
+		if l != nil && l.Category == BooleanCategory || r != nil && r.Category == BooleanCategory {
+			return mismatch()
+		}
+		if both && l.Category == JSONCategory && r.Category == JSONCategory {
+			return Jsonb, nil
+		}
+		if l != nil && l.Category == ArrayCategory {
+			return l, nil
+		} else if r != nil && r.Category == ArrayCategory {
+			return r, nil
+		}
+		return Text, nil
+	case "+", "-", "*", "/", "%", "^":
+		// Union, difference and intersection of ranges.
+		if l != nil && l.Category == RangeCategory || r != nil && r.Category == RangeCategory {
+			if op != "+" && op != "-" && op != "*" || !is(l, RangeCategory) || !is(r, RangeCategory) ||
+				both && !sameType(l, r) {
+				return mismatch()
+			} else if l != nil {
+				return l, nil
+			}
+			return r, nil
+		}
+		if is(l, NumericCategory) && is(r, NumericCategory) {
+			if !both {
+				return nil, nil
+			} else if op == "^" {
+				return Double, nil
+			} else if l.rank >= r.rank {
+				return l, nil
+			}
+			return r, nil
+		}
+		// Dates, times and intervals have arithmetic of their own: they are
+		// added and subtracted, while intervals are also scaled.
+		switch {
+		case (op == "+" || op == "-") && is(l, DateTimeCategory, TimespanCategory, NumericCategory) &&
+			is(r, DateTimeCategory, TimespanCategory, NumericCategory):
+			if both && l.Category == DateTimeCategory && r.Category == DateTimeCategory {
+				if op == "+" {
+					return mismatch()
+				}
+				return Interval, nil
+			} else if l != nil && l.Category != NumericCategory {
+				return l, nil
+			}
+			return r, nil
+		case (op == "*" || op == "/") && is(l, TimespanCategory, NumericCategory) &&
+			is(r, TimespanCategory, NumericCategory):
+			if l != nil && l.Category == TimespanCategory {
+				return l, nil
+			}
+			return r, nil
+		}
+		return mismatch()
+	case "<<", ">>", "&", "|":
+		// Whether a range is strictly left or right of another.
+		if (op == "<<" || op == ">>") && (l != nil && l.Category == RangeCategory ||
+			r != nil && r.Category == RangeCategory) {
+			if !is(l, RangeCategory) || !is(r, RangeCategory) || both && !sameElems(l, r) {
+				return mismatch()
+			}
+			return Boolean, nil
+		}
+		if both && l.Category == NumericCategory && r.Category == NumericCategory {
+			return l, nil
+		}
+		if l != nil && l.Category != NumericCategory && l.Category != OtherCategory ||
+			r != nil && r.Category != NumericCategory && r.Category != OtherCategory {
+			return mismatch()
+		}
+		return l, nil
+	}
+	return nil, nil
+}
+
+// Checks that the operands of conditions are booleans.
+func checkBoolean(op string, e Exp) error {
+	if t := TypeOf(e); t != nil && t.Category != BooleanCategory {
+		return fmt.Errorf("operand of %s must be boolean, got %s", strings.TrimSpace(op), t)
+	}
+	return nil
+}
+
+// The elements of arrays must all be of one type.
+func (a *ArrayExp) checkElems() error {
+	var first *Type
+	for _, val := range a.Values {
+		var t *Type
+		if sub, ok := val.(Exp); ok {
+			t = TypeOf(sub)
+		} else {
+			t = typeOfValue(val)
+		}
+		if t == nil {
+			continue
+		} else if first == nil {
+			first = t
+		} else if !sameType(first, t) {
+			return fmt.Errorf("array elements of types %s and %s", first, t)
+		}
+	}
+	return nil
+}
+
+func (u *UnaryExp) check() error {
+	t := TypeOf(u.SubExp)
+	if t == nil || !u.pre {
+		return nil
+	}
+	switch op := strings.ToUpper(strings.TrimSpace(u.Op)); op {
+	case "NOT":
+		return checkBoolean(op, u.SubExp)
+	case "-", "+":
+		if t.Category != NumericCategory && t.Category != TimespanCategory {
+			return fmt.Errorf("operator %s cannot be applied to %s", op, t)
+		}
+	}
+	return nil
+}
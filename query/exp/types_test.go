@@ -0,0 +1,188 @@
+package exp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/query"
+)
+
+func TestParseType(t *testing.T) {
+	assert.Equal(t, BigInt, ParseType("INT8"))
+	assert.Equal(t, Text, ParseType("varchar(20)"))
+	assert.Equal(t, Double, ParseType("double  precision"))
+	assert.Equal(t, TimestampTz, ParseType("timestamp(3) with time zone"))
+	arr := ParseType("integer[]")
+	assert.Equal(t, ArrayCategory, arr.Category)
+	assert.Equal(t, Integer, arr.Elem)
+	assert.Equal(t, "integer[]", arr.String())
+	other := ParseType("tsvector")
+	assert.Equal(t, OtherCategory, other.Category)
+	assert.Equal(t, "tsvector", other.Name)
+}
+
+func TestTypeOf(t *testing.T) {
+	age := Column("age").SetType(Integer)
+	cases := []struct {
+		e    Exp
+		want *Type
+	}{
+		{Column("a"), nil},
+		{age, Integer},
+		{Literal("x"), nil},
+		{Literal(int32(1)), Integer},
+		{Literal(1), BigInt},
+		{Literal(1.5), Double},
+		{Literal(true), Boolean},
+		{Literal(time.Time{}), TimestampTz},
+		{Literal([]byte("x")), Bytea},
+		{Expression("now()"), nil},
+		{age.Add(Literal(int16(1))), Integer},
+		{age.Mul(Literal(1.5)), Double},
+		{Binary(age, "^", Literal(2)), Double},
+		{age.Gt(Literal(1)), Boolean},
+		{And(Column("a"), Column("b")), Boolean},
+		{Not(Column("a")), Boolean},
+		{Cast("numeric(10, 2)", Column("a")), Numeric},
+		{Func("COUNT", All), BigInt},
+		{Func("now").Sub(Column("ts").SetType(Timestamp)), Interval},
+		{Func("now").Sub(Cast("interval", Literal("1 day"))), TimestampTz},
+		{Group(age), Integer},
+		{As("x", age), Integer},
+		{Column("name").SetType(Text).Concat(Literal("!")), Text},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, TypeOf(c.e), toSQL(t, c.e))
+	}
+	assert.Equal(t, ArrayOf(Text), TypeOf(Array("a", Column("b").SetType(Text))))
+	assert.Equal(t, ArrayOf(BigInt), TypeOf(Literal([]int{1, 2})))
+}
+
+func TestTypeCheck(t *testing.T) {
+	tags := Column("tags").SetType(ArrayOf(Text))
+	data := Column("data").SetType(Jsonb)
+	name := Column("name").SetType(Text)
+	age := Column("age").SetType(Integer)
+	valid := []Exp{
+		tags.Contain(Array("a", Unbind())),
+		tags.Overlap(Unbind()),
+		data.Contain(Cast("jsonb", Literal(`{"a":1}`))),
+		data.ContainedBy(Column("other")),
+		name.Like(Literal("a%")),
+		name.Match(Unbind(), false),
+		age.Gt(Literal(1.5)),
+		age.Eq(Unbind()),
+		age.Is(Literal(nil)),
+		age.In(Array(1, 2)),
+		Column("ts").SetType(Timestamp).Gte(Func("now").Sub(Cast("interval", Literal("1 day")))),
+		Column("d").SetType(Date).Lt(Literal(time.Now())),
+		And(age.Gt(Literal(1)), Not(Column("banned")), Literal(true)),
+		Minus(age),
+		tags.Concat(Literal("b")),
+		data.Concat(Column("more").SetType(Jsonb)),
+	}
+	for _, e := range valid {
+		b := bytes.Buffer{}
+		assert.NoError(t, e.ToSQL(query.NewSQLContext(), &b), toSQL(t, e))
+	}
+	invalid := []Exp{
+		age.Contain(Array(1)),
+		tags.Contain(data),
+		name.Contain(Literal("a")),
+		age.Like(Literal("1%")),
+		tags.Match(Literal("a"), true),
+		age.Eq(name),
+		age.Eq(Literal(true)),
+		tags.Overlap(Array(1, 2)),
+		name.Add(Literal(1)),
+		Column("ts").SetType(Timestamp).Mul(Literal(2)),
+		Column("ts").SetType(Timestamp).Add(Column("d").SetType(Date)),
+		And(age.Gt(Literal(1)), age),
+		Or(name),
+		Not(age),
+		Minus(name),
+		Array(1, "a", true),
+		Column("ok").SetType(Boolean).Concat(name),
+		// Errors of subexpressions are returned too.
+		And(Column("a"), Group(age.Like(Literal("1%")))),
+	}
+	for i, e := range invalid {
+		b := bytes.Buffer{}
+		assert.Error(t, e.ToSQL(query.NewSQLContext(), &b), i)
+	}
+}
+
+func TestTypeCheck_Range(t *testing.T) {
+	during := Column("during").SetType(ParseType("tstzrange"))
+	ids := Column("ids").SetType(Int4Range)
+	valid := []Exp{
+		during.Contain(Func("now")),
+		during.Contain(Column("other").SetType(TstzRange)),
+		Func("now").ContainedBy(during),
+		during.Overlap(Unbind()),
+		during.Adjacent(Column("other")),
+		ids.NotExtendRight(Cast("int4range", Literal("[1,5)"))),
+		ids.NotExtendLeft(Unbind()),
+		ids.LeftShift(Column("other").SetType(Int4Range)),
+		ids.Mul(Column("other").SetType(Int4Range)),
+		ids.Contain(Literal(int32(1))),
+		// Multiranges mix with ranges when comparing extents.
+		Column("busy").SetType(ParseType("tstzmultirange")).Overlap(during),
+		Column("busy").SetType(TstzMultirange).Contain(Func("now")),
+		Column("busy").SetType(TstzMultirange).Contain(during),
+		during.ContainedBy(Column("busy").SetType(TstzMultirange)),
+		during.Adjacent(Column("busy").SetType(TstzMultirange)),
+		Column("busy").SetType(TstzMultirange).LeftShift(during),
+		Column("busy").SetType(TstzMultirange).Add(Column("free").SetType(TstzMultirange)),
+	}
+	for _, e := range valid {
+		b := bytes.Buffer{}
+		assert.NoError(t, e.ToSQL(query.NewSQLContext(), &b), toSQL(t, e))
+	}
+	assert.Equal(t, Int4Range, TypeOf(ids.Add(Unbind())))
+	assert.Equal(t, Boolean, TypeOf(ids.Adjacent(Unbind())))
+	invalid := []Exp{
+		during.Contain(Literal(int32(1))),
+		during.Overlap(ids),
+		during.Overlap(Column("tags").SetType(ArrayOf(Text))),
+		during.Adjacent(Func("now")),
+		ids.NotExtendRight(Literal(1)),
+		ids.LeftShift(Literal(1)),
+		ids.Div(Unbind()),
+		ids.Intersect(Unbind()),
+		Column("n").SetType(Integer).ContainedBy(during),
+		Column("tags").SetType(ArrayOf(Text)).Contain(during),
+		Column("busy").SetType(TstzMultirange).Overlap(ids),
+		// But not in set operations nor comparisons.
+		Column("busy").SetType(TstzMultirange).Add(during),
+		during.Sub(Column("busy").SetType(TstzMultirange)),
+		Column("busy").SetType(TstzMultirange).Mul(during),
+		Column("busy").SetType(TstzMultirange).Eq(during),
+		during.Lt(Column("busy").SetType(TstzMultirange)),
+	}
+	for i, e := range invalid {
+		b := bytes.Buffer{}
+		assert.Error(t, e.ToSQL(query.NewSQLContext(), &b), i)
+	}
+
+	b := bytes.Buffer{}
+	assert.NoError(t, ids.Adjacent(Unbind()).ToSQL(query.NewSQLContext(), &b))
+	assert.Equal(t, "(ids-|-$1)", b.String())
+}
+
+func TestTypeCheck_JSON(t *testing.T) {
+	e := Column("tags").SetType(ParseType("text[]")).Contain(Array("a"))
+	data, err := EncodeJSON(e)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"sql_type":"text[]"`)
+	res, err := DecodeJSON(data)
+	assert.NoError(t, err)
+	assert.Equal(t, ArrayOf(Text), res.(*BinaryExp).LeftExp.(*ColumnExp).Type)
+
+	res, err = DecodeJSON([]byte(`{"type":"binary","op":"@>","args":[` +
+		`{"type":"column","name":"n","sql_type":"int"},{"type":"literal","value":1}]}`))
+	assert.NoError(t, err)
+	b := bytes.Buffer{}
+	assert.Error(t, res.ToSQL(query.NewSQLContext(), &b))
+}
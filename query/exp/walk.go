@@ -0,0 +1,332 @@
+package exp
+
+import (
+	"fmt"
+)
+
+// Node is implemented by every expression type of this package, which allows
+// inspecting and transforming trees without knowing all the types involved.
+type Node interface {
+	Exp
+	// Returns the direct subexpressions, in rendering order.
+	Children() []Exp
+	// Returns a copy of the node with its children replaced, in the order
+	// returned by Children, or an error if they don't fit.
+	WithChildren(children []Exp) (Exp, error)
+}
+
+// Calls fn on e and, as long as fn returns true, on its descendants, depth
+// first. Expressions which are not Nodes are visited but not descended into.
+func Walk(e Exp, fn func(Exp) bool) {
+	if e == nil || !fn(e) {
+		return
+	}
+	if n, ok := e.(Node); ok {
+		for _, child := range n.Children() {
+			Walk(child, fn)
+		}
+	}
+}
+
+// Returns e with every subexpression replaced by the result of fn, bottom-up:
+// fn is given a node after its children have been rewritten. Nodes with no
+// changed children are kept as they are rather than copied. Fails if fn
+// returns a node which doesn't fit where it goes, e.g. a function call as the
+// relation of a column.
+func Rewrite(e Exp, fn func(Exp) Exp) (Exp, error) {
+	if e == nil {
+		return nil, nil
+	}
+	if n, ok := e.(Node); ok {
+		children := n.Children()
+		var changed []Exp
+		for i, child := range children {
+			if child == nil {
+				continue
+			}
+			res, err := Rewrite(child, fn)
+			if err != nil {
+				return nil, err
+			}
+			if res != child {
+				if changed == nil {
+					changed = append([]Exp{}, children...)
+				}
+				changed[i] = res
+			}
+		}
+		if changed != nil {
+			var err error
+			if e, err = n.WithChildren(changed); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return fn(e), nil
+}
+
+func checkArity(node Exp, children []Exp, n int) error {
+	if len(children) != n {
+		return fmt.Errorf("%T takes %d children, got %d", node, n, len(children))
+	}
+	return nil
+}
+
+func (l *LiteralExp) Children() []Exp {
+	return nil
+}
+
+func (l *LiteralExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(l, children, 0); err != nil {
+		return nil, err
+	}
+	res := *l
+	res.Exp = &res
+	return &res, nil
+}
+
+// Only values that are expressions are children.
+func (a *ArrayExp) Children() []Exp {
+	var res []Exp
+	for _, val := range a.Values {
+		if e, ok := val.(Exp); ok {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func (a *ArrayExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(a, children, len(a.Children())); err != nil {
+		return nil, err
+	}
+	res := *a
+	res.Values = make([]interface{}, len(a.Values))
+	j := 0
+	for i, val := range a.Values {
+		if _, ok := val.(Exp); ok {
+			res.Values[i] = children[j]
+			j++
+		} else {
+			res.Values[i] = val
+		}
+	}
+	res.Exp = &res
+	return &res, nil
+}
+
+func (u *UnbindExp) Children() []Exp {
+	return nil
+}
+
+func (u *UnbindExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(u, children, 0); err != nil {
+		return nil, err
+	}
+	res := *u
+	res.Exp = &res
+	return &res, nil
+}
+
+func (g *GroupExp) Children() []Exp {
+	return []Exp{g.SubExp}
+}
+
+func (g *GroupExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(g, children, 1); err != nil {
+		return nil, err
+	}
+	res := *g
+	res.SubExp = children[0]
+	res.Exp = &res
+	return &res, nil
+}
+
+func (b *BinaryExp) Children() []Exp {
+	return []Exp{b.LeftExp, b.RightExp}
+}
+
+func (b *BinaryExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(b, children, 2); err != nil {
+		return nil, err
+	}
+	res := *b
+	res.LeftExp, res.RightExp = children[0], children[1]
+	res.Exp = &res
+	return &res, nil
+}
+
+func (u *UnaryExp) Children() []Exp {
+	return []Exp{u.SubExp}
+}
+
+func (u *UnaryExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(u, children, 1); err != nil {
+		return nil, err
+	}
+	res := *u
+	res.SubExp = children[0]
+	res.Exp = &res
+	return &res, nil
+}
+
+func (c *CondExp) Children() []Exp {
+	return c.Exps
+}
+
+func (c *CondExp) WithChildren(children []Exp) (Exp, error) {
+	res := *c
+	res.Exps = append([]Exp{}, children...)
+	res.Exp = &res
+	return &res, nil
+}
+
+func (f *FuncExp) Children() []Exp {
+	return f.Args
+}
+
+func (f *FuncExp) WithChildren(children []Exp) (Exp, error) {
+	res := *f
+	res.Args = append([]Exp{}, children...)
+	res.Exp = &res
+	return &res, nil
+}
+
+func (c *CastExp) Children() []Exp {
+	return []Exp{c.SubExp}
+}
+
+func (c *CastExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(c, children, 1); err != nil {
+		return nil, err
+	}
+	res := *c
+	res.SubExp = children[0]
+	res.Exp = &res
+	return &res, nil
+}
+
+func (a *AliasExp) Children() []Exp {
+	return []Exp{a.SubExp}
+}
+
+func (a *AliasExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(a, children, 1); err != nil {
+		return nil, err
+	}
+	res := *a
+	res.SubExp = children[0]
+	res.Exp = &res
+	return &res, nil
+}
+
+// The relation, if any, is the only child of a column.
+func (c *ColumnExp) Children() []Exp {
+	if c.Relation == nil {
+		return nil
+	}
+	return []Exp{c.Relation}
+}
+
+func (c *ColumnExp) WithChildren(children []Exp) (Exp, error) {
+	res := *c
+	if c.Relation == nil {
+		if err := checkArity(c, children, 0); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := checkArity(c, children, 1); err != nil {
+			return nil, err
+		}
+		switch rel := children[0].(type) {
+		case *RelationExp:
+			res.Relation = rel
+		case *AliasExp:
+			// The column is then qualified by the alias, e.g. when users is
+			// replaced with users AS u.
+			res.Relation = Relation(rel.Name)
+		default:
+			return nil, fmt.Errorf("the relation of a column must be a *RelationExp or *AliasExp, got %T",
+				children[0])
+		}
+	}
+	res.Exp = &res
+	return &res, nil
+}
+
+// The schema, if any, is the only child of a relation.
+func (r *RelationExp) Children() []Exp {
+	if r.Schema == nil {
+		return nil
+	}
+	return []Exp{r.Schema}
+}
+
+func (r *RelationExp) WithChildren(children []Exp) (Exp, error) {
+	res := *r
+	if r.Schema == nil {
+		if err := checkArity(r, children, 0); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := checkArity(r, children, 1); err != nil {
+			return nil, err
+		}
+		s, ok := children[0].(*SchemaExp)
+		if !ok {
+			return nil, fmt.Errorf("the schema of a relation must be a *SchemaExp, got %T",
+				children[0])
+		}
+		res.Schema = s
+	}
+	res.Exp = &res
+	return &res, nil
+}
+
+func (s *SchemaExp) Children() []Exp {
+	return nil
+}
+
+func (s *SchemaExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(s, children, 0); err != nil {
+		return nil, err
+	}
+	res := *s
+	res.Exp = &res
+	return &res, nil
+}
+
+func (a *AssignExp) Children() []Exp {
+	return []Exp{a.Col, a.RightExp}
+}
+
+func (a *AssignExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(a, children, 2); err != nil {
+		return nil, err
+	}
+	res := *a
+	res.Col, res.RightExp = children[0], children[1]
+	res.Exp = &res
+	return &res, nil
+}
+
+// The condition, if any, comes after both sides of a join.
+func (j *JoinExp) Children() []Exp {
+	if j.On == nil {
+		return []Exp{j.Left, j.Right}
+	}
+	return []Exp{j.Left, j.Right, j.On}
+}
+
+func (j *JoinExp) WithChildren(children []Exp) (Exp, error) {
+	if err := checkArity(j, children, len(j.Children())); err != nil {
+		return nil, err
+	}
+	res := *j
+	res.Left, res.Right = children[0], children[1]
+	if j.On != nil {
+		res.On = children[2]
+	}
+	res.Exp = &res
+	return &res, nil
+}
@@ -0,0 +1,115 @@
+package exp
+
+import (
+	"testing"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/query"
+)
+
+func toSQL(t *testing.T, e Exp) string {
+	b := bytes.Buffer{}
+	assert.NoError(t, e.ToSQL(query.NewSQLContext(), &b))
+	return b.String()
+}
+
+func TestWalk(t *testing.T) {
+	u := Relation("u")
+	e := And(
+		Column("a").SetRelation(u).Eq(Func("lower", Column("b"))),
+		Or(Not(Column("c")), Cast("int", Column("d")).Gt(Literal(1))),
+		Array(1, Column("e")),
+		As("x", Group(Minus(Column("f")))),
+		Assign(Column("g"), Unbind()),
+	)
+	var cols []string
+	Walk(e, func(e Exp) bool {
+		if c, ok := e.(*ColumnExp); ok {
+			cols = append(cols, c.Name)
+		}
+		return true
+	})
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f", "g"}, cols)
+
+	// Not descending into a node skips its subtree.
+	cols = nil
+	Walk(e, func(e Exp) bool {
+		if c, ok := e.(*ColumnExp); ok {
+			cols = append(cols, c.Name)
+		}
+		_, isOr := e.(*CondExp)
+		return !isOr || e.(*CondExp).Op != " OR "
+	})
+	assert.Equal(t, []string{"a", "b", "e", "f", "g"}, cols)
+}
+
+func TestRewrite(t *testing.T) {
+	c := Column("a").SetRelation(Relation("users"))
+	e := And(c.Eq(Literal(1)), Column("b").Lt(Func("f", Column("a"))))
+	before := toSQL(t, e)
+
+	// Swaps the relation alias, and qualifies unqualified columns.
+	res, err := Rewrite(e, func(e Exp) Exp {
+		switch n := e.(type) {
+		case *RelationExp:
+			if n.Name == "users" {
+				return Relation("u")
+			}
+		case *ColumnExp:
+			if n.Relation == nil {
+				return Column(n.Name).SetRelation(Relation("u"))
+			}
+		}
+		return e
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `((u.a=1) AND (u.b<f(u.a)))`, toSQL(t, res))
+	// The original tree is left untouched.
+	assert.Equal(t, before, toSQL(t, e))
+	assert.Equal(t, "users", c.Relation.Name)
+
+	// Unchanged trees are not copied.
+	res, err = Rewrite(e, func(e Exp) Exp { return e })
+	assert.NoError(t, err)
+	assert.True(t, res == Exp(e))
+
+	// Relations may be replaced with aliases, which columns are qualified by.
+	res, err = Rewrite(c, func(e Exp) Exp {
+		if r, ok := e.(*RelationExp); ok {
+			return As("u", r)
+		}
+		return e
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `u.a`, toSQL(t, res))
+
+	// Nodes which don't fit are errors.
+	_, err = Rewrite(c, func(e Exp) Exp {
+		if _, ok := e.(*RelationExp); ok {
+			return Func("f")
+		}
+		return e
+	})
+	assert.Error(t, err)
+	_, err = Binary(Column("a"), "=", Column("b")).WithChildren(nil)
+	assert.Error(t, err)
+	_, err = c.WithChildren([]Exp{Column("x")})
+	assert.Error(t, err)
+	_, err = Relation("t").SetSchema(Schema("s")).WithChildren([]Exp{Column("x")})
+	assert.Error(t, err)
+}
+
+func TestJoin(t *testing.T) {
+	j := Join(As("u", Relation("users")), LeftJoin, Relation("orders"),
+		Column("uid").SetRelation(Relation("orders")).Eq(Column("id").SetRelation(Relation("u"))))
+	assert.Equal(t, `users AS u LEFT JOIN orders ON (orders.uid=u.id)`, toSQL(t, j))
+	res, err := Rewrite(j, func(e Exp) Exp {
+		if r, ok := e.(*RelationExp); ok && r.Name == "orders" {
+			return Relation("o")
+		}
+		return e
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `users AS u LEFT JOIN o ON (o.uid=u.id)`, toSQL(t, res))
+	assert.Equal(t, `a CROSS JOIN b`, toSQL(t, Join(Relation("a"), CrossJoin, Relation("b"), nil)))
+}
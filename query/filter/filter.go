@@ -0,0 +1,409 @@
+// Package filter compiles the filters of URL query strings, e.g.
+//   ?age.gte=18&name.ilike=al%&status.in=a,b&order=created_at.desc&limit=20
+// into conditions and clauses of SQL recipes. Only the columns of a schema may
+// be filtered on, and values are coerced to their types and bound to
+// placeholders rather than written into the query.
+package filter
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/tsealex/dbutil/query/clause"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+// Parameters which are not filters.
+const (
+	OrderParam  = "order"
+	LimitParam  = "limit"
+	OffsetParam = "offset"
+)
+
+// A column which may be filtered on.
+type Field struct {
+	Column string
+	// Values are coerced to it, see Schema.Add. Nil keeps them as strings.
+	Type reflect.Type
+}
+
+// Schema is the set of fields filters may refer to, by name.
+type Schema struct {
+	fields map[string]Field
+	// Limits above it are lowered to it, if positive, and filters giving none
+	// are limited to it.
+	MaxLimit int
+	// Limit of the filters which don't give one, if positive. MaxLimit applies
+	// to it too.
+	DefaultLimit int
+}
+
+func NewSchema() *Schema {
+	return &Schema{fields: map[string]Field{}}
+}
+
+// Returns a schema of untyped columns, named after them.
+func Columns(cols ... string) *Schema {
+	s := NewSchema()
+	for _, col := range cols {
+		s.Add(col, col, nil)
+	}
+	return s
+}
+
+// Implemented by dynamic.Object.
+type Typed interface {
+	Type() reflect.Type
+}
+
+// Returns a schema of the fields of a dynamic object (see ForType).
+func ForObject(obj Typed) *Schema {
+	return ForType(obj.Type())
+}
+
+// Returns a schema of the exported fields of struct type t, named after their
+// columns: the db tag if any, as with sqlx, or the field name in lowercase.
+func ForType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := NewSchema()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		col := strings.ToLower(f.Name)
+		if tag := strings.Split(f.Tag.Get("db"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			col = tag
+		}
+		s.Add(col, col, f.Type)
+	}
+	return s
+}
+
+// Adds a field named name. Values are coerced to typ, if not nil: numbers,
+// strings, booleans, time.Time (RFC 3339 or dates), pointers to those, and
+// sql.Scanners accepting strings, e.g. the types of package null.
+func (s *Schema) Add(name string, column string, typ reflect.Type) *Schema {
+	s.fields[name] = Field{Column: column, Type: typ}
+	return s
+}
+
+// Filter is the result of compiling query parameters.
+type Filter struct {
+	// Values are placeholders, numbered in the order the conditions are
+	// rendered in.
+	Conds []exp.Exp
+	// Values of the placeholders of Conds, in order. The arguments of a query
+	// the filter is applied to are those of the placeholders rendered before
+	// the conditions, if any, followed by Args.
+	Args   []interface{}
+	Order  *clause.OrderByClause // Nil if none.
+	Limit  int                   // Zero if none.
+	Offset int
+}
+
+// Adds the filter to r.
+func (f *Filter) Apply(r *clause.SQLRecipe) *clause.SQLRecipe {
+	conds := make([]interface{}, len(f.Conds))
+	for i, c := range f.Conds {
+		conds[i] = c
+	}
+	r.Where(conds...)
+	if f.Order != nil {
+		r.AddClause(f.Order)
+	}
+	if f.Limit > 0 {
+		r.AddClause(clause.Limit(f.Limit))
+	}
+	if f.Offset > 0 {
+		r.AddClause(clause.Offset(f.Offset))
+	}
+	return r
+}
+
+// Returns the condition, and the values of its placeholders.
+type operator func(col *exp.ColumnExp, field Field, value string) (exp.Exp, []interface{}, error)
+
+// Filter operators, by name.
+var operators = map[string]operator{
+	"eq":     compare((*exp.ColumnExp).Eq),
+	"neq":    compare((*exp.ColumnExp).NotEq),
+	"gt":     compare((*exp.ColumnExp).Gt),
+	"gte":    compare((*exp.ColumnExp).Gte),
+	"lt":     compare((*exp.ColumnExp).Lt),
+	"lte":    compare((*exp.ColumnExp).Lte),
+	"like":   match(" ~~ "),
+	"ilike":  match(" ~~* "),
+	"nlike":  match(" !~~ "),
+	"nilike": match(" !~~* "),
+	"in":     list((*exp.ColumnExp).In),
+	"nin":    list((*exp.ColumnExp).NotIn),
+	"is":     is((*exp.ColumnExp).Is),
+	"isnot":  is((*exp.ColumnExp).IsNot),
+}
+
+func compare(op func(*exp.ColumnExp, exp.Exp) *exp.BinaryExp) operator {
+	return func(col *exp.ColumnExp, field Field, value string) (exp.Exp, []interface{}, error) {
+		v, err := coerce(field, value)
+		if err != nil {
+			return nil, nil, err
+		}
+		return op(col, exp.Unbind()), []interface{}{v}, nil
+	}
+}
+
+// Patterns are strings whatever the type of the column. The LIKE methods of
+// columns only take literals, hence the operators.
+func match(op string) operator {
+	return func(col *exp.ColumnExp, field Field, value string) (exp.Exp, []interface{}, error) {
+		return exp.Binary(col, op, exp.Unbind()), []interface{}{value}, nil
+	}
+}
+
+func list(op func(*exp.ColumnExp, exp.Exp) *exp.BinaryExp) operator {
+	return func(col *exp.ColumnExp, field Field, value string) (exp.Exp, []interface{}, error) {
+		items, err := splitList(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		// One placeholder per item, as drivers don't take slices.
+		params := make([]interface{}, len(items))
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			if values[i], err = coerce(field, item); err != nil {
+				return nil, nil, err
+			}
+			params[i] = exp.Unbind()
+		}
+		return op(col, exp.Array(params...)), values, nil
+	}
+}
+
+// The operand is one of a few keywords, which are written as is.
+func is(op func(*exp.ColumnExp, *exp.LiteralExp) *exp.BinaryExp) operator {
+	return func(col *exp.ColumnExp, field Field, value string) (exp.Exp, []interface{}, error) {
+		switch strings.ToLower(value) {
+		case "null":
+			return op(col, exp.Literal(nil)), nil, nil
+		case "true":
+			return op(col, exp.Literal(true)), nil, nil
+		case "false":
+			return op(col, exp.Literal(false)), nil, nil
+		}
+		return nil, nil, fmt.Errorf("cannot compare with IS to %q", value)
+	}
+}
+
+// Compiles the filters of values: each parameter col.op=value (or col=value,
+// for equality) becomes a condition on col, plus the special parameters
+// order=col[.asc|.desc][.nullsfirst|.nullslast],..., limit and offset.
+// Values are bound to placeholders (see Filter.Args). Unknown columns and
+// operators, and values which don't fit the type of their column, are errors.
+func (s *Schema) Parse(values url.Values) (*Filter, error) {
+	res := &Filter{Limit: s.DefaultLimit}
+	// Sorted, so that the result doesn't depend on map ordering.
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range values[key] {
+			var err error
+			switch key {
+			case OrderParam:
+				res.Order, err = s.order(res.Order, value)
+			case LimitParam:
+				// LIMIT 0 would be left out, i.e. no limit at all.
+				if res.Limit, err = parseCount(key, value); err == nil && res.Limit == 0 {
+					err = fmt.Errorf("invalid %s %q", key, value)
+				}
+			case OffsetParam:
+				res.Offset, err = parseCount(key, value)
+			default:
+				var cond exp.Exp
+				var args []interface{}
+				if cond, args, err = s.cond(key, value); err == nil {
+					res.Conds = append(res.Conds, cond)
+					res.Args = append(res.Args, args...)
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if s.MaxLimit > 0 && (res.Limit == 0 || res.Limit > s.MaxLimit) {
+		res.Limit = s.MaxLimit
+	}
+	return res, nil
+}
+
+func (s *Schema) cond(key string, value string) (exp.Exp, []interface{}, error) {
+	name, opName := key, "eq"
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		name, opName = key[:i], key[i+1:]
+	}
+	field, ok := s.fields[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown filter field %q", name)
+	}
+	op, ok := operators[strings.ToLower(opName)]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown filter operator %q", opName)
+	}
+	cond, args, err := op(exp.Column(field.Column), field, value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid filter %s: %v", key, err)
+	}
+	return cond, args, nil
+}
+
+func (s *Schema) order(oc *clause.OrderByClause, value string) (*clause.OrderByClause, error) {
+	if oc == nil {
+		oc = clause.Order()
+	}
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ".")
+		field, ok := s.fields[parts[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown order field %q", parts[0])
+		}
+		// At most one direction, then at most one of the nulls modifiers.
+		var dir, nulls string
+		for _, mod := range parts[1:] {
+			switch m := strings.ToLower(mod); {
+			case nulls != "" || dir != "" && (m == "asc" || m == "desc"):
+				return nil, fmt.Errorf("invalid order %q", item)
+			case m == "asc":
+				dir = clause.ASC
+			case m == "desc":
+				dir = clause.DESC
+			case m == "nullsfirst":
+				nulls = "NULLS FIRST"
+			case m == "nullslast":
+				nulls = "NULLS LAST"
+			default:
+				return nil, fmt.Errorf("unknown order modifier %q", mod)
+			}
+		}
+		order := dir
+		if nulls != "" {
+			order = strings.TrimSpace(dir + " " + nulls)
+		}
+		oc.By(order, exp.Column(field.Column))
+	}
+	return oc, nil
+}
+
+func parseCount(key string, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return n, nil
+}
+
+// Splits a list of values, optionally in parentheses. Values containing commas
+// or parentheses can be double-quoted, with \ escaping.
+func splitList(value string) ([]string, error) {
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		value = value[1 : len(value)-1]
+	}
+	var res []string
+	var cur strings.Builder
+	quoted, inQuotes := false, false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case inQuotes && c == '\\' && i+1 < len(value):
+			i++
+			cur.WriteByte(value[i])
+		case c == '"' && (inQuotes || cur.Len() == 0 && !quoted):
+			inQuotes, quoted = !inQuotes, true
+		case c == ',' && !inQuotes:
+			res = append(res, cur.String())
+			cur.Reset()
+			quoted = false
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in %q", value)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return append(res, cur.String()), nil
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// Returns value as a value of the type of field.
+func coerce(field Field, value string) (interface{}, error) {
+	t := field.Type
+	if t == nil {
+		return value, nil
+	}
+	if reflect.PtrTo(t).Implements(scannerType) {
+		ptr := reflect.New(t)
+		if err := ptr.Interface().(sql.Scanner).Scan(value); err != nil {
+			return nil, err
+		}
+		return ptr.Elem().Interface(), nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		if res, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return res, nil
+		}
+		return time.Parse("2006-01-02", value)
+	}
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		v.SetFloat(f)
+	default:
+		return nil, fmt.Errorf("cannot filter on values of type %s", t)
+	}
+	return v.Interface(), nil
+}
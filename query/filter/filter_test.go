@@ -0,0 +1,108 @@
+package filter
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/query/clause"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+type user struct {
+	Id        int64
+	Age       *int16
+	Name      string
+	Active    bool
+	Score     float64
+	CreatedAt time.Time `db:"created_at"`
+	Secret    string    `db:"-"`
+	internal  int
+}
+
+// Stands in for a dynamic.Object.
+type object struct{}
+
+func (object) Type() reflect.Type {
+	return reflect.TypeOf(user{})
+}
+
+func build(t *testing.T, s *Schema, query string) (string, []interface{}) {
+	values, err := url.ParseQuery(query)
+	assert.NoError(t, err)
+	f, err := s.Parse(values)
+	if !assert.NoError(t, err, query) {
+		return "", nil
+	}
+	q, err := f.Apply(clause.SQL().Read(exp.All)).Select(exp.Relation("users"))
+	assert.NoError(t, err)
+	return q, f.Args
+}
+
+func TestSchema_Parse(t *testing.T) {
+	s := ForObject(object{})
+	s.MaxLimit = 100
+	expect := func(q string, args []interface{}, query string) {
+		resQ, resArgs := build(t, s, query)
+		assert.Equal(t, q, resQ, query)
+		assert.Equal(t, args, resArgs, query)
+	}
+	expect(`SELECT * FROM users WHERE ((age>=$1) AND (name ~~* $2) AND `+
+		`(name=ANY(ARRAY[$3,$4]))) ORDER BY created_at DESC,id LIMIT 20`,
+		[]interface{}{int16(18), "al%", "a", "b,c"},
+		`age.gte=18&name.ilike=al%25&name.in=a,"b,c"&order=created_at.desc,id&limit=20`)
+	expect(`SELECT * FROM users WHERE ((active=$1) AND (id<>ALL(ARRAY[$2,$3])) AND `+
+		`(score<$4)) ORDER BY name DESC NULLS LAST,age NULLS FIRST LIMIT 100 OFFSET 40`,
+		[]interface{}{true, int64(1), int64(2), 2.5},
+		`active=1&id.nin=(1,2)&score.lt=2.5&order=name.desc.nullslast,age.nullsfirst&limit=1000&offset=40`)
+	expect(`SELECT * FROM users WHERE ((age IS NULL) AND (name=ANY('{}'))) LIMIT 100`, nil,
+		`age.is=null&name.in=`)
+	// Values never make it into the query.
+	expect(`SELECT * FROM users WHERE (name !~~ $1) LIMIT 100`,
+		[]interface{}{"x'; DROP TABLE users; --"}, `name.nlike=x'%3B DROP TABLE users%3B --`)
+	expect(`SELECT * FROM users WHERE ((created_at>=$1) AND (created_at<$2)) LIMIT 100`,
+		[]interface{}{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 3, 12, 30, 0, 0, time.FixedZone("", 3600))},
+		`created_at.gte=2024-01-02&created_at.lt=2024-01-03T12:30:00%2B01:00`)
+	q, args := build(t, Columns("kind"), `kind=1`)
+	assert.Equal(t, `SELECT * FROM users WHERE (kind=$1)`, q)
+	assert.Equal(t, []interface{}{"1"}, args)
+
+	// Limits default to DefaultLimit, capped by MaxLimit like any other, and
+	// to MaxLimit without one.
+	s.DefaultLimit = 20
+	expect(`SELECT * FROM users LIMIT 20`, nil, ``)
+	expect(`SELECT * FROM users LIMIT 5`, nil, `limit=5`)
+	s.DefaultLimit = 500
+	expect(`SELECT * FROM users LIMIT 100`, nil, ``)
+	s.DefaultLimit = 0
+	expect(`SELECT * FROM users LIMIT 100`, nil, ``)
+	q, _ = build(t, Columns("kind"), ``)
+	assert.Equal(t, `SELECT * FROM users`, q)
+
+	for _, query := range []string{
+		`secret=x`,
+		`internal=1`,
+		`age.foo=1`,
+		`age=old`,
+		`age=40000`,
+		`active.is=maybe`,
+		`id.in="1`,
+		`created_at=now`,
+		`order=password`,
+		`order=id.up`,
+		`order=id.desc.desc`,
+		`order=id.asc.desc`,
+		`order=id.nullsfirst.asc`,
+		`order=id.nullsfirst.nullslast`,
+		`limit=-1`,
+		`limit=0`,
+		`offset=x`,
+	} {
+		values, err := url.ParseQuery(query)
+		assert.NoError(t, err)
+		_, err = s.Parse(values)
+		assert.Error(t, err, query)
+	}
+}
@@ -0,0 +1,148 @@
+package hook
+
+// Hooks are invoked around every query executed through DB, which is how we
+// plug logging, metrics and tracing into the execution of SQLRecipes without
+// the recipes knowing about any of them.
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"github.com/tsealex/dbutil/query"
+)
+
+type Event struct {
+	SQL  string
+	Args []interface{}
+	// Column (or tag) each placeholder index is bound to, when known. The
+	// index of $1 is 1.
+	ParamNames   map[int]string
+	Start        time.Time
+	Duration     time.Duration
+	// -1 for queries returning rows, or if the driver cannot tell.
+	RowsAffected int64
+	Err          error
+}
+
+// Returns the leading keyword of the query (e.g. "SELECT").
+func (e *Event) Operation() string {
+	q := strings.TrimLeft(e.SQL, " \t\r\n(")
+	if i := strings.IndexAny(q, " \t\r\n("); i >= 0 {
+		q = q[:i]
+	}
+	return strings.ToUpper(q)
+}
+
+type Hook interface {
+	// Called before the query is sent. The returned context is the one given
+	// to After, which allows a hook to carry state (e.g. a span) over.
+	Before(ctx context.Context, e *Event) context.Context
+	// Called once the query completes, with Duration, RowsAffected and Err set.
+	After(ctx context.Context, e *Event)
+}
+
+// Execer is implemented by *sql.DB, *sql.Conn, *sql.Tx and *sqlx.DB.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ... interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ... interface{}) (*sql.Rows, error)
+}
+
+// DB runs hooks around the queries it executes. It is an Execer itself.
+type DB struct {
+	db         Execer
+	hooks      []Hook
+	paramNames map[int]string
+}
+
+func Wrap(db Execer, hooks ... Hook) *DB {
+	return &DB{db: db, hooks: hooks}
+}
+
+// Returns a copy of d which reports the parameter names recorded in ctx, which
+// should be the context the next query was rendered into (see
+// SQLRecipe.UseContext).
+func (d *DB) WithContext(ctx *query.SQLContext) *DB {
+	res := *d
+	res.paramNames = ctx.ParamNames
+	return &res
+}
+
+func (d *DB) ExecContext(ctx context.Context, q string, args ... interface{}) (sql.Result, error) {
+	e, ctx := d.before(ctx, q, args)
+	res, err := d.db.ExecContext(ctx, q, args...)
+	if err == nil {
+		if n, err := res.RowsAffected(); err == nil {
+			e.RowsAffected = n
+		}
+	}
+	d.after(ctx, e, err)
+	return res, err
+}
+
+func (d *DB) QueryContext(ctx context.Context, q string, args ... interface{}) (*sql.Rows, error) {
+	e, ctx := d.before(ctx, q, args)
+	rows, err := d.db.QueryContext(ctx, q, args...)
+	d.after(ctx, e, err)
+	return rows, err
+}
+
+func (d *DB) before(ctx context.Context, q string, args []interface{}) (*Event, context.Context) {
+	e := &Event{
+		SQL:          q,
+		Args:         args,
+		ParamNames:   d.paramNames,
+		RowsAffected: -1,
+	}
+	for _, h := range d.hooks {
+		ctx = h.Before(ctx, e)
+	}
+	e.Start = time.Now()
+	return e, ctx
+}
+
+func (d *DB) after(ctx context.Context, e *Event, err error) {
+	e.Duration = time.Since(e.Start)
+	e.Err = err
+	for i := len(d.hooks) - 1; i >= 0; i-- {
+		d.hooks[i].After(ctx, e)
+	}
+}
+
+// Redactor masks the arguments bound to sensitive columns before they leave
+// the process. Arguments whose column is unknown, e.g. because the query was
+// not rendered into the context given to DB.WithContext, or because the
+// placeholder is not compared or assigned to a column, are masked too unless
+// AllowUnnamed is set.
+type Redactor struct {
+	// Lowercase names of the sensitive columns (or tags).
+	Columns      map[string]bool
+	Mask         interface{}
+	AllowUnnamed bool
+}
+
+func Redact(columns ... string) *Redactor {
+	r := &Redactor{Columns: map[string]bool{}, Mask: "[REDACTED]"}
+	for _, col := range columns {
+		r.Columns[strings.ToLower(col)] = true
+	}
+	return r
+}
+
+// Returns the arguments of e with the sensitive ones masked. A nil Redactor
+// masks nothing.
+func (r *Redactor) Args(e *Event) []interface{} {
+	if r == nil {
+		return e.Args
+	}
+	res := make([]interface{}, len(e.Args))
+	for i, arg := range e.Args {
+		name, ok := e.ParamNames[i+1]
+		if ok && !r.Columns[strings.ToLower(name)] || !ok && r.AllowUnnamed {
+			res[i] = arg
+		} else {
+			res[i] = r.Mask
+		}
+	}
+	return res
+}
@@ -0,0 +1,193 @@
+package hook
+
+import (
+	"testing"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/query"
+	"github.com/tsealex/dbutil/query/clause"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+type stubDB struct {
+	err error
+}
+
+func (s *stubDB) ExecContext(ctx context.Context, q string, args ... interface{}) (sql.Result, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return stubResult(3), nil
+}
+
+func (s *stubDB) QueryContext(ctx context.Context, q string, args ... interface{}) (*sql.Rows, error) {
+	return nil, s.err
+}
+
+type stubResult int64
+
+func (r stubResult) LastInsertId() (int64, error) {
+	return 0, errors.New("unsupported")
+}
+
+func (r stubResult) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+type logRecorder struct {
+	msgs   []string
+	fields []map[string]interface{}
+}
+
+func (l *logRecorder) Log(ctx context.Context, msg string, fields map[string]interface{}) {
+	l.msgs = append(l.msgs, msg)
+	l.fields = append(l.fields, fields)
+}
+
+type histRecorder map[string][]float64
+
+func (h histRecorder) WithLabelValues(lvs ... string) Observer {
+	return observerFunc(func(v float64) {
+		key := lvs[0] + "," + lvs[1]
+		h[key] = append(h[key], v)
+	})
+}
+
+type observerFunc func(float64)
+
+func (f observerFunc) Observe(v float64) {
+	f(v)
+}
+
+type spanRecorder struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *spanRecorder) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *spanRecorder) RecordError(err error) {
+	s.err = err
+}
+
+func (s *spanRecorder) End() {
+	s.ended = true
+}
+
+type tracerRecorder struct {
+	spans []*spanRecorder
+}
+
+func (t *tracerRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &spanRecorder{name: name, attrs: map[string]interface{}{}}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func TestDB_ExecContext(t *testing.T) {
+	ctx := query.NewSQLContext()
+	q, err := clause.SQL().UseContext(ctx).
+		Write(exp.Column("password"), exp.Unbind()).
+		Where(exp.Column("id").Eq(exp.TaggedUnbind("id"))).
+		Update(exp.Relation("users"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]string{1: "password", 2: "id"}, ctx.ParamNames)
+
+	logs := &logRecorder{}
+	hist := histRecorder{}
+	tracer := &tracerRecorder{}
+	redactor := Redact("Password")
+	db := Wrap(&stubDB{},
+		&LogHook{Logger: logs, Redactor: redactor},
+		&MetricsHook{Histogram: hist},
+		&TraceHook{Tracer: tracer, Redactor: redactor, WithArgs: true},
+	).WithContext(ctx)
+
+	_, err = db.ExecContext(context.Background(), q, "secret", 7)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"query"}, logs.msgs)
+	assert.Equal(t, q, logs.fields[0]["sql"])
+	assert.Equal(t, []interface{}{"[REDACTED]", 7}, logs.fields[0]["args"])
+	assert.Equal(t, int64(3), logs.fields[0]["rows"])
+
+	assert.Len(t, hist["UPDATE,ok"], 1)
+
+	assert.Len(t, tracer.spans, 1)
+	span := tracer.spans[0]
+	assert.Equal(t, "UPDATE", span.name)
+	assert.True(t, span.ended)
+	assert.Equal(t, q, span.attrs["db.statement"])
+	assert.Equal(t, "[REDACTED]", span.attrs["db.statement.args.1"])
+	assert.Equal(t, 7, span.attrs["db.statement.args.2"])
+	assert.Equal(t, int64(3), span.attrs["db.rows_affected"])
+}
+
+func TestDB_QueryContext(t *testing.T) {
+	logs := &logRecorder{}
+	hist := histRecorder{}
+	tracer := &tracerRecorder{}
+	failure := errors.New("boom")
+	db := Wrap(&stubDB{err: failure},
+		&LogHook{Logger: logs},
+		&MetricsHook{Histogram: hist},
+		&TraceHook{Tracer: tracer},
+	)
+
+	_, err := db.QueryContext(context.Background(), "select 1", 1)
+	assert.Equal(t, failure, err)
+	assert.Equal(t, []string{"query failed"}, logs.msgs)
+	assert.Equal(t, "boom", logs.fields[0]["error"])
+	assert.Equal(t, []interface{}{1}, logs.fields[0]["args"])
+	assert.Len(t, hist["SELECT,error"], 1)
+	assert.Equal(t, failure, tracer.spans[0].err)
+	assert.NotContains(t, tracer.spans[0].attrs, "db.statement.args.1")
+}
+
+func TestRedactor_Args(t *testing.T) {
+	ctx := query.NewSQLContext()
+	_, err := clause.SQL().UseContext(ctx).
+		Where(exp.Column("id").In(exp.Unbind()),
+			exp.Column("email").Eq(exp.Func("lower", exp.Unbind())),
+			exp.Func("length", exp.Column("name")).Gt(exp.Unbind())).
+		Read("id").Select("users")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]string{1: "id", 2: "email"}, ctx.ParamNames)
+
+	r := Redact("email")
+	e := &Event{Args: []interface{}{1, "a@b.c", 3}, ParamNames: ctx.ParamNames}
+	assert.Equal(t, []interface{}{1, "[REDACTED]", "[REDACTED]"}, r.Args(e))
+
+	// Without names, everything is masked unless told otherwise.
+	e.ParamNames = nil
+	assert.Equal(t, []interface{}{"[REDACTED]", "[REDACTED]", "[REDACTED]"}, r.Args(e))
+	r.AllowUnnamed = true
+	assert.Equal(t, []interface{}{1, "a@b.c", 3}, r.Args(e))
+}
+
+func TestTraceHook_Nested(t *testing.T) {
+	outer := &tracerRecorder{}
+	inner := &tracerRecorder{}
+	db := Wrap(&stubDB{}, &TraceHook{Tracer: outer}, &TraceHook{Tracer: inner})
+	_, err := db.ExecContext(context.Background(), "delete from t")
+	assert.NoError(t, err)
+	assert.True(t, outer.spans[0].ended)
+	assert.True(t, inner.spans[0].ended)
+	assert.Equal(t, int64(3), outer.spans[0].attrs["db.rows_affected"])
+}
+
+func TestHistogramFunc(t *testing.T) {
+	hist := histRecorder{}
+	db := Wrap(&stubDB{}, &MetricsHook{Histogram: HistogramFunc(func(lvs ... string) Observer {
+		return hist.WithLabelValues(lvs...)
+	})})
+	_, err := db.ExecContext(context.Background(), "delete from t")
+	assert.NoError(t, err)
+	assert.Len(t, hist["DELETE,ok"], 1)
+}
@@ -0,0 +1,131 @@
+package hook
+
+import (
+	"context"
+	"strconv"
+)
+
+// Logger receives one structured record per query.
+type Logger interface {
+	Log(ctx context.Context, msg string, fields map[string]interface{})
+}
+
+type LogHook struct {
+	Logger   Logger
+	Redactor *Redactor
+}
+
+func (h *LogHook) Before(ctx context.Context, e *Event) context.Context {
+	return ctx
+}
+
+func (h *LogHook) After(ctx context.Context, e *Event) {
+	fields := map[string]interface{}{
+		"sql":      e.SQL,
+		"args":     h.Redactor.Args(e),
+		"duration": e.Duration,
+		"rows":     e.RowsAffected,
+	}
+	msg := "query"
+	if e.Err != nil {
+		fields["error"] = e.Err.Error()
+		msg = "query failed"
+	}
+	h.Logger.Log(ctx, msg, fields)
+}
+
+// Observer and HistogramVec are the subset of the Prometheus client API used
+// by MetricsHook. Since WithLabelValues of *prometheus.HistogramVec returns a
+// prometheus.Observer, it has to be adapted through HistogramFunc:
+//
+//	HistogramFunc(func(lvs ... string) Observer {
+//		return vec.WithLabelValues(lvs...)
+//	})
+type Observer interface {
+	Observe(float64)
+}
+
+type HistogramVec interface {
+	WithLabelValues(lvs ... string) Observer
+}
+
+type HistogramFunc func(lvs ... string) Observer
+
+var _ HistogramVec = HistogramFunc(nil)
+
+func (f HistogramFunc) WithLabelValues(lvs ... string) Observer {
+	return f(lvs...)
+}
+
+// MetricsHook observes query durations in seconds, labelled by operation
+// (e.g. "SELECT") and status ("ok" or "error").
+type MetricsHook struct {
+	Histogram HistogramVec
+}
+
+func (h *MetricsHook) Before(ctx context.Context, e *Event) context.Context {
+	return ctx
+}
+
+func (h *MetricsHook) After(ctx context.Context, e *Event) {
+	status := "ok"
+	if e.Err != nil {
+		status = "error"
+	}
+	h.Histogram.WithLabelValues(e.Operation(), status).Observe(e.Duration.Seconds())
+}
+
+// Tracer and Span mirror the parts of the OpenTelemetry tracing API used by
+// TraceHook, so that an OpenTelemetry tracer can be plugged in through a thin
+// adapter without this package depending on it.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// TraceHook starts a span per query, with attributes named after the
+// OpenTelemetry database semantic conventions.
+type TraceHook struct {
+	Tracer   Tracer
+	Redactor *Redactor
+	// Whether to record the arguments as db.statement.args.<n> attributes.
+	WithArgs bool
+}
+
+// Keyed by hook, so that several TraceHooks on the same DB keep their own span.
+type spanKey struct {
+	h *TraceHook
+}
+
+func (h *TraceHook) Before(ctx context.Context, e *Event) context.Context {
+	op := e.Operation()
+	ctx, span := h.Tracer.Start(ctx, op)
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.operation", op)
+	span.SetAttribute("db.statement", e.SQL)
+	if h.WithArgs {
+		for i, arg := range h.Redactor.Args(e) {
+			span.SetAttribute("db.statement.args."+strconv.Itoa(i+1), arg)
+		}
+	}
+	return context.WithValue(ctx, spanKey{h}, span)
+}
+
+func (h *TraceHook) After(ctx context.Context, e *Event) {
+	span, ok := ctx.Value(spanKey{h}).(Span)
+	if !ok {
+		return
+	}
+	if e.RowsAffected >= 0 {
+		span.SetAttribute("db.rows_affected", e.RowsAffected)
+	}
+	if e.Err != nil {
+		span.RecordError(e.Err)
+	}
+	span.End()
+}
@@ -0,0 +1,259 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind uint8
+
+const (
+	tokEOF    tokenKind = iota
+	tokIdent  tokenKind = iota // Unquoted identifiers and keywords.
+	tokQuoted tokenKind = iota // "Quoted" identifiers.
+	tokNumber tokenKind = iota
+	tokString tokenKind = iota
+	tokParam  tokenKind = iota // $n
+	tokOp     tokenKind = iota // Symbolic operators.
+	tokPunct  tokenKind = iota // ( ) [ ] , ; . ::
+)
+
+type token struct {
+	kind tokenKind
+	// Unescaped content for strings and quoted identifiers, the text as is
+	// otherwise.
+	text string
+	pos  int
+}
+
+// Whether t is the given keyword, case-insensitively.
+func (t token) is(keyword string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (t token) isPunct(p string) bool {
+	return t.kind == tokPunct && t.text == p
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return strconv.Quote(t.text)
+}
+
+const opChars = "+-*/<>=~!@#%^&|`?"
+
+func lex(src string) ([]token, error) {
+	var res []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			depth := 0
+			for ; i < len(src); i++ {
+				if strings.HasPrefix(src[i:], "/*") {
+					depth++
+					i++
+				} else if strings.HasPrefix(src[i:], "*/") {
+					depth--
+					i++
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+			if depth > 0 {
+				return nil, fmt.Errorf("unterminated comment at %d", start)
+			}
+		case (c == 'E' || c == 'e') && i+1 < len(src) && src[i+1] == '\'':
+			s, end, err := lexString(src, i+1, true)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, token{tokString, s, start})
+			i = end
+		case c == '\'':
+			s, end, err := lexString(src, i, false)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, token{tokString, s, start})
+			i = end
+		case c == '"':
+			var b strings.Builder
+			for i++; ; i++ {
+				if i >= len(src) {
+					return nil, fmt.Errorf("unterminated quoted identifier at %d", start)
+				} else if src[i] != '"' {
+					b.WriteByte(src[i])
+				} else if i+1 < len(src) && src[i+1] == '"' {
+					b.WriteByte('"')
+					i++
+				} else {
+					i++
+					break
+				}
+			}
+			if b.Len() == 0 {
+				return nil, fmt.Errorf("zero-length quoted identifier at %d", start)
+			}
+			res = append(res, token{tokQuoted, b.String(), start})
+		case c == '$':
+			i++
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i > start+1 {
+				res = append(res, token{tokParam, src[start+1 : i], start})
+				break
+			}
+			// Dollar-quoted string.
+			for i < len(src) && isIdentChar(src[i]) && src[i] != '$' {
+				i++
+			}
+			if i >= len(src) || src[i] != '$' {
+				return nil, fmt.Errorf("unexpected '$' at %d", start)
+			}
+			tag := src[start : i+1]
+			end := strings.Index(src[i+1:], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated dollar-quoted string at %d", start)
+			}
+			res = append(res, token{tokString, src[i+1 : i+1+end], start})
+			i += 1 + end + len(tag)
+		case isDigit(c) || c == '.' && i+1 < len(src) && isDigit(src[i+1]):
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i < len(src) && src[i] == '.' {
+				for i++; i < len(src) && isDigit(src[i]); i++ {
+				}
+			}
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && isDigit(src[j]) {
+					for i = j; i < len(src) && isDigit(src[i]); i++ {
+					}
+				}
+			}
+			res = append(res, token{tokNumber, src[start:i], start})
+		case isIdentStart(c):
+			for i < len(src) && isIdentChar(src[i]) {
+				i++
+			}
+			res = append(res, token{tokIdent, src[start:i], start})
+		case c == ':' && i+1 < len(src) && src[i+1] == ':':
+			i += 2
+			res = append(res, token{tokPunct, "::", start})
+		case strings.IndexByte("()[],;.", c) >= 0:
+			i++
+			res = append(res, token{tokPunct, string(c), start})
+		case strings.IndexByte(opChars, c) >= 0:
+			for i < len(src) && strings.IndexByte(opChars, src[i]) >= 0 &&
+				!strings.HasPrefix(src[i:], "--") && !strings.HasPrefix(src[i:], "/*") {
+				i++
+			}
+			op := src[start:i]
+			// Like Postgres, a multi-character operator cannot end in + or -
+			// unless it contains one of ~ ! @ # % ^ & | ` ?, so that e.g. a=-1
+			// reads as a = -1.
+			for len(op) > 1 && (op[len(op)-1] == '+' || op[len(op)-1] == '-') &&
+				strings.IndexAny(op, "~!@#%^&|`?") < 0 {
+				op = op[:len(op)-1]
+			}
+			i = start + len(op)
+			res = append(res, token{tokOp, op, start})
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, start)
+		}
+	}
+	return append(res, token{tokEOF, "", len(src)}), nil
+}
+
+// Lexes the string starting with the quote at src[i]. Returns its content and
+// the index right after it.
+func lexString(src string, i int, escaped bool) (string, int, error) {
+	start := i
+	var b strings.Builder
+	for i++; i < len(src); i++ {
+		c := src[i]
+		if c == '\'' {
+			if i+1 < len(src) && src[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		} else if c != '\\' || !escaped {
+			b.WriteByte(c)
+			continue
+		}
+		if i++; i >= len(src) {
+			break
+		}
+		switch c = src[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'x':
+			j := i + 1
+			for j < len(src) && j < i+3 && isHex(src[j]) {
+				j++
+			}
+			if j == i+1 {
+				b.WriteByte(c)
+				break
+			}
+			n, _ := strconv.ParseUint(src[i+1:j], 16, 8)
+			b.WriteByte(byte(n))
+			i = j - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < len(src) && j < i+3 && src[j] >= '0' && src[j] <= '7' {
+				j++
+			}
+			n, _ := strconv.ParseUint(src[i:j], 8, 8)
+			b.WriteByte(byte(n))
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string at %d", start)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
@@ -0,0 +1,1037 @@
+package parse
+
+// Parses the subset of Postgres SQL the clause package emits back into
+// recipes and expression trees, so that hand-written queries can be moved onto
+// SQLRecipe. Anything outside of that subset (joins, subqueries, CASE, LIMIT,
+// ...) is reported as an error rather than approximated.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"github.com/tsealex/dbutil/query"
+	"github.com/tsealex/dbutil/query/clause"
+	"github.com/tsealex/dbutil/query/exp"
+)
+
+type parser struct {
+	toks   []token
+	pos    int
+	params map[string]int
+}
+
+func newParser(src string) (*parser, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{toks: toks, params: map[string]int{}}, nil
+}
+
+// Parses a single SELECT, INSERT, UPDATE or DELETE statement. Placeholders
+// become TaggedUnbinds bound to their original index through the statement's
+// Params, so that the rebuilt query takes the same arguments.
+func Parse(src string) (*clause.Statement, error) {
+	p, err := newParser(src)
+	if err != nil {
+		return nil, err
+	}
+	var stmt *clause.Statement
+	t := p.peek()
+	switch {
+	case t.is("SELECT"):
+		stmt, err = p.selectStmt()
+	case t.is("INSERT"):
+		stmt, err = p.insertStmt()
+	case t.is("UPDATE"):
+		stmt, err = p.updateStmt()
+	case t.is("DELETE"):
+		stmt, err = p.deleteStmt()
+	default:
+		return nil, p.unexpected("SELECT, INSERT, UPDATE or DELETE")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().isPunct(";") {
+		p.next()
+	}
+	if err = p.end(); err != nil {
+		return nil, err
+	}
+	stmt.Params = p.params
+	return stmt, nil
+}
+
+// Parses a single expression. Placeholder $n becomes TaggedUnbind("$n").
+func ParseExp(src string) (exp.Exp, error) {
+	p, err := newParser(src)
+	if err != nil {
+		return nil, err
+	}
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return e, p.end()
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if i := p.pos + offset; i < len(p.toks) {
+		return p.toks[i]
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// Consumes the given keywords if they come next.
+func (p *parser) accept(keywords ... string) bool {
+	for i, kw := range keywords {
+		if !p.peekAt(i).is(kw) {
+			return false
+		}
+	}
+	p.pos += len(keywords)
+	return true
+}
+
+func (p *parser) expect(keywords ... string) error {
+	if !p.accept(keywords...) {
+		return p.unexpected(strings.Join(keywords, " "))
+	}
+	return nil
+}
+
+func (p *parser) acceptPunct(punct string) bool {
+	if p.peek().isPunct(punct) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectPunct(punct string) error {
+	if !p.acceptPunct(punct) {
+		return p.unexpected(strconv.Quote(punct))
+	}
+	return nil
+}
+
+func (p *parser) end() error {
+	if p.peek().kind != tokEOF {
+		return p.unexpected("end of input")
+	}
+	return nil
+}
+
+func (p *parser) unexpected(expected string) error {
+	t := p.peek()
+	return fmt.Errorf("expected %s at %d, got %s", expected, t.pos, t)
+}
+
+////////
+// Statements
+
+func (p *parser) selectStmt() (*clause.Statement, error) {
+	p.next()
+	if p.peek().is("DISTINCT") {
+		return nil, fmt.Errorf("DISTINCT is not supported")
+	}
+	stmt := &clause.Statement{Kind: clause.SelectStmt, Recipe: clause.SQL()}
+	items, err := p.selectItems()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Recipe.Read(items...)
+	if p.accept("FROM") {
+		for {
+			tb, err := p.tableRef()
+			if err != nil {
+				return nil, err
+			} else if p.peek().isPunct("(") {
+				return nil, fmt.Errorf("set-returning functions are not supported")
+			}
+			stmt.Tables = append(stmt.Tables, tb)
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+		if t := p.peek(); t.is("JOIN") || t.is("INNER") || t.is("LEFT") ||
+			t.is("RIGHT") || t.is("FULL") || t.is("CROSS") || t.is("NATURAL") {
+			return nil, fmt.Errorf("joins are not supported")
+		}
+	}
+	if err = p.where(stmt.Recipe); err != nil {
+		return nil, err
+	}
+	if p.accept("GROUP", "BY") {
+		exps, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		cols := make([]interface{}, len(exps))
+		for i, e := range exps {
+			cols[i] = e
+		}
+		stmt.Recipe.AddClause(clause.GroupBy(cols...))
+	}
+	if p.accept("HAVING") {
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Recipe.AddClause(clause.Having(cond))
+	}
+	if p.accept("ORDER", "BY") {
+		order := clause.Order()
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			var dir []string
+			if p.accept("ASC") {
+				dir = append(dir, clause.ASC)
+			} else if p.accept("DESC") {
+				dir = append(dir, clause.DESC)
+			}
+			if p.accept("NULLS", "FIRST") {
+				dir = append(dir, "NULLS FIRST")
+			} else if p.accept("NULLS", "LAST") {
+				dir = append(dir, "NULLS LAST")
+			}
+			order.By(strings.Join(dir, " "), e)
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+		stmt.Recipe.AddClause(order)
+	}
+	return stmt, nil
+}
+
+func (p *parser) insertStmt() (*clause.Statement, error) {
+	p.next()
+	if err := p.expect("INTO"); err != nil {
+		return nil, err
+	}
+	stmt := &clause.Statement{Kind: clause.InsertStmt, Recipe: clause.SQL()}
+	tb, err := p.tableRef()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Tables = []exp.Exp{tb}
+	if err = p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	var cols []*exp.ColumnExp
+	for {
+		col, err := p.columnName()
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+		if !p.acceptPunct(",") {
+			break
+		}
+	}
+	if err = p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	if err = p.expect("VALUES"); err != nil {
+		return nil, err
+	}
+	if err = p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	vals, err := p.exprList()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	if p.peek().isPunct(",") {
+		return nil, fmt.Errorf("inserting multiple rows is not supported")
+	} else if len(vals) != len(cols) {
+		return nil, fmt.Errorf("%d columns but %d values", len(cols), len(vals))
+	}
+	for i, col := range cols {
+		stmt.Recipe.Write(col, vals[i])
+	}
+	if p.accept("ON", "CONFLICT") {
+		if err = p.onConflict(stmt.Recipe); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, p.returning(stmt.Recipe)
+}
+
+func (p *parser) onConflict(r *clause.SQLRecipe) error {
+	var cols []interface{}
+	if p.acceptPunct("(") {
+		exps, err := p.exprList()
+		if err != nil {
+			return err
+		}
+		for _, e := range exps {
+			cols = append(cols, e)
+		}
+		if err = p.expectPunct(")"); err != nil {
+			return err
+		}
+	}
+	oc := clause.OnConflict(cols...)
+	r.AddClause(oc)
+	if err := p.expect("DO"); err != nil {
+		return err
+	}
+	if p.accept("NOTHING") {
+		oc.DoNothing()
+		return nil
+	}
+	if err := p.expect("UPDATE", "SET"); err != nil {
+		return err
+	}
+	return p.assignments(func(col *exp.ColumnExp, val exp.Exp) {
+		oc.Write(col, val)
+	})
+}
+
+func (p *parser) updateStmt() (*clause.Statement, error) {
+	p.next()
+	stmt := &clause.Statement{Kind: clause.UpdateStmt, Recipe: clause.SQL()}
+	tb, err := p.tableRef()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Tables = []exp.Exp{tb}
+	if err = p.expect("SET"); err != nil {
+		return nil, err
+	}
+	if err = p.assignments(func(col *exp.ColumnExp, val exp.Exp) {
+		stmt.Recipe.Write(col, val)
+	}); err != nil {
+		return nil, err
+	}
+	if err = p.where(stmt.Recipe); err != nil {
+		return nil, err
+	}
+	return stmt, p.returning(stmt.Recipe)
+}
+
+func (p *parser) deleteStmt() (*clause.Statement, error) {
+	p.next()
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	stmt := &clause.Statement{Kind: clause.DeleteStmt, Recipe: clause.SQL()}
+	tb, err := p.tableRef()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Tables = []exp.Exp{tb}
+	if err = p.where(stmt.Recipe); err != nil {
+		return nil, err
+	}
+	return stmt, p.returning(stmt.Recipe)
+}
+
+func (p *parser) where(r *clause.SQLRecipe) error {
+	if !p.accept("WHERE") {
+		return nil
+	}
+	cond, err := p.expr()
+	if err != nil {
+		return err
+	}
+	r.Where(cond)
+	return nil
+}
+
+func (p *parser) returning(r *clause.SQLRecipe) error {
+	if !p.accept("RETURNING") {
+		return nil
+	}
+	items, err := p.selectItems()
+	if err != nil {
+		return err
+	}
+	r.Read(items...)
+	return nil
+}
+
+func (p *parser) assignments(write func(*exp.ColumnExp, exp.Exp)) error {
+	for {
+		col, err := p.columnName()
+		if err != nil {
+			return err
+		}
+		if t := p.next(); t.kind != tokOp || t.text != "=" {
+			p.pos--
+			return p.unexpected(`"="`)
+		}
+		val, err := p.expr()
+		if err != nil {
+			return err
+		}
+		write(col, val)
+		if !p.acceptPunct(",") {
+			return nil
+		}
+	}
+}
+
+// Parses expressions with optional aliases, as found after SELECT and
+// RETURNING.
+func (p *parser) selectItems() ([]interface{}, error) {
+	var items []interface{}
+	for {
+		var item exp.Exp
+		if t := p.peek(); t.kind == tokOp && t.text == "*" {
+			p.next()
+			item = exp.All
+		} else {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			item = e
+			if alias, ok, err := p.alias(); err != nil {
+				return nil, err
+			} else if ok {
+				item = exp.As(alias, e)
+			}
+		}
+		items = append(items, item)
+		if !p.acceptPunct(",") {
+			return items, nil
+		}
+	}
+}
+
+// Unreserved keywords which cannot be taken for an alias without AS where we
+// use them.
+var clauseKeywords = map[string]bool{"SET": true, "VALUES": true, "NULLS": true}
+
+func (p *parser) alias() (string, bool, error) {
+	explicit := p.accept("AS")
+	t := p.peek()
+	if t.kind == tokQuoted || t.kind == tokIdent && !exp.IsReserved(t.text) &&
+		(explicit || !clauseKeywords[strings.ToUpper(t.text)]) {
+		p.next()
+		name, _ := identName(t)
+		return name, true, nil
+	} else if explicit {
+		return "", false, p.unexpected("alias")
+	}
+	return "", false, nil
+}
+
+func (p *parser) tableRef() (exp.Exp, error) {
+	names, err := p.qualifiedName()
+	if err != nil {
+		return nil, err
+	}
+	var rel *exp.RelationExp
+	switch len(names) {
+	case 1:
+		rel = relation(names[0])
+	case 2:
+		rel = relation(names[1]).SetSchema(schema(names[0]))
+	default:
+		return nil, fmt.Errorf("invalid relation name %s", joinNames(names))
+	}
+	if alias, ok, err := p.alias(); err != nil {
+		return nil, err
+	} else if ok {
+		return exp.As(alias, rel), nil
+	}
+	return rel, nil
+}
+
+func (p *parser) columnName() (*exp.ColumnExp, error) {
+	t := p.peek()
+	if t.kind != tokQuoted && (t.kind != tokIdent || exp.IsReserved(t.text)) {
+		return nil, p.unexpected("column name")
+	}
+	p.next()
+	name, quoted := identName(t)
+	col := exp.Column(name)
+	col.Quoted = quoted
+	return col, nil
+}
+
+////////
+// Expressions, from the loosest binding operators to the tightest ones.
+
+func (p *parser) expr() (exp.Exp, error) {
+	return p.or()
+}
+
+func (p *parser) exprList() ([]exp.Exp, error) {
+	var res []exp.Exp
+	for {
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+		if !p.acceptPunct(",") {
+			return res, nil
+		}
+	}
+}
+
+func (p *parser) or() (exp.Exp, error) {
+	e, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	exps := []exp.Exp{e}
+	for p.accept("OR") {
+		if e, err = p.and(); err != nil {
+			return nil, err
+		}
+		exps = append(exps, e)
+	}
+	if len(exps) == 1 {
+		return exps[0], nil
+	}
+	return exp.Or(exps...), nil
+}
+
+func (p *parser) and() (exp.Exp, error) {
+	e, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	exps := []exp.Exp{e}
+	for p.accept("AND") {
+		if e, err = p.not(); err != nil {
+			return nil, err
+		}
+		exps = append(exps, e)
+	}
+	if len(exps) == 1 {
+		return exps[0], nil
+	}
+	return exp.And(exps...), nil
+}
+
+func (p *parser) not() (exp.Exp, error) {
+	if p.accept("NOT") {
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return exp.Not(e), nil
+	}
+	return p.is()
+}
+
+func (p *parser) is() (exp.Exp, error) {
+	e, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.accept("ISNULL"):
+			e = exp.Binary(e, " IS ", exp.Literal(nil))
+		case p.accept("NOTNULL"):
+			e = exp.Binary(e, " IS NOT ", exp.Literal(nil))
+		case p.accept("IS"):
+			op := " IS "
+			if p.accept("NOT") {
+				op = " IS NOT "
+			}
+			var val interface{}
+			switch {
+			case p.accept("NULL"):
+			case p.accept("TRUE"):
+				val = true
+			case p.accept("FALSE"):
+				val = false
+			default:
+				return nil, p.unexpected("NULL, TRUE or FALSE")
+			}
+			e = exp.Binary(e, op, exp.Literal(val))
+		default:
+			return e, nil
+		}
+	}
+}
+
+var comparisonOps = map[string]string{
+	"=": "=", "<>": "<>", "!=": "<>", "<": "<", ">": ">", "<=": "<=", ">=": ">=",
+}
+
+func (p *parser) comparison() (exp.Exp, error) {
+	e, err := p.like()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokOp {
+		if op, ok := comparisonOps[t.text]; ok {
+			p.next()
+			right, err := p.like()
+			if err != nil {
+				return nil, err
+			}
+			return exp.Binary(e, op, right), nil
+		}
+	}
+	return e, nil
+}
+
+func (p *parser) like() (exp.Exp, error) {
+	e, err := p.other()
+	if err != nil {
+		return nil, err
+	}
+	var op string
+	switch {
+	case p.accept("LIKE"):
+		op = " ~~ "
+	case p.accept("ILIKE"):
+		op = " ~~* "
+	case p.accept("NOT", "LIKE"):
+		op = " !~~ "
+	case p.accept("NOT", "ILIKE"):
+		op = " !~~* "
+	case p.accept("SIMILAR", "TO"):
+		op = " SIMILAR TO "
+	case p.peek().is("IN") || p.peek().is("BETWEEN") ||
+		p.peek().is("NOT") && (p.peekAt(1).is("IN") || p.peekAt(1).is("BETWEEN")):
+		return nil, fmt.Errorf("IN and BETWEEN are not supported")
+	default:
+		return e, nil
+	}
+	right, err := p.other()
+	if err != nil {
+		return nil, err
+	}
+	return exp.Binary(e, op, right), nil
+}
+
+// Operators rendered with surrounding spaces by the exp package.
+var spacedOps = map[string]bool{"~~": true, "~~*": true, "!~~": true, "!~~*": true}
+
+func (p *parser) other() (exp.Exp, error) {
+	e, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokOp {
+			return e, nil
+		}
+		switch t.text {
+		case "+", "-", "*", "/", "%", "^", "=", "<>", "!=", "<", ">", "<=", ">=":
+			return e, nil
+		}
+		p.next()
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		op := t.text
+		if spacedOps[op] {
+			op = " " + op + " "
+		}
+		e = exp.Binary(e, op, right)
+	}
+}
+
+func (p *parser) additive() (exp.Exp, error) {
+	return p.binary(p.multiplicative, "+", "-")
+}
+
+func (p *parser) multiplicative() (exp.Exp, error) {
+	return p.binary(p.exponent, "*", "/", "%")
+}
+
+func (p *parser) exponent() (exp.Exp, error) {
+	return p.binary(p.unary, "^")
+}
+
+// Parses left-associative operations of the given operators.
+func (p *parser) binary(operand func() (exp.Exp, error), ops ... string) (exp.Exp, error) {
+	e, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		found := false
+		for _, op := range ops {
+			found = found || t.kind == tokOp && t.text == op
+		}
+		if !found {
+			return e, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		e = exp.Binary(e, t.text, right)
+	}
+}
+
+func (p *parser) unary() (exp.Exp, error) {
+	if t := p.peek(); t.kind == tokOp && (t.text == "-" || t.text == "+") {
+		p.next()
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if t.text == "+" {
+			return e, nil
+		}
+		return exp.Minus(e), nil
+	}
+	return p.cast()
+}
+
+func (p *parser) cast() (exp.Exp, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptPunct("::") {
+		typeName, err := p.typeName()
+		if err != nil {
+			return nil, err
+		}
+		e = exp.Cast(typeName, e)
+	}
+	return e, nil
+}
+
+// Keywords standing for values on their own.
+var valueKeywords = map[string]bool{
+	"CURRENT_DATE": true, "CURRENT_TIME": true, "CURRENT_TIMESTAMP": true,
+	"LOCALTIME": true, "LOCALTIMESTAMP": true, "CURRENT_USER": true,
+	"SESSION_USER": true, "CURRENT_ROLE": true, "CURRENT_CATALOG": true,
+	"CURRENT_SCHEMA": true, "USER": true,
+}
+
+func (p *parser) primary() (exp.Exp, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokNumber:
+		p.next()
+		return number(t.text)
+	case tokString:
+		p.next()
+		return exp.Literal(t.text), nil
+	case tokParam:
+		p.next()
+		n, err := strconv.Atoi(t.text)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid placeholder $%s at %d", t.text, t.pos)
+		}
+		tag := "$" + t.text
+		p.params[tag] = n
+		return exp.TaggedUnbind(tag), nil
+	case tokPunct:
+		if !t.isPunct("(") {
+			break
+		}
+		p.next()
+		if p.peek().is("SELECT") {
+			return nil, fmt.Errorf("subqueries are not supported")
+		}
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expectPunct(")")
+	case tokIdent:
+		upper := strings.ToUpper(t.text)
+		switch {
+		case upper == "NULL":
+			p.next()
+			return exp.Literal(nil), nil
+		case upper == "TRUE" || upper == "FALSE":
+			p.next()
+			return exp.Literal(upper == "TRUE"), nil
+		case valueKeywords[upper] && !p.peekAt(1).isPunct("("):
+			p.next()
+			return exp.Expression(upper), nil
+		case upper == "ARRAY":
+			return p.array()
+		case upper == "CAST" && p.peekAt(1).isPunct("("):
+			p.pos += 2
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("AS"); err != nil {
+				return nil, err
+			}
+			typeName, err := p.typeName()
+			if err != nil {
+				return nil, err
+			}
+			return exp.Cast(typeName, e), p.expectPunct(")")
+		case upper == "CASE" || upper == "EXISTS":
+			return nil, fmt.Errorf("%s is not supported", upper)
+		}
+		return p.name()
+	case tokQuoted:
+		return p.name()
+	}
+	return nil, p.unexpected("expression")
+}
+
+func (p *parser) array() (exp.Exp, error) {
+	p.next()
+	if p.peek().isPunct("(") {
+		return nil, fmt.Errorf("array subqueries are not supported")
+	}
+	if err := p.expectPunct("["); err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	if !p.acceptPunct("]") {
+		exps, err := p.exprList()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range exps {
+			values = append(values, e)
+		}
+		if err = p.expectPunct("]"); err != nil {
+			return nil, err
+		}
+	}
+	return exp.Array(values...), nil
+}
+
+// Parses a column reference, which may be qualified or end with .*, or a
+// function call.
+func (p *parser) name() (exp.Exp, error) {
+	first := p.peek()
+	names, err := p.qualifiedName()
+	if err != nil {
+		return nil, err
+	}
+	if p.acceptPunct("(") {
+		return p.call(names)
+	}
+	if first.kind == tokIdent && exp.IsReserved(first.text) && len(names) == 1 {
+		p.pos--
+		return nil, p.unexpected("expression")
+	}
+	var col *exp.ColumnExp
+	last := names[len(names)-1]
+	if p.peek().isPunct(".") && p.peekAt(1).kind == tokOp && p.peekAt(1).text == "*" {
+		p.pos += 2
+		col = exp.Column("*")
+		names = append(names, token{})
+	} else {
+		col = column(last)
+	}
+	switch len(names) {
+	case 1:
+	case 2:
+		col.SetRelation(relation(names[0]))
+	case 3:
+		col.SetRelation(relation(names[1]).SetSchema(schema(names[0])))
+	default:
+		return nil, fmt.Errorf("invalid column name %s", joinNames(names))
+	}
+	return col, nil
+}
+
+func (p *parser) call(names []token) (exp.Exp, error) {
+	parts := make([]string, len(names))
+	for i, t := range names {
+		if name, quoted := identName(t); quoted {
+			parts[i] = exp.QuoteIdent(name, query.Postgres)
+		} else {
+			parts[i] = name
+		}
+	}
+	fn := strings.Join(parts, ".")
+	var args []exp.Exp
+	if t := p.peek(); t.kind == tokOp && t.text == "*" {
+		p.next()
+		args = []exp.Exp{exp.All}
+	} else if p.peek().is("DISTINCT") {
+		return nil, fmt.Errorf("DISTINCT is not supported")
+	} else if !p.peek().isPunct(")") {
+		var err error
+		if args, err = p.exprList(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	return exp.Func(fn, args...), nil
+}
+
+// Parses dot-separated identifiers, leaving a trailing .* alone.
+func (p *parser) qualifiedName() ([]token, error) {
+	var names []token
+	for {
+		t := p.peek()
+		if t.kind != tokIdent && t.kind != tokQuoted {
+			return nil, p.unexpected("name")
+		}
+		names = append(names, p.next())
+		if !p.peek().isPunct(".") || p.peekAt(1).kind != tokIdent && p.peekAt(1).kind != tokQuoted {
+			return names, nil
+		}
+		p.next()
+	}
+}
+
+// Multi-word type names, keyed by their first word.
+var typeSuffixes = map[string][][]string{
+	"DOUBLE":    {{"PRECISION"}},
+	"CHARACTER": {{"VARYING"}},
+	"BIT":       {{"VARYING"}},
+	"TIMESTAMP": {{"WITH", "TIME", "ZONE"}, {"WITHOUT", "TIME", "ZONE"}},
+	"TIME":      {{"WITH", "TIME", "ZONE"}, {"WITHOUT", "TIME", "ZONE"}},
+}
+
+// Parses a type name, e.g. int, numeric(10,2), timestamp with time zone or
+// text[].
+func (p *parser) typeName() (string, error) {
+	names, err := p.qualifiedName()
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(names))
+	for i, t := range names {
+		if name, quoted := identName(t); quoted {
+			parts[i] = exp.QuoteIdent(name, query.Postgres)
+		} else {
+			parts[i] = name
+		}
+	}
+	res := strings.Join(parts, ".")
+	first := strings.ToUpper(names[0].text)
+	precision := func() error {
+		if !p.acceptPunct("(") {
+			return nil
+		}
+		var mods []string
+		for {
+			t := p.next()
+			if t.kind != tokNumber {
+				p.pos--
+				return p.unexpected("type modifier")
+			}
+			mods = append(mods, t.text)
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+		res += "(" + strings.Join(mods, ",") + ")"
+		return p.expectPunct(")")
+	}
+	if len(names) == 1 {
+		if err = precision(); err != nil {
+			return "", err
+		}
+		for _, suffix := range typeSuffixes[first] {
+			if p.accept(suffix...) {
+				res += " " + strings.ToLower(strings.Join(suffix, " "))
+				if err = precision(); err != nil {
+					return "", err
+				}
+				break
+			}
+		}
+	}
+	for p.acceptPunct("[") {
+		if err = p.expectPunct("]"); err != nil {
+			return "", err
+		}
+		res += "[]"
+	}
+	return res, nil
+}
+
+////////
+// Helpers
+
+// Unquoted identifiers are folded to lowercase, as Postgres does.
+func identName(t token) (string, bool) {
+	if t.kind == tokQuoted {
+		return t.text, true
+	}
+	return strings.ToLower(t.text), false
+}
+
+func column(t token) *exp.ColumnExp {
+	name, quoted := identName(t)
+	col := exp.Column(name)
+	col.Quoted = quoted
+	return col
+}
+
+func relation(t token) *exp.RelationExp {
+	name, quoted := identName(t)
+	rel := exp.Relation(name)
+	rel.Quoted = quoted
+	return rel
+}
+
+func schema(t token) *exp.SchemaExp {
+	name, quoted := identName(t)
+	s := exp.Schema(name)
+	s.Quoted = quoted
+	return s
+}
+
+func joinNames(names []token) string {
+	parts := make([]string, len(names))
+	for i, t := range names {
+		parts[i] = t.text
+	}
+	return strings.Join(parts, ".")
+}
+
+// Integers become int64 literals and other numbers float64 ones, unless that
+// would lose precision, in which case the number is kept as written.
+func number(text string) (exp.Exp, error) {
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return exp.Literal(i), nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil && !strings.ContainsAny(text, ".eE") {
+		// An integer too large for int64.
+		return exp.Expression(text), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid number %s", text)
+	}
+	digits := strings.TrimLeft(strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, strings.SplitN(strings.ToLower(text), "e", 2)[0]), "0")
+	if len(strings.TrimRight(digits, "0")) > 15 {
+		return exp.Expression(text), nil
+	}
+	return exp.Literal(f), nil
+}
@@ -0,0 +1,156 @@
+package parse
+
+import (
+	"testing"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/tsealex/dbutil/query"
+)
+
+// Parses src, builds it back, and checks that the result reads back to the
+// very same query, i.e. that rendering and parsing agree on its meaning.
+func roundTrip(t *testing.T, src string) string {
+	stmt, err := Parse(src)
+	if !assert.NoError(t, err, src) {
+		return ""
+	}
+	q, err := stmt.Build()
+	if !assert.NoError(t, err, src) {
+		return ""
+	}
+	again, err := Parse(q)
+	if !assert.NoError(t, err, q) {
+		return ""
+	}
+	q2, err := again.Build()
+	assert.NoError(t, err, q)
+	assert.Equal(t, q, q2)
+	return q
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{`select * from users`, `SELECT * FROM users`},
+		{`SELECT u.id, "Name" AS n, count(*) c FROM public.users AS u`,
+			`SELECT u.id,"Name" AS n,(count(*)) AS c FROM public.users AS u`},
+		{`SELECT a FROM t WHERE a = $2 AND (b <> $1 OR NOT c) AND d IS NOT NULL`,
+			`SELECT a FROM t WHERE ((a=$2) AND ((b<>$1) OR  NOT c) AND (d IS NOT NULL))`},
+		{`SELECT a + b * -c, 2 ^ 3, x::int[], CAST(y AS double precision) FROM t`,
+			`SELECT (a+(b*-c)),(2^3),(x)::int[],(y)::double precision FROM t`},
+		{`SELECT a FROM t WHERE name LIKE 'it''s%' AND tags @> ARRAY['x', $1] ` +
+			`AND t.ts >= now() - '1 day'::interval`,
+			`SELECT a FROM t WHERE ((name ~~ 'it''s%') AND (tags@>ARRAY['x',$1]) ` +
+				`AND (t.ts>=(now()-('1 day')::interval)))`},
+		{`SELECT a, sum(b) FROM t GROUP BY a HAVING sum(b) > 10 ORDER BY a DESC NULLS LAST, 2`,
+			`SELECT a,sum(b) FROM t GROUP BY a HAVING (sum(b)>10) ` +
+				`ORDER BY a DESC NULLS LAST,2`},
+		{`INSERT INTO t (a, "B") VALUES ($1, DEFAULT_VALUE()) ` +
+			`ON CONFLICT (a) DO UPDATE SET b = excluded.b RETURNING id;`,
+			`INSERT INTO t (a,"B") VALUES ($1,default_value()) ` +
+				`ON CONFLICT (a) DO UPDATE SET b=excluded.b RETURNING id`},
+		{`insert into t (a) values (1) on conflict do nothing`,
+			`INSERT INTO t (a) VALUES (1) ON CONFLICT DO NOTHING`},
+		{`UPDATE t SET a = $1, b = b - -1 WHERE id = $2 RETURNING a, b`,
+			`UPDATE t SET a=$1,b=(b- -1) WHERE (id=$2) RETURNING a,b`},
+		{`DELETE FROM s.t WHERE ts < CURRENT_TIMESTAMP`,
+			`DELETE FROM s.t WHERE (ts<CURRENT_TIMESTAMP)`},
+		{`SELECT E'a\'b\\c', $$x'y$$, 1.5e3, 123456789012345678901234567890`,
+			`SELECT E'a''b\\c','x''y',1500,123456789012345678901234567890`},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, roundTrip(t, c.src))
+	}
+}
+
+// Pretty output must read back to the same query as compact output.
+func TestParse_Pretty(t *testing.T) {
+	for _, src := range []string{
+		`SELECT a + b * -c, (a + b) * c, a - (b - c), 2 ^ -3 ^ 2, -(a + b)::int FROM t`,
+		`SELECT a FROM t WHERE NOT (a OR b) AND (c = d) = e AND f IS NOT NULL ` +
+			`AND (NOT g) IS TRUE AND h || i ~~ 'x%' AND j::text <> $1`,
+		`SELECT a, sum(b) AS s FROM t GROUP BY a HAVING sum(b) > 1 OR count(*) = 0 ` +
+			`ORDER BY a DESC NULLS LAST, (a + 1) ASC`,
+		`SELECT CAST(-1 AS int), '1 day'::interval, ARRAY[1, a - 1], lower(a || 'x') FROM t`,
+		`INSERT INTO t (a, b) VALUES ($1, a - -1) ON CONFLICT (a) DO UPDATE SET b = excluded.b * 2 ` +
+			`RETURNING id`,
+		`UPDATE t SET a = a - 1, b = NOT (b AND c) WHERE a = 1 OR b AND c RETURNING a`,
+		`DELETE FROM t WHERE a @> ARRAY[1] AND (b OR c)`,
+	} {
+		stmt, err := Parse(src)
+		if !assert.NoError(t, err, src) {
+			continue
+		}
+		compact, err := stmt.Build()
+		assert.NoError(t, err, src)
+		ctx := query.NewSQLContext()
+		ctx.Pretty = true
+		stmt.Recipe.UseContext(ctx)
+		pretty, err := stmt.Build()
+		assert.NoError(t, err, src)
+		assert.NotEqual(t, compact, pretty)
+		again, err := Parse(pretty)
+		if !assert.NoError(t, err, pretty) {
+			continue
+		}
+		q, err := again.Build()
+		assert.NoError(t, err, pretty)
+		assert.Equal(t, compact, q, pretty)
+	}
+}
+
+func TestParse_Placeholders(t *testing.T) {
+	// Indexes are kept even if they appear out of order or several times.
+	q := roundTrip(t, `SELECT a FROM t WHERE b = $2 AND c = $1 AND d = $2`)
+	assert.Equal(t, `SELECT a FROM t WHERE ((b=$2) AND (c=$1) AND (d=$2))`, q)
+}
+
+func TestStatement_BuildConcurrently(t *testing.T) {
+	stmt, err := Parse(`SELECT a FROM t WHERE b = $2 AND c = $1`)
+	assert.NoError(t, err)
+	res := make(chan string)
+	for i := 0; i < 4; i++ {
+		go func() {
+			q, _ := stmt.Build()
+			res <- q
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, `SELECT a FROM t WHERE ((b=$2) AND (c=$1))`, <-res)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`SELECT`,
+		`SELECT a FROM`,
+		`SELECT a FROM t WHERE`,
+		`SELECT a FROM t LIMIT 1`,
+		`SELECT a FROM t JOIN u ON true`,
+		`SELECT (SELECT 1)`,
+		`SELECT a IN (1, 2)`,
+		`SELECT 'unterminated`,
+		`SELECT from`,
+		`INSERT INTO t (a, b) VALUES (1)`,
+		`INSERT INTO t (a) VALUES (1), (2)`,
+		`UPDATE t SET a`,
+		`DROP TABLE t`,
+	} {
+		_, err := Parse(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestParseExp(t *testing.T) {
+	e, err := ParseExp(`a = 1 OR b = 2 AND NOT c ~~* $1`)
+	assert.NoError(t, err)
+	b := bytes.Buffer{}
+	assert.NoError(t, e.ToSQL(query.NewSQLContext(), &b))
+	assert.Equal(t, `((a=1) OR ((b=2) AND  NOT (c ~~* $1)))`, b.String())
+
+	_, err = ParseExp(`a = `)
+	assert.Error(t, err)
+}